@@ -6,12 +6,21 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc"
 
 	csipkg "github.com/sistemica/docker-volume-manager/pkg/driver/csi"
+
+	// Import backends to register them; node-side operations run in the plugin
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/archive"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/git"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/image"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/local"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/nfs"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/s3"
 )
 
 const (
@@ -32,25 +41,40 @@ func main() {
 		endpoint = "unix:///csi/csi.sock"
 	}
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Error("failed to get hostname", "error", err)
+		os.Exit(1)
+	}
+
 	nodeID := os.Getenv("NODE_ID")
 	if nodeID == "" {
-		hostname, err := os.Hostname()
-		if err != nil {
-			logger.Error("failed to get hostname", "error", err)
-			os.Exit(1)
-		}
 		nodeID = hostname
 	}
 
+	topology, err := csipkg.NodeTopology(nodeID, hostname, os.Getenv("NODE_LABELS"))
+	if err != nil {
+		logger.Error("invalid node labels", "error", err)
+		os.Exit(1)
+	}
+
 	managerURL := os.Getenv("MANAGER_URL")
 	if managerURL == "" {
 		managerURL = "http://volume-manager:9789"
 	}
 
+	// Volumes staged on this node are recorded below DATA_DIR
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "/var/lib/volume-manager"
+	}
+
 	logger.Info("CSI plugin configuration",
 		"endpoint", endpoint,
 		"node_id", nodeID,
 		"manager_url", managerURL,
+		"topology", topology,
+		"data_dir", dataDir,
 	)
 
 	// Create CSI services
@@ -62,7 +86,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	nodeServer, err := csipkg.NewNodeServer(nodeID, managerURL, logger)
+	nodeServer, err := csipkg.NewNodeServer(nodeID, managerURL, topology, filepath.Join(dataDir, "staged"), logger)
 	if err != nil {
 		logger.Error("failed to create node server", "error", err)
 		os.Exit(1)
@@ -106,9 +130,20 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Register the node's topology for placing new volumes, refresh and grow
+	// staged volumes when their content or capacity changes, report the
+	// health of staged volumes to the manager and take the snapshots of
+	// node-local volumes living here
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	go nodeServer.Register(watchCtx)
+	go nodeServer.WatchRefreshes(watchCtx)
+	go nodeServer.MonitorHealth(watchCtx)
+	go nodeServer.SyncSnapshots(watchCtx)
+
 	go func() {
 		<-sigChan
 		logger.Info("shutting down CSI plugin")
+		stopWatch()
 		grpcServer.GracefulStop()
 	}()
 
@@ -6,18 +6,33 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/sistemica/docker-volume-manager/pkg/api"
+	"github.com/sistemica/docker-volume-manager/pkg/backup"
 	"github.com/sistemica/docker-volume-manager/pkg/config"
 	"github.com/sistemica/docker-volume-manager/pkg/store"
 
 	// Import backends to register them
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/archive"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/git"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/image"
 	_ "github.com/sistemica/docker-volume-manager/pkg/storage/local"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/nfs"
+	_ "github.com/sistemica/docker-volume-manager/pkg/storage/s3"
 )
 
+// fileStoreName is the file store's database file within DATA_DIR
+const fileStoreName = "volume-manager.db"
+
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		os.Exit(runRestore(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -33,36 +48,60 @@ func main() {
 		"port", cfg.Port,
 	)
 
-	// Create metadata store (etcd or memory)
+	// Create metadata store (etcd, file or memory)
 	var metaStore store.Store
 
-	if cfg.EtcdEnabled {
-		etcdCfg := store.EtcdConfig{
-			DataDir:      cfg.DataDir,
-			Name:         cfg.ServiceName,
-			ClusterSize:  cfg.ClusterSize,
-			ServiceName:  cfg.ServiceName,
-			TaskSlot:     cfg.TaskSlot,
-			ClientPort:   cfg.EtcdClientPort,
-			PeerPort:     cfg.EtcdPeerPort,
-		}
+	switch cfg.StoreType {
+	case config.StoreEtcd:
+		etcdCfg := etcdConfig(cfg)
 		metaStore, err = store.NewEtcdStore(etcdCfg, logger)
 		if err != nil {
 			logger.Error("failed to create etcd store", "error", err)
 			os.Exit(1)
 		}
-		logger.Info("initialized embedded etcd metadata store",
-			"cluster_size", cfg.ClusterSize,
-			"task_slot", cfg.TaskSlot,
-		)
-	} else {
+		if etcdCfg.External() {
+			logger.Info("initialized external etcd metadata store",
+				"endpoints", cfg.EtcdEndpoints,
+			)
+		} else {
+			logger.Info("initialized embedded etcd metadata store",
+				"cluster_size", cfg.ClusterSize,
+				"task_slot", cfg.TaskSlot,
+				"tls_mode", cfg.EtcdTLSMode,
+			)
+		}
+	case config.StoreFile:
+		storePath := filepath.Join(cfg.DataDir, fileStoreName)
+		metaStore, err = store.NewFileStore(storePath, logger)
+		if err != nil {
+			logger.Error("failed to create file store", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("initialized file metadata store", "path", storePath)
+	default:
 		metaStore = store.NewMemoryStore()
 		logger.Info("initialized in-memory metadata store")
 	}
 	defer metaStore.Close()
 
+	// Scheduled metadata snapshots (memory store has nothing to snapshot)
+	var backups *backup.Manager
+	if snapshotStore, ok := metaStore.(store.SnapshotStore); ok {
+		backups, err = backup.NewManager(snapshotStore, cfg.SnapshotDir, cfg.SnapshotRetention, logger)
+		if err != nil {
+			logger.Error("failed to create snapshot manager", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	backupCtx, stopBackups := context.WithCancel(context.Background())
+	defer stopBackups()
+	if backups != nil && cfg.SnapshotInterval > 0 {
+		go backups.Run(backupCtx, cfg.SnapshotInterval)
+	}
+
 	// Create API server
-	server := api.NewServer(cfg, metaStore, logger)
+	server := api.NewServer(cfg, metaStore, backups, logger)
 
 	// Start server in goroutine
 	go func() {
@@ -93,6 +132,31 @@ func main() {
 	logger.Info("volume manager stopped")
 }
 
+// etcdConfig builds the etcd store configuration from the application config
+func etcdConfig(cfg *config.Config) store.EtcdConfig {
+	return store.EtcdConfig{
+		DataDir:      cfg.DataDir,
+		Name:         cfg.ServiceName,
+		ClusterSize:  cfg.ClusterSize,
+		ServiceName:  cfg.ServiceName,
+		TaskSlot:     cfg.TaskSlot,
+		ClientPort:   cfg.EtcdClientPort,
+		PeerPort:     cfg.EtcdPeerPort,
+		Endpoints:    cfg.EtcdEndpoints,
+		CertFile:     cfg.EtcdCertFile,
+		KeyFile:      cfg.EtcdKeyFile,
+		CAFile:       cfg.EtcdCAFile,
+		Username:     cfg.EtcdUsername,
+		Password:     cfg.EtcdPassword,
+		KeyPrefix:    cfg.EtcdKeyPrefix,
+		TLSMode:      cfg.EtcdTLSMode,
+		TLSCertFile:  cfg.EtcdTLSCertFile,
+		TLSKeyFile:   cfg.EtcdTLSKeyFile,
+		TLSCAFile:    cfg.EtcdTLSCAFile,
+		TLSCAKeyFile: cfg.EtcdTLSCAKeyFile,
+	}
+}
+
 // setupLogger configures the structured logger
 func setupLogger(cfg *config.Config) *slog.Logger {
 	var level slog.Level
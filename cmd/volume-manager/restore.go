@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sistemica/docker-volume-manager/pkg/config"
+	"github.com/sistemica/docker-volume-manager/pkg/store"
+)
+
+// runRestore implements `volume-manager restore`, which rebuilds the metadata
+// store under DATA_DIR from a snapshot. It returns the process exit code.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := fs.Bool("force", false, "move existing store data aside instead of refusing to restore")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: volume-manager restore [-force] <snapshot-file>\n\n")
+		fmt.Fprintf(fs.Output(), "Rebuilds the metadata store in DATA_DIR from a snapshot. STORE_TYPE and the\n")
+		fmt.Fprintf(fs.Output(), "etcd settings are read from the environment like the server does. Stop the\n")
+		fmt.Fprintf(fs.Output(), "volume manager first; in an etcd cluster, restore the same snapshot on every task.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	snapshotPath := fs.Arg(0)
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	if _, err := os.Stat(snapshotPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read snapshot: %v\n", err)
+		return 1
+	}
+
+	var target string
+	switch cfg.StoreType {
+	case config.StoreEtcd:
+		if len(cfg.EtcdEndpoints) > 0 {
+			fmt.Fprintf(os.Stderr, "External etcd clusters are restored with etcdutl on the etcd hosts\n")
+			return 1
+		}
+		target = filepath.Join(cfg.DataDir, "etcd")
+	case config.StoreFile:
+		target = filepath.Join(cfg.DataDir, fileStoreName)
+	default:
+		fmt.Fprintf(os.Stderr, "Store type %q keeps no data on disk; set STORE_TYPE to etcd or file\n", cfg.StoreType)
+		return 1
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		if !*force {
+			fmt.Fprintf(os.Stderr, "%s already exists; use -force to move it aside\n", target)
+			return 1
+		}
+		backup := fmt.Sprintf("%s.bak-%s", target, time.Now().UTC().Format("20060102T150405Z"))
+		if err := os.Rename(target, backup); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to move existing data aside: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Moved existing data to %s\n", backup)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Cannot check %s: %v\n", target, err)
+		return 1
+	}
+
+	if cfg.StoreType == config.StoreEtcd {
+		err = store.RestoreEtcdSnapshot(etcdConfig(cfg), snapshotPath)
+	} else {
+		err = store.RestoreFileSnapshot(snapshotPath, target)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Restored %s store from %s into %s\n", cfg.StoreType, snapshotPath, target)
+	return 0
+}
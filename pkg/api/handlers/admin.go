@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sistemica/docker-volume-manager/pkg/backup"
+	"github.com/sistemica/docker-volume-manager/pkg/store"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// AdminHandler handles operational requests about the manager itself
+type AdminHandler struct {
+	store   store.Store
+	backups *backup.Manager // nil when the store cannot be snapshotted
+	logger  *slog.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(store store.Store, backups *backup.Manager, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{
+		store:   store,
+		backups: backups,
+		logger:  logger.With("handler", "admin"),
+	}
+}
+
+// HandleListMembers handles GET /api/v1/admin/cluster/members
+func (h *AdminHandler) HandleListMembers(c echo.Context) error {
+	clusterStore, ok := h.store.(store.ClusterStore)
+	if !ok {
+		return c.JSON(http.StatusNotImplemented, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "The metadata store does not run as a cluster",
+		})
+	}
+
+	members, err := clusterStore.Members(c.Request().Context())
+	if err != nil {
+		h.logger.Error("failed to list cluster members", "error", err)
+		return c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error:   "cluster_unavailable",
+			Message: "Failed to list cluster members",
+		})
+	}
+
+	healthy := 0
+	for _, m := range members {
+		if m.Healthy {
+			healthy++
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"members": members,
+		"count":   len(members),
+		"healthy": healthy,
+	})
+}
+
+// HandleCreateSnapshot handles POST /api/v1/admin/snapshots
+func (h *AdminHandler) HandleCreateSnapshot(c echo.Context) error {
+	if h.backups == nil {
+		return snapshotsNotSupported(c)
+	}
+
+	info, err := h.backups.Create(c.Request().Context())
+	if err != nil {
+		h.logger.Error("failed to create snapshot", "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "snapshot_failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, info)
+}
+
+// HandleListSnapshots handles GET /api/v1/admin/snapshots
+func (h *AdminHandler) HandleListSnapshots(c echo.Context) error {
+	if h.backups == nil {
+		return snapshotsNotSupported(c)
+	}
+
+	snapshots, err := h.backups.List()
+	if err != nil {
+		h.logger.Error("failed to list snapshots", "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to list snapshots",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshots": snapshots,
+		"count":     len(snapshots),
+	})
+}
+
+// HandleDownloadSnapshot handles GET /api/v1/admin/snapshots/:name
+func (h *AdminHandler) HandleDownloadSnapshot(c echo.Context) error {
+	if h.backups == nil {
+		return snapshotsNotSupported(c)
+	}
+
+	name := c.Param("name")
+	f, info, err := h.backups.Open(name)
+	if errors.Is(err, backup.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "not_found",
+			Message: "Snapshot not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("failed to open snapshot", "name", name, "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to open snapshot",
+		})
+	}
+	defer f.Close()
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+info.Name+`"`)
+	http.ServeContent(c.Response(), c.Request(), info.Name, info.CreatedAt, f)
+	return nil
+}
+
+// snapshotsNotSupported responds for stores without snapshot support
+func snapshotsNotSupported(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, types.ErrorResponse{
+		Error:   "not_supported",
+		Message: "The metadata store does not support snapshots",
+	})
+}
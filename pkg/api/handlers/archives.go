@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/store"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// replicaCheckTimeout bounds the health check of the replica holding an
+// archive before an upload is proxied to it
+const replicaCheckTimeout = 5 * time.Second
+
+// ArchiveHandler handles the content uploaded to seed volumes
+type ArchiveHandler struct {
+	store  store.Store
+	logger *slog.Logger
+}
+
+// NewArchiveHandler creates a new archive handler
+func NewArchiveHandler(store store.Store, logger *slog.Logger) *ArchiveHandler {
+	return &ArchiveHandler{
+		store:  store,
+		logger: logger.With("handler", "archive"),
+	}
+}
+
+// IsArchiveRequest reports whether a request transfers a volume archive,
+// which can take longer than the request timeout
+func IsArchiveRequest(c echo.Context) bool {
+	return strings.HasSuffix(c.Path(), "/volumes/:id/archive")
+}
+
+// proxy forwards an archive request to the volume manager replica holding
+// the archive, since uploads are kept on the replica that received them
+func (h *ArchiveHandler) proxy(c echo.Context, owner string) error {
+	target, err := url.Parse(owner)
+	if err != nil {
+		h.logger.Error("invalid archive replica", "error", err, "volume_id", c.Param("id"), "replica", owner)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Invalid archive replica: " + owner,
+		})
+	}
+
+	h.logger.Debug("proxying archive request", "volume_id", c.Param("id"), "replica", owner)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		h.logger.Error("failed to reach archive replica", "error", err, "volume_id", c.Param("id"), "replica", owner)
+		_ = c.JSON(http.StatusBadGateway, types.ErrorResponse{
+			Error:   "replica_unavailable",
+			Message: "The volume manager replica holding the archive is unavailable: " + owner,
+		})
+	}
+	proxy.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// reachable reports whether a volume manager replica answers its health check
+func (h *ArchiveHandler) reachable(ctx context.Context, replica string) bool {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, replica+"/health", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.logger.Warn("archive replica is unreachable", "replica", replica, "error", err)
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// getUploader loads the volume named in the path and the upload support of
+// its backend. If either cannot be returned, the error response has already
+// been written and the volume is nil.
+func (h *ArchiveHandler) getUploader(c echo.Context) (*types.Volume, storage.Uploader, error) {
+	id := c.Param("id")
+
+	volume, err := h.store.GetVolume(c.Request().Context(), id)
+	if err != nil {
+		return nil, nil, h.errorResponse(c, err, id)
+	}
+
+	uploader, err := storage.GetUploader(volume.Backend)
+	if err != nil {
+		return nil, nil, c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not support uploads: " + volume.Backend,
+		})
+	}
+
+	return volume, uploader, nil
+}
+
+// HandleUpload handles PUT /api/v1/volumes/:id/archive
+// The new archive is only used once its checksum replaces the one the
+// upload started from; a concurrent upload that got there first wins.
+func (h *ArchiveHandler) HandleUpload(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	volume, uploader, err := h.getUploader(c)
+	if err != nil {
+		return err
+	}
+	if volume == nil {
+		return nil
+	}
+
+	// A replica that is gone, for example after scaling down, hands the
+	// archive over to this one
+	if owner := uploader.UploadOwner(volume); owner != "" && h.reachable(ctx, owner) {
+		return h.proxy(c, owner)
+	}
+
+	previous := volume.Parameters["checksum"]
+	params, err := uploader.Upload(ctx, volume, c.Request().Body)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidUpload) {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+		}
+		h.logger.Error("failed to store upload", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "upload_failed",
+			Message: err.Error(),
+		})
+	}
+
+	volume, err = store.UpdateVolumeWithRetry(ctx, h.store, id, func(v *types.Volume) error {
+		if v.Parameters["checksum"] != previous {
+			return fmt.Errorf("%w: the archive of volume %s was replaced by another upload", store.ErrConflict, id)
+		}
+		if v.Parameters == nil {
+			v.Parameters = make(map[string]string, len(params))
+		}
+		for k, val := range params {
+			v.Parameters[k] = val
+		}
+		v.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		// The upload lost the race; drop it unless this replica also holds
+		// the archive that won
+		if current, getErr := h.store.GetVolume(ctx, id); getErr == nil && uploader.UploadOwner(current) != "" {
+			if err := uploader.DeleteUpload(ctx, current); err != nil {
+				h.logger.Warn("failed to remove unused upload", "error", err, "volume_id", id)
+			}
+		}
+		return h.errorResponse(c, err, id)
+	}
+
+	h.logger.Info("archive uploaded", "volume_id", id, "checksum", volume.Parameters["checksum"])
+
+	return c.JSON(http.StatusOK, volume)
+}
+
+// HandleDownload handles GET /api/v1/volumes/:id/archive
+func (h *ArchiveHandler) HandleDownload(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	volume, uploader, err := h.getUploader(c)
+	if err != nil {
+		return err
+	}
+	if volume == nil {
+		return nil
+	}
+
+	if owner := uploader.UploadOwner(volume); owner != "" {
+		return h.proxy(c, owner)
+	}
+
+	f, err := uploader.OpenUpload(ctx, volume)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "not_found",
+			Message: "No archive has been uploaded for the volume",
+		})
+	}
+	if err != nil {
+		h.logger.Error("failed to open upload", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to open archive",
+		})
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		h.logger.Error("failed to open upload", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to open archive",
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	http.ServeContent(c.Response(), c.Request(), "", info.ModTime(), f)
+	return nil
+}
+
+// HandleDeleteUpload handles DELETE /api/v1/backends/:name/uploads/:id
+// Replicas call it on the replica holding an upload once its volume has been
+// deleted, so the volume is not looked up.
+func (h *ArchiveHandler) HandleDeleteUpload(c echo.Context) error {
+	name := c.Param("name")
+	id := c.Param("id")
+
+	uploader, err := storage.GetUploader(name)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not support uploads: " + name,
+		})
+	}
+
+	volume := &types.Volume{ID: id, Backend: name}
+	if err := uploader.DeleteUpload(c.Request().Context(), volume); err != nil {
+		h.logger.Error("failed to delete upload", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete upload",
+		})
+	}
+
+	h.logger.Info("upload deleted", "volume_id", id, "backend", name)
+
+	return c.JSON(http.StatusOK, types.SuccessResponse{
+		Message: "Upload deleted successfully",
+	})
+}
+
+// errorResponse maps store errors to responses
+func (h *ArchiveHandler) errorResponse(c echo.Context, err error, id string) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "not_found",
+			Message: "Volume not found",
+		})
+	case errors.Is(err, store.ErrConflict):
+		return c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:   "conflict",
+			Message: err.Error(),
+			Code:    "conflict",
+		})
+	default:
+		h.logger.Error("failed to access volume", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to access volume",
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -37,3 +38,47 @@ func (h *BackendHandler) HandleList(c echo.Context) error {
 		"count":    len(backends),
 	})
 }
+
+// HandleCapacity handles GET /api/v1/backends/:name/capacity
+// Query parameters are the volume parameters the capacity is asked for.
+func (h *BackendHandler) HandleCapacity(c echo.Context) error {
+	name := c.Param("name")
+
+	reporter, err := storage.GetUsageReporter(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrBackendNotFound) {
+			return c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "not_found",
+				Message: "Backend not found: " + name,
+			})
+		}
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not report capacity: " + name,
+		})
+	}
+
+	params := make(map[string]string)
+	for key, values := range c.QueryParams() {
+		params[key] = values[0]
+	}
+
+	available, err := reporter.AvailableCapacity(c.Request().Context(), params)
+	if errors.Is(err, storage.ErrCapacityUnknown) {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"backend":         name,
+		"available_bytes": available,
+	})
+}
@@ -17,7 +17,7 @@ func NewHealthHandler() *HealthHandler {
 // HandleHealth handles GET /health
 func (h *HealthHandler) HandleHealth(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"status": "healthy",
+		"status":  "healthy",
 		"service": "volume-manager",
 	})
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sistemica/docker-volume-manager/pkg/store"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// NodeHandler handles the registration of CSI nodes and their topology
+type NodeHandler struct {
+	store  store.Store
+	logger *slog.Logger
+}
+
+// NewNodeHandler creates a new node handler
+func NewNodeHandler(store store.Store, logger *slog.Logger) *NodeHandler {
+	return &NodeHandler{
+		store:  store,
+		logger: logger.With("handler", "node"),
+	}
+}
+
+// HandleRegister handles PUT /api/v1/nodes/:id
+// Nodes register on start and then periodically, so the time of the last
+// registration tells whether a node is still around.
+func (h *NodeHandler) HandleRegister(c echo.Context) error {
+	var req types.RegisterNodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+	}
+
+	node := &types.Node{
+		ID:        c.Param("id"),
+		Topology:  req.Topology,
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.store.PutNode(c.Request().Context(), node); err != nil {
+		h.logger.Error("failed to register node", "node_id", node.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to register node",
+		})
+	}
+
+	h.logger.Debug("node registered", "node_id", node.ID, "topology", node.Topology)
+	return c.JSON(http.StatusOK, node)
+}
+
+// HandleList handles GET /api/v1/nodes
+func (h *NodeHandler) HandleList(c echo.Context) error {
+	nodes, err := h.store.ListNodes(c.Request().Context())
+	if err != nil {
+		h.logger.Error("failed to list nodes", "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to list nodes",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"nodes": nodes,
+		"count": len(nodes),
+	})
+}
@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/store"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// errSnapshotState is returned when a node reports a snapshot that is not
+// waiting for it
+var errSnapshotState = errors.New("snapshot is not waiting for this node")
+
+// SnapshotHandler handles volume snapshot requests
+type SnapshotHandler struct {
+	store  store.Store
+	logger *slog.Logger
+}
+
+// NewSnapshotHandler creates a new snapshot handler
+func NewSnapshotHandler(store store.Store, logger *slog.Logger) *SnapshotHandler {
+	return &SnapshotHandler{
+		store:  store,
+		logger: logger.With("handler", "snapshot"),
+	}
+}
+
+// HandleCreate handles POST /api/v1/volumes/:id/snapshots
+// Backends that copy on the manager answer 201 with a ready snapshot;
+// snapshots of node-local volumes are taken later and answered with 202.
+func (h *SnapshotHandler) HandleCreate(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	var req types.CreateSnapshotRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Snapshot name is required",
+		})
+	}
+
+	volume, err := h.store.GetVolume(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "not_found",
+				Message: "Volume not found",
+			})
+		}
+		h.logger.Error("failed to get volume", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get volume",
+		})
+	}
+
+	// Check the name before copying any data
+	if _, err := h.store.GetSnapshotByName(ctx, req.Name); err == nil {
+		return snapshotExists(c)
+	} else if !errors.Is(err, store.ErrSnapshotNotFound) {
+		h.logger.Error("failed to get snapshot", "error", err, "name", req.Name)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get snapshot",
+		})
+	}
+
+	snapshot := &types.Snapshot{
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		SourceVolumeID: volume.ID,
+		Backend:        volume.Backend,
+		CreatedAt:      time.Now(),
+	}
+
+	snapshotter, err := storage.GetSnapshotter(volume.Backend)
+	if err != nil {
+		if errors.Is(err, storage.ErrSnapshotNotSupported) {
+			// The data of node-local volumes is copied on their node
+			if _, err := storage.GetNodeSnapshotter(volume.Backend); err == nil {
+				return h.createOnNode(c, volume, snapshot)
+			}
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "not_supported",
+				Message: "Backend does not support snapshots: " + volume.Backend,
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get backend",
+		})
+	}
+
+	if err := snapshotter.CreateSnapshot(ctx, volume, snapshot); err != nil {
+		h.logger.Error("failed to create snapshot", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "snapshot_failed",
+			Message: err.Error(),
+		})
+	}
+	snapshot.Status = types.SnapshotStatusReady
+	snapshot.ReadyToUse = true
+
+	// Record the snapshot, dropping the copied data again if that fails
+	if err := h.store.CreateSnapshot(ctx, snapshot); err != nil {
+		if err := snapshotter.DeleteSnapshot(ctx, snapshot); err != nil {
+			h.logger.Error("failed to remove unrecorded snapshot", "error", err, "snapshot_id", snapshot.ID)
+		}
+		if errors.Is(err, store.ErrSnapshotExists) {
+			return snapshotExists(c)
+		}
+		h.logger.Error("failed to store snapshot", "error", err, "snapshot_id", snapshot.ID)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create snapshot",
+		})
+	}
+
+	h.logger.Info("snapshot created", "snapshot_id", snapshot.ID, "name", snapshot.Name, "volume_id", volume.ID)
+
+	return c.JSON(http.StatusCreated, snapshot)
+}
+
+// HandleList handles GET /api/v1/snapshots and GET /api/v1/volumes/:id/snapshots
+// The top-level listing can be narrowed with ?source_volume_id=, ?name= and
+// ?node=, the node holding the data of node-local snapshots.
+func (h *SnapshotHandler) HandleList(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	sourceVolumeID := c.Param("id")
+	if sourceVolumeID != "" {
+		if _, err := h.store.GetVolume(ctx, sourceVolumeID); errors.Is(err, store.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "not_found",
+				Message: "Volume not found",
+			})
+		}
+	} else {
+		sourceVolumeID = c.QueryParam("source_volume_id")
+	}
+
+	snapshots, err := h.store.ListSnapshots(ctx, sourceVolumeID)
+	if err != nil {
+		h.logger.Error("failed to list snapshots", "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to list snapshots",
+		})
+	}
+
+	name, node := c.QueryParam("name"), c.QueryParam("node")
+	if name != "" || node != "" {
+		filtered := snapshots[:0]
+		for _, s := range snapshots {
+			if (name == "" || s.Name == name) && (node == "" || s.Node == node) {
+				filtered = append(filtered, s)
+			}
+		}
+		snapshots = filtered
+	}
+
+	// Oldest first, so the order is stable across calls
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+		}
+		return snapshots[i].ID < snapshots[j].ID
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshots": snapshots,
+		"count":     len(snapshots),
+	})
+}
+
+// HandleGet handles GET /api/v1/snapshots/:snapshot_id and
+// GET /api/v1/volumes/:id/snapshots/:snapshot_id
+func (h *SnapshotHandler) HandleGet(c echo.Context) error {
+	snapshot, err := h.getSnapshot(c)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return nil
+	}
+
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// HandleDelete handles DELETE /api/v1/snapshots/:snapshot_id and
+// DELETE /api/v1/volumes/:id/snapshots/:snapshot_id
+func (h *SnapshotHandler) HandleDelete(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	snapshot, err := h.getSnapshot(c)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return nil
+	}
+
+	if snapshot.Node != "" {
+		return h.deleteOnNode(c, snapshot)
+	}
+
+	snapshotter, err := storage.GetSnapshotter(snapshot.Backend)
+	if err != nil {
+		h.logger.Error("failed to get snapshot backend", "error", err, "snapshot_id", snapshot.ID)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get backend",
+		})
+	}
+
+	if err := snapshotter.DeleteSnapshot(ctx, snapshot); err != nil {
+		h.logger.Error("failed to delete snapshot data", "error", err, "snapshot_id", snapshot.ID)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "delete_failed",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.store.DeleteSnapshot(ctx, snapshot.ID); err != nil && !errors.Is(err, store.ErrSnapshotNotFound) {
+		h.logger.Error("failed to delete snapshot", "error", err, "snapshot_id", snapshot.ID)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete snapshot",
+		})
+	}
+
+	h.logger.Info("snapshot deleted", "snapshot_id", snapshot.ID)
+
+	return c.JSON(http.StatusOK, types.SuccessResponse{
+		Message: "Snapshot deleted successfully",
+	})
+}
+
+// createOnNode records a snapshot of a node-local volume for the node holding
+// its data to take. It is returned with status creating and becomes ready
+// once the node reports the copy to HandleCreated.
+func (h *SnapshotHandler) createOnNode(c echo.Context, volume *types.Volume, snapshot *types.Snapshot) error {
+	if volume.Node == "" {
+		return c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:   "conflict",
+			Message: "Volume has not been staged on a node yet, so there is no data to snapshot",
+			Code:    "volume_not_pinned",
+		})
+	}
+
+	snapshot.Node = volume.Node
+	snapshot.Status = types.SnapshotStatusCreating
+
+	if err := h.store.CreateSnapshot(c.Request().Context(), snapshot); err != nil {
+		if errors.Is(err, store.ErrSnapshotExists) {
+			return snapshotExists(c)
+		}
+		h.logger.Error("failed to store snapshot", "error", err, "snapshot_id", snapshot.ID)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create snapshot",
+		})
+	}
+
+	h.logger.Info("snapshot requested", "snapshot_id", snapshot.ID, "name", snapshot.Name, "volume_id", volume.ID, "node", snapshot.Node)
+
+	return c.JSON(http.StatusAccepted, snapshot)
+}
+
+// deleteOnNode marks a snapshot for the node holding its data to remove. The
+// record goes once the node reports the removal to HandleDeleted. A failed
+// snapshot has no data, so its record is deleted right away.
+func (h *SnapshotHandler) deleteOnNode(c echo.Context, snapshot *types.Snapshot) error {
+	ctx := c.Request().Context()
+
+	if snapshot.Status == types.SnapshotStatusFailed {
+		if err := h.store.DeleteSnapshot(ctx, snapshot.ID); err != nil && !errors.Is(err, store.ErrSnapshotNotFound) {
+			h.logger.Error("failed to delete snapshot", "error", err, "snapshot_id", snapshot.ID)
+			return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to delete snapshot",
+			})
+		}
+
+		h.logger.Info("snapshot deleted", "snapshot_id", snapshot.ID)
+
+		return c.JSON(http.StatusOK, types.SuccessResponse{
+			Message: "Snapshot deleted successfully",
+		})
+	}
+
+	// Deleting again clears the error of a failed removal, so the node retries
+	updated, err := h.store.UpdateSnapshot(ctx, snapshot.ID, func(snapshot *types.Snapshot) error {
+		snapshot.Status = types.SnapshotStatusDeleting
+		snapshot.ReadyToUse = false
+		snapshot.Error = ""
+		return nil
+	})
+	if err != nil {
+		return h.snapshotErrorResponse(c, err, snapshot.ID)
+	}
+
+	h.logger.Info("snapshot deletion requested", "snapshot_id", updated.ID, "node", updated.Node)
+
+	return c.JSON(http.StatusAccepted, updated)
+}
+
+// HandleCreated handles POST /api/v1/snapshots/:snapshot_id/created, where the
+// node holding a snapshot reports whether it copied the data
+func (h *SnapshotHandler) HandleCreated(c echo.Context) error {
+	id := c.Param("snapshot_id")
+
+	var req types.SnapshotResultRequest
+	if err := c.Bind(&req); err != nil || req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body, node_id is required",
+		})
+	}
+
+	snapshot, err := h.store.UpdateSnapshot(c.Request().Context(), id, func(snapshot *types.Snapshot) error {
+		if snapshot.Node != req.NodeID {
+			return fmt.Errorf("%w: snapshot %s is taken on node %s", errSnapshotState, snapshot.ID, snapshot.Node)
+		}
+
+		switch snapshot.Status {
+		case types.SnapshotStatusCreating:
+			if req.Error != "" {
+				snapshot.Status = types.SnapshotStatusFailed
+				snapshot.Error = req.Error
+				return nil
+			}
+			snapshot.Parameters = req.Parameters
+			snapshot.SizeBytes = req.SizeBytes
+			snapshot.Status = types.SnapshotStatusReady
+			snapshot.ReadyToUse = true
+			snapshot.Error = ""
+		case types.SnapshotStatusDeleting:
+			// Deleted while the node was copying; keep where the copy went so
+			// the node removes it next
+			if req.Error == "" {
+				snapshot.Parameters = req.Parameters
+			}
+		case types.SnapshotStatusReady:
+			// A repeated report of the same copy
+			if req.Error != "" {
+				return fmt.Errorf("%w: snapshot %s is already ready", errSnapshotState, snapshot.ID)
+			}
+		default:
+			return fmt.Errorf("%w: snapshot %s is %s", errSnapshotState, snapshot.ID, snapshot.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		return h.snapshotErrorResponse(c, err, id)
+	}
+
+	if snapshot.Status == types.SnapshotStatusFailed {
+		h.logger.Warn("node failed to create snapshot", "snapshot_id", id, "node", req.NodeID, "error", req.Error)
+	} else {
+		h.logger.Info("snapshot created", "snapshot_id", id, "name", snapshot.Name, "node", req.NodeID)
+	}
+
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// HandleDeleted handles POST /api/v1/snapshots/:snapshot_id/deleted, where the
+// node holding a snapshot reports whether it removed the data. The record is
+// deleted on success and keeps the error otherwise.
+func (h *SnapshotHandler) HandleDeleted(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("snapshot_id")
+
+	var req types.SnapshotResultRequest
+	if err := c.Bind(&req); err != nil || req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body, node_id is required",
+		})
+	}
+
+	snapshot, err := h.store.UpdateSnapshot(ctx, id, func(snapshot *types.Snapshot) error {
+		if snapshot.Node != req.NodeID {
+			return fmt.Errorf("%w: snapshot %s is held by node %s", errSnapshotState, snapshot.ID, snapshot.Node)
+		}
+		if snapshot.Status != types.SnapshotStatusDeleting {
+			return fmt.Errorf("%w: snapshot %s is %s", errSnapshotState, snapshot.ID, snapshot.Status)
+		}
+		snapshot.Error = req.Error
+		return nil
+	})
+	if errors.Is(err, store.ErrSnapshotNotFound) {
+		// A repeated report of the same removal
+		return c.JSON(http.StatusOK, types.SuccessResponse{
+			Message: "Snapshot deleted successfully",
+		})
+	}
+	if err != nil {
+		return h.snapshotErrorResponse(c, err, id)
+	}
+
+	if req.Error != "" {
+		h.logger.Warn("node failed to delete snapshot", "snapshot_id", id, "node", req.NodeID, "error", req.Error)
+		return c.JSON(http.StatusOK, snapshot)
+	}
+
+	if err := h.store.DeleteSnapshot(ctx, id); err != nil && !errors.Is(err, store.ErrSnapshotNotFound) {
+		h.logger.Error("failed to delete snapshot", "error", err, "snapshot_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to delete snapshot",
+		})
+	}
+
+	h.logger.Info("snapshot deleted", "snapshot_id", id, "node", req.NodeID)
+
+	return c.JSON(http.StatusOK, types.SuccessResponse{
+		Message: "Snapshot deleted successfully",
+	})
+}
+
+// snapshotErrorResponse writes the response for a failed snapshot update
+func (h *SnapshotHandler) snapshotErrorResponse(c echo.Context, err error, id string) error {
+	switch {
+	case errors.Is(err, store.ErrSnapshotNotFound):
+		return c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "not_found",
+			Message: "Snapshot not found",
+		})
+	case errors.Is(err, errSnapshotState):
+		return c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:   "conflict",
+			Message: err.Error(),
+			Code:    "conflict",
+		})
+	default:
+		h.logger.Error("failed to update snapshot", "error", err, "snapshot_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update snapshot",
+		})
+	}
+}
+
+// getSnapshot loads the snapshot named in the path. When the route is nested
+// under a volume, the snapshot must belong to it. If the snapshot cannot be
+// returned, the error response has already been written and it returns nil.
+func (h *SnapshotHandler) getSnapshot(c echo.Context) (*types.Snapshot, error) {
+	id := c.Param("snapshot_id")
+
+	snapshot, err := h.store.GetSnapshot(c.Request().Context(), id)
+	if err == nil && c.Param("id") != "" && snapshot.SourceVolumeID != c.Param("id") {
+		err = store.ErrSnapshotNotFound
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrSnapshotNotFound) {
+			return nil, c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "not_found",
+				Message: "Snapshot not found",
+			})
+		}
+		h.logger.Error("failed to get snapshot", "error", err, "snapshot_id", id)
+		return nil, c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to get snapshot",
+		})
+	}
+
+	return snapshot, nil
+}
+
+// snapshotExists writes the response for a duplicate snapshot name
+func snapshotExists(c echo.Context) error {
+	return c.JSON(http.StatusConflict, types.ErrorResponse{
+		Error:   "already_exists",
+		Message: "Snapshot with this name already exists",
+	})
+}
@@ -1,18 +1,27 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sistemica/docker-volume-manager/pkg/state"
 	"github.com/sistemica/docker-volume-manager/pkg/storage"
 	"github.com/sistemica/docker-volume-manager/pkg/store"
 	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
 
+// watchHeartbeatInterval is how often an idle watch stream sends a comment line
+const watchHeartbeatInterval = 15 * time.Second
+
 // VolumeHandler handles volume-related requests
 type VolumeHandler struct {
 	store  store.Store
@@ -58,7 +67,7 @@ func (h *VolumeHandler) HandleCreate(c echo.Context) error {
 	}
 
 	// Get backend
-	backend, err := storage.GetBackend(req.Backend)
+	backend, err := storage.GetController(req.Backend)
 	if err != nil {
 		if errors.Is(err, storage.ErrBackendNotFound) {
 			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
@@ -81,15 +90,128 @@ func (h *VolumeHandler) HandleCreate(c echo.Context) error {
 		})
 	}
 
+	if req.CapacityBytes < 0 {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Capacity must not be negative",
+		})
+	}
+	if req.CapacityBytes > 0 && !backend.Capabilities().SupportsCapacity {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not support capacity limits: " + req.Backend,
+		})
+	}
+	if req.CapacityBytes == 0 && backend.Capabilities().RequiresCapacity {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Backend requires a capacity: " + req.Backend,
+		})
+	}
+	for key := range req.Labels {
+		if err := validateLabelKey(key); err != nil {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+		}
+	}
+	if req.AccessMode == "" {
+		req.AccessMode = backend.Capabilities().DefaultAccessMode()
+	}
+	if !backend.Capabilities().SupportsAccessMode(req.AccessMode) {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not support access mode " + string(req.AccessMode) + ": " + req.Backend,
+		})
+	}
+	if req.Node != "" && !backend.Capabilities().NodeLocal {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Backend volumes are not node-local: " + req.Backend,
+		})
+	}
+
+	// Resolve the volume or snapshot a clone is populated from
+	var populate func(ctx context.Context, volume *types.Volume) error
+	if req.Source != nil {
+		if (req.Source.VolumeID == "") == (req.Source.SnapshotID == "") {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Source must set exactly one of volume_id or snapshot_id",
+			})
+		}
+
+		cloner, err := storage.GetCloner(req.Backend)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "not_supported",
+				Message: "Backend does not support cloning: " + req.Backend,
+			})
+		}
+
+		var sourceBackend, sourceNode string
+		if req.Source.VolumeID != "" {
+			source, err := h.store.GetVolume(c.Request().Context(), req.Source.VolumeID)
+			if err != nil {
+				return h.sourceErrorResponse(c, err, store.ErrNotFound, "Source volume not found")
+			}
+			sourceBackend, sourceNode = source.Backend, source.Node
+			populate = func(ctx context.Context, volume *types.Volume) error {
+				return cloner.CloneVolume(ctx, volume, source)
+			}
+		} else {
+			source, err := h.store.GetSnapshot(c.Request().Context(), req.Source.SnapshotID)
+			if err != nil {
+				return h.sourceErrorResponse(c, err, store.ErrSnapshotNotFound, "Source snapshot not found")
+			}
+			if !source.ReadyToUse {
+				return c.JSON(http.StatusConflict, types.ErrorResponse{
+					Error:   "conflict",
+					Message: "Source snapshot is not ready to use",
+					Code:    "snapshot_not_ready",
+				})
+			}
+			sourceBackend, sourceNode = source.Backend, source.Node
+			populate = func(ctx context.Context, volume *types.Volume) error {
+				return cloner.RestoreSnapshot(ctx, volume, source)
+			}
+		}
+
+		if sourceBackend != req.Backend {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Source belongs to backend " + sourceBackend + ", not " + req.Backend,
+			})
+		}
+
+		// The data of a node-local source only exists on its node, so the
+		// clone is populated and placed there
+		if sourceNode != "" && backend.Capabilities().NodeLocal {
+			if req.Node != "" && req.Node != sourceNode {
+				return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+					Error:   "validation_error",
+					Message: "Source lives on node " + sourceNode + ", not " + req.Node,
+				})
+			}
+			req.Node = sourceNode
+		}
+	}
+
 	// Create volume
 	volume := &types.Volume{
-		ID:         uuid.New().String(),
-		Name:       req.Name,
-		Backend:    req.Backend,
-		Parameters: req.Parameters,
-		Status:     types.VolumeStatusCreated,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:            uuid.New().String(),
+		Name:          req.Name,
+		Backend:       req.Backend,
+		Parameters:    req.Parameters,
+		CapacityBytes: req.CapacityBytes,
+		Status:        types.VolumeStatusCreated,
+		Source:        req.Source,
+		Node:          req.Node,
+		AccessMode:    req.AccessMode,
+		Labels:        req.Labels,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
 	// Store volume
@@ -107,15 +229,133 @@ func (h *VolumeHandler) HandleCreate(c echo.Context) error {
 		})
 	}
 
+	// Provision backing storage, dropping the record again if that fails
+	if err := backend.Provision(c.Request().Context(), volume); err != nil {
+		h.logger.Error("failed to provision volume", "error", err, "volume_id", volume.ID)
+		if err := h.store.DeleteVolume(c.Request().Context(), volume.ID, volume.ResourceVersion); err != nil {
+			h.logger.Error("failed to remove unprovisioned volume", "error", err, "volume_id", volume.ID)
+		}
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "provision_failed",
+			Message: err.Error(),
+		})
+	}
+
+	// Populate clones, rolling back the whole volume if the copy fails. The
+	// backend may record parameters to finish populating it on its node.
+	if populate != nil {
+		err := populate(c.Request().Context(), volume)
+		if err == nil {
+			parameters := volume.Parameters
+			var populated *types.Volume
+			populated, err = store.UpdateVolumeWithRetry(c.Request().Context(), h.store, volume.ID, func(volume *types.Volume) error {
+				volume.Parameters = parameters
+				return nil
+			})
+			if err == nil {
+				volume = populated
+			}
+		}
+		if err != nil {
+			h.logger.Error("failed to populate volume", "error", err, "volume_id", volume.ID)
+			if err := backend.Delete(c.Request().Context(), volume); err != nil {
+				h.logger.Error("failed to release unpopulated volume", "error", err, "volume_id", volume.ID)
+			}
+			if err := h.store.DeleteVolume(c.Request().Context(), volume.ID, volume.ResourceVersion); err != nil {
+				h.logger.Error("failed to remove unpopulated volume", "error", err, "volume_id", volume.ID)
+			}
+			return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+				Error:   "clone_failed",
+				Message: err.Error(),
+			})
+		}
+	}
+
 	h.logger.Info("volume created", "volume_id", volume.ID, "name", volume.Name)
 
 	return c.JSON(http.StatusCreated, volume)
 }
 
+// sourceErrorResponse writes the response for a clone source that could not be loaded
+func (h *VolumeHandler) sourceErrorResponse(c echo.Context, err, notFound error, message string) error {
+	if errors.Is(err, notFound) {
+		return c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "not_found",
+			Message: message,
+			Code:    "source_not_found",
+		})
+	}
+	h.logger.Error("failed to get clone source", "error", err)
+	return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+		Error:   "internal_error",
+		Message: "Failed to get clone source",
+	})
+}
+
 // HandleList handles GET /api/v1/volumes
+// Volumes are listed in ID order and can be filtered by name, backend,
+// status, node and label. A name is looked up directly, as names are unique,
+// so it lists at most one volume. With ?limit=N the response holds at most N volumes and a
+// continue token for the next page, passed back as ?continue=.
+// With ?watch=true it streams volume changes instead, see handleWatch.
 func (h *VolumeHandler) HandleList(c echo.Context) error {
-	volumes, err := h.store.ListVolumes(c.Request().Context())
+	if IsWatchRequest(c) {
+		return h.handleWatch(c)
+	}
+
+	opts := store.ListOptions{
+		Filter: types.VolumeFilter{
+			Name:    c.QueryParam("name"),
+			Backend: c.QueryParam("backend"),
+			Status:  types.VolumeStatus(c.QueryParam("status")),
+			Node:    c.QueryParam("node"),
+		},
+		Continue: c.QueryParam("continue"),
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Limit must be a non-negative integer",
+			})
+		}
+		opts.Limit = n
+	}
+
+	for _, selector := range c.QueryParams()["label"] {
+		labels, err := parseLabelSelector(selector)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+		}
+		if opts.Filter.Labels == nil {
+			opts.Filter.Labels = make(map[string]string, len(labels))
+		}
+		for key, value := range labels {
+			opts.Filter.Labels[key] = value
+		}
+	}
+
+	var volumes []*types.Volume
+	var next string
+	var err error
+	if opts.Filter.Name != "" {
+		volumes, err = h.volumeByName(c.Request().Context(), opts.Filter)
+	} else {
+		volumes, next, err = h.store.ListVolumes(c.Request().Context(), opts)
+	}
 	if err != nil {
+		if errors.Is(err, store.ErrInvalidContinue) {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Invalid continue token",
+				Code:    "invalid_continue",
+			})
+		}
 		h.logger.Error("failed to list volumes", "error", err)
 		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "internal_error",
@@ -123,10 +363,136 @@ func (h *VolumeHandler) HandleList(c echo.Context) error {
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"volumes": volumes,
 		"count":   len(volumes),
-	})
+	}
+	if next != "" {
+		response["continue"] = next
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// volumeByName lists the volume with the name of filter if it passes the
+// rest of the filter
+func (h *VolumeHandler) volumeByName(ctx context.Context, filter types.VolumeFilter) ([]*types.Volume, error) {
+	volume, err := h.store.GetVolumeByName(ctx, filter.Name)
+	if errors.Is(err, store.ErrNotFound) {
+		return []*types.Volume{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !filter.Matches(volume) {
+		return []*types.Volume{}, nil
+	}
+	return []*types.Volume{volume}, nil
+}
+
+// parseLabelSelector parses comma-separated label requirements, each
+// "key=value" or just "key" to require the label with any value
+func parseLabelSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(requirement), "=")
+		if err := validateLabelKey(key); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// validateLabelKey checks that a label key can be used in a label selector
+func validateLabelKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "=, ") {
+		return fmt.Errorf("invalid label key %q", key)
+	}
+	return nil
+}
+
+// IsWatchRequest reports whether a request asks for a watch stream
+func IsWatchRequest(c echo.Context) bool {
+	watch, _ := strconv.ParseBool(c.QueryParam("watch"))
+	return watch
+}
+
+// handleWatch streams volume changes as Server-Sent Events. Each event has
+// the change type (ADDED, MODIFIED, DELETED) as its name and the store
+// revision as its ID. Clients resume with ?resource_version=N or the
+// Last-Event-ID header; without either the stream starts with every
+// existing volume as an ADDED event.
+func (h *VolumeHandler) handleWatch(c echo.Context) error {
+	fromRevision := int64(0)
+	resume := c.QueryParam("resource_version")
+	if lastEventID := c.Request().Header.Get("Last-Event-ID"); lastEventID != "" {
+		resume = lastEventID
+	}
+	if resume != "" {
+		rev, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || rev < 0 {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Invalid resource version: " + resume,
+			})
+		}
+		fromRevision = rev
+	}
+
+	ctx := c.Request().Context()
+	events, err := h.store.Watch(ctx, fromRevision)
+	if err != nil {
+		if errors.Is(err, store.ErrCompacted) {
+			return c.JSON(http.StatusGone, types.ErrorResponse{
+				Error:   "expired",
+				Message: "Resource version is too old, list and watch again",
+				Code:    "revision_compacted",
+			})
+		}
+		h.logger.Error("failed to watch volumes", "error", err)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to watch volumes",
+		})
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	h.logger.Debug("volume watch started", "from_revision", fromRevision)
+
+	// Comment lines keep idle connections open through proxies
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Error("failed to marshal volume event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Revision, event.Type, data); err != nil {
+				return nil
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		case <-ctx.Done():
+			return nil
+		}
+	}
 }
 
 // HandleGet handles GET /api/v1/volumes/:id
@@ -151,55 +517,88 @@ func (h *VolumeHandler) HandleGet(c echo.Context) error {
 	return c.JSON(http.StatusOK, volume)
 }
 
-// HandleDelete handles DELETE /api/v1/volumes/:id
-func (h *VolumeHandler) HandleDelete(c echo.Context) error {
+// HandleUpdate handles PATCH /api/v1/volumes/:id
+// It expands the volume to the requested capacity.
+func (h *VolumeHandler) HandleUpdate(c echo.Context) error {
 	id := c.Param("id")
 
-	// Get volume first
+	var req types.UpdateVolumeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.CapacityBytes <= 0 {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Capacity is required",
+		})
+	}
+
 	volume, err := h.store.GetVolume(c.Request().Context(), id)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			return c.JSON(http.StatusNotFound, types.ErrorResponse{
-				Error:   "not_found",
-				Message: "Volume not found",
-			})
-		}
-		h.logger.Error("failed to get volume", "error", err, "volume_id", id)
-		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error:   "internal_error",
-			Message: "Failed to get volume",
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	if volume.CapacityBytes == 0 {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Volume has no capacity limit to expand",
+		})
+	}
+	if req.CapacityBytes < volume.CapacityBytes {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Volumes cannot shrink",
+			Code:    "shrink_not_supported",
 		})
 	}
+	if req.CapacityBytes == volume.CapacityBytes {
+		return c.JSON(http.StatusOK, volume)
+	}
 
-	// Check if volume is still published
-	if len(volume.PublishedOn) > 0 {
-		return c.JSON(http.StatusConflict, types.ErrorResponse{
-			Error:   "volume_in_use",
-			Message: "Volume is still published, unpublish first",
+	expander, err := storage.GetExpander(volume.Backend)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not support expansion: " + volume.Backend,
 		})
 	}
 
-	// Delete volume
-	if err := h.store.DeleteVolume(c.Request().Context(), id); err != nil {
-		h.logger.Error("failed to delete volume", "error", err, "volume_id", id)
+	// Grow the backing storage first; recording a capacity it does not have would be worse
+	if err := expander.ExpandVolume(c.Request().Context(), volume, req.CapacityBytes); err != nil {
+		h.logger.Error("failed to expand volume", "error", err, "volume_id", id)
 		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error:   "internal_error",
-			Message: "Failed to delete volume",
+			Error:   "expand_failed",
+			Message: err.Error(),
 		})
 	}
 
-	h.logger.Info("volume deleted", "volume_id", id)
-
-	return c.JSON(http.StatusOK, types.SuccessResponse{
-		Message: "Volume deleted successfully",
+	volume, err = store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(v *types.Volume) error {
+		if req.CapacityBytes > v.CapacityBytes {
+			v.CapacityBytes = req.CapacityBytes
+			v.UpdatedAt = time.Now()
+		}
+		return nil
 	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Info("volume expanded", "volume_id", id, "capacity_bytes", volume.CapacityBytes)
+
+	return c.JSON(http.StatusOK, volume)
 }
 
-// HandleStage handles POST /api/v1/volumes/:id/stage
-func (h *VolumeHandler) HandleStage(c echo.Context) error {
+// HandleRefresh handles POST /api/v1/volumes/:id/refresh
+// It optionally switches the volume to a new ref and bumps its generation;
+// node plugins refresh the volume wherever it is staged when they see the change.
+func (h *VolumeHandler) HandleRefresh(c echo.Context) error {
 	id := c.Param("id")
 
-	var req types.StageVolumeRequest
+	var req types.RefreshVolumeRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
 			Error:   "invalid_request",
@@ -207,9 +606,68 @@ func (h *VolumeHandler) HandleStage(c echo.Context) error {
 		})
 	}
 
-	req.VolumeID = id
+	volume, err := h.store.GetVolume(c.Request().Context(), id)
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	if _, err := storage.GetRefresher(volume.Backend); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not support refresh: " + volume.Backend,
+		})
+	}
+
+	if req.Ref != "" {
+		backend, err := storage.GetController(volume.Backend)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to get backend",
+			})
+		}
+
+		params := make(map[string]string, len(volume.Parameters)+1)
+		for k, v := range volume.Parameters {
+			params[k] = v
+		}
+		params["ref"] = req.Ref
+		if err := backend.Validate(params); err != nil {
+			return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	volume, err = store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(v *types.Volume) error {
+		if req.Ref != "" {
+			if v.Parameters == nil {
+				v.Parameters = make(map[string]string)
+			}
+			v.Parameters["ref"] = req.Ref
+		}
+		v.Generation++
+		v.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Info("volume refresh requested",
+		"volume_id", id,
+		"ref", volume.Parameters["ref"],
+		"generation", volume.Generation,
+	)
+
+	return c.JSON(http.StatusOK, volume)
+}
+
+// HandleUsage handles GET /api/v1/volumes/:id/usage
+func (h *VolumeHandler) HandleUsage(c echo.Context) error {
+	id := c.Param("id")
 
-	// Get volume
 	volume, err := h.store.GetVolume(c.Request().Context(), id)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
@@ -218,37 +676,181 @@ func (h *VolumeHandler) HandleStage(c echo.Context) error {
 				Message: "Volume not found",
 			})
 		}
+		h.logger.Error("failed to get volume", "error", err, "volume_id", id)
 		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to get volume",
 		})
 	}
 
+	reporter, err := storage.GetUsageReporter(volume.Backend)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Backend does not report usage: " + volume.Backend,
+		})
+	}
+
+	// Node-local volumes are measured on their node, which reports the usage
+	if controller, err := storage.GetController(volume.Backend); err == nil && controller.Capabilities().NodeLocal {
+		if attachment := volume.Attachments[volume.Node]; attachment != nil && attachment.Usage != nil {
+			return c.JSON(http.StatusOK, attachment.Usage)
+		}
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: "Usage of the volume has not been reported by its node",
+		})
+	}
+
+	usage, err := reporter.Usage(c.Request().Context(), volume)
+	if errors.Is(err, storage.ErrUsageUnknown) {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "not_supported",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("failed to get volume usage", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "usage_failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// HandleReportUsage handles POST /api/v1/volumes/:id/usage
+// The node holding a node-local volume reports its usage, which only it can
+// measure.
+func (h *VolumeHandler) HandleReportUsage(c echo.Context) error {
+	id := c.Param("id")
+
+	var req types.VolumeUsageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Node ID is required",
+		})
+	}
+
+	volume, err := store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(volume *types.Volume) error {
+		state.SetUsage(volume, req.NodeID, req.Usage, time.Now())
+		return nil
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Debug("volume usage reported", "volume_id", id, "node_id", req.NodeID, "usage", req.Usage)
+
+	return c.JSON(http.StatusOK, volume)
+}
+
+// HandleDelete handles DELETE /api/v1/volumes/:id
+// The record is removed first, compare-and-swap on the state that was checked,
+// so a node cannot publish the volume while its storage is released. If
+// releasing the storage fails, the record is restored for a retry.
+func (h *VolumeHandler) HandleDelete(c echo.Context) error {
+	id := c.Param("id")
+	ctx := c.Request().Context()
+
+	volume, err := store.DeleteVolumeWithRetry(ctx, h.store, id, func(volume *types.Volume) error {
+		// Check if volume is still published
+		return state.CheckDeletable(volume)
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
 	// Get backend
-	backend, err := storage.GetBackend(volume.Backend)
+	backend, err := storage.GetController(volume.Backend)
 	if err != nil {
+		h.restoreVolume(ctx, volume)
 		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to get backend",
 		})
 	}
 
-	// Stage volume
-	if err := backend.Stage(c.Request().Context(), volume, req.StagingPath); err != nil {
-		h.logger.Error("failed to stage volume", "error", err, "volume_id", id)
+	// Release backing storage
+	if err := backend.Delete(ctx, volume); err != nil {
+		h.logger.Error("failed to delete backing storage", "error", err, "volume_id", id)
+		h.restoreVolume(ctx, volume)
 		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error:   "stage_failed",
+			Error:   "delete_failed",
 			Message: err.Error(),
 		})
 	}
 
-	// Update volume status
-	volume.Status = types.VolumeStatusStaged
-	volume.StagedOn = append(volume.StagedOn, req.NodeID)
-	volume.UpdatedAt = time.Now()
+	h.logger.Info("volume deleted", "volume_id", id)
 
-	if err := h.store.UpdateVolume(c.Request().Context(), volume); err != nil {
-		h.logger.Error("failed to update volume", "error", err)
+	return c.JSON(http.StatusOK, types.SuccessResponse{
+		Message: "Volume deleted successfully",
+	})
+}
+
+// restoreVolume records a deleted volume again after its storage could not
+// be released, so deleting it can be retried
+func (h *VolumeHandler) restoreVolume(ctx context.Context, volume *types.Volume) {
+	if err := h.store.CreateVolume(ctx, volume); err != nil {
+		h.logger.Error("failed to restore volume after failed delete", "error", err, "volume_id", volume.ID)
+	}
+}
+
+// HandleStage handles POST /api/v1/volumes/:id/stage
+// It records that a node has staged the volume, or failed to; the node plugin performs the stage itself.
+func (h *VolumeHandler) HandleStage(c echo.Context) error {
+	id := c.Param("id")
+
+	var req types.StageVolumeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
+	}
+
+	req.VolumeID = id
+
+	if req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Node ID is required",
+		})
+	}
+
+	if req.StagingPath == "" && req.Error == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Staging path is required",
+		})
+	}
+
+	// Record the node's transition
+	volume, err := store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(volume *types.Volume) error {
+		if req.Error != "" {
+			state.Fail(volume, req.NodeID, req.Error, time.Now())
+			return nil
+		}
+		if err := state.Stage(volume, req.NodeID, req.StagingPath, req.Generation, time.Now()); err != nil {
+			return err
+		}
+		// A node-local volume created without a node stays on the first node to stage it
+		if volume.Node == "" && isNodeLocal(volume.Backend) {
+			volume.Node = req.NodeID
+		}
+		return nil
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
 	}
 
 	h.logger.Info("volume staged", "volume_id", id, "node_id", req.NodeID)
@@ -256,7 +858,17 @@ func (h *VolumeHandler) HandleStage(c echo.Context) error {
 	return c.JSON(http.StatusOK, volume)
 }
 
+// isNodeLocal reports whether the volumes of a backend live on a single node
+func isNodeLocal(backendName string) bool {
+	backend, err := storage.GetController(backendName)
+	if err != nil {
+		return false
+	}
+	return backend.Capabilities().NodeLocal
+}
+
 // HandlePublish handles POST /api/v1/volumes/:id/publish
+// It records that a node has published the volume, or failed to; the node plugin performs the mount itself.
 func (h *VolumeHandler) HandlePublish(c echo.Context) error {
 	id := c.Param("id")
 
@@ -270,50 +882,168 @@ func (h *VolumeHandler) HandlePublish(c echo.Context) error {
 
 	req.VolumeID = id
 
-	// Get volume
-	volume, err := h.store.GetVolume(c.Request().Context(), id)
-	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			return c.JSON(http.StatusNotFound, types.ErrorResponse{
-				Error:   "not_found",
-				Message: "Volume not found",
-			})
+	if req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Node ID is required",
+		})
+	}
+
+	if req.TargetPath == "" && req.Error == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Target path is required",
+		})
+	}
+
+	// Record the node's transition
+	volume, err := store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(volume *types.Volume) error {
+		if req.Error != "" {
+			state.Fail(volume, req.NodeID, req.Error, time.Now())
+			return nil
 		}
-		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error:   "internal_error",
-			Message: "Failed to get volume",
+		return state.Publish(volume, req.NodeID, req.TargetPath, req.ReadOnly, time.Now())
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Info("volume published", "volume_id", id, "node_id", req.NodeID)
+
+	return c.JSON(http.StatusOK, volume)
+}
+
+// HandleCondition handles POST /api/v1/volumes/:id/condition
+// It records the result of a health check of the volume on a node.
+func (h *VolumeHandler) HandleCondition(c echo.Context) error {
+	id := c.Param("id")
+
+	var req types.VolumeConditionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
 		})
 	}
 
-	// Get backend
-	backend, err := storage.GetBackend(volume.Backend)
+	if req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Node ID is required",
+		})
+	}
+
+	condition := types.VolumeCondition{Abnormal: req.Abnormal, Message: req.Message}
+	volume, err := store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(volume *types.Volume) error {
+		state.SetCondition(volume, req.NodeID, condition, time.Now())
+		return nil
+	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error:   "internal_error",
-			Message: "Failed to get backend",
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Info("volume condition reported", "volume_id", id, "node_id", req.NodeID, "abnormal", req.Abnormal, "message", req.Message)
+
+	return c.JSON(http.StatusOK, volume)
+}
+
+// HandleUnstage handles DELETE /api/v1/volumes/:id/stage
+// It records that a node has unstaged the volume.
+func (h *VolumeHandler) HandleUnstage(c echo.Context) error {
+	id := c.Param("id")
+
+	var req types.UnstageVolumeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
 		})
 	}
 
-	// Publish volume (staging path not used for local backend)
-	stagingPath := ""
-	if err := backend.Publish(c.Request().Context(), volume, stagingPath, req.TargetPath, req.ReadOnly); err != nil {
-		h.logger.Error("failed to publish volume", "error", err, "volume_id", id)
-		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error:   "publish_failed",
-			Message: err.Error(),
+	req.VolumeID = id
+
+	if req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Node ID is required",
 		})
 	}
 
-	// Update volume status
-	volume.Status = types.VolumeStatusPublished
-	volume.PublishedOn = append(volume.PublishedOn, req.NodeID)
-	volume.UpdatedAt = time.Now()
+	// Record the node's transition
+	volume, err := store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(volume *types.Volume) error {
+		return state.Unstage(volume, req.NodeID, time.Now())
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Info("volume unstaged", "volume_id", id, "node_id", req.NodeID)
+
+	return c.JSON(http.StatusOK, volume)
+}
+
+// HandleUnpublish handles DELETE /api/v1/volumes/:id/publish
+// It records that a node has unpublished the volume.
+func (h *VolumeHandler) HandleUnpublish(c echo.Context) error {
+	id := c.Param("id")
 
-	if err := h.store.UpdateVolume(c.Request().Context(), volume); err != nil {
-		h.logger.Error("failed to update volume", "error", err)
+	var req types.UnpublishVolumeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+		})
 	}
 
-	h.logger.Info("volume published", "volume_id", id, "node_id", req.NodeID)
+	req.VolumeID = id
+
+	if req.NodeID == "" {
+		return c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Node ID is required",
+		})
+	}
+
+	// Record the node's transition
+	volume, err := store.UpdateVolumeWithRetry(c.Request().Context(), h.store, id, func(volume *types.Volume) error {
+		return state.Unpublish(volume, req.NodeID, req.TargetPath, time.Now())
+	})
+	if err != nil {
+		return h.updateErrorResponse(c, err, id)
+	}
+
+	h.logger.Info("volume unpublished", "volume_id", id, "node_id", req.NodeID)
 
 	return c.JSON(http.StatusOK, volume)
 }
+
+// updateErrorResponse maps an error from a volume update to an HTTP response.
+// Rejected state transitions and lost update races are reported as 409 Conflict.
+func (h *VolumeHandler) updateErrorResponse(c echo.Context, err error, id string) error {
+	var transitionErr *state.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		return c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:   "invalid_transition",
+			Message: transitionErr.Error(),
+			Code:    transitionErr.Code,
+		})
+	case errors.Is(err, store.ErrNotFound):
+		return c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "not_found",
+			Message: "Volume not found",
+		})
+	case errors.Is(err, store.ErrConflict):
+		return c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:   "conflict",
+			Message: err.Error(),
+			Code:    "conflict",
+		})
+	default:
+		h.logger.Error("failed to update volume", "error", err, "volume_id", id)
+		return c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to update volume",
+		})
+	}
+}
@@ -11,29 +11,33 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sistemica/docker-volume-manager/pkg/api/handlers"
 	custommw "github.com/sistemica/docker-volume-manager/pkg/api/middleware"
+	"github.com/sistemica/docker-volume-manager/pkg/backup"
 	"github.com/sistemica/docker-volume-manager/pkg/config"
 	"github.com/sistemica/docker-volume-manager/pkg/store"
 )
 
 // Server represents the HTTP API server
 type Server struct {
-	echo   *echo.Echo
-	config *config.Config
-	logger *slog.Logger
-	store  store.Store
+	echo    *echo.Echo
+	config  *config.Config
+	logger  *slog.Logger
+	store   store.Store
+	backups *backup.Manager
 }
 
-// NewServer creates a new API server
-func NewServer(cfg *config.Config, store store.Store, logger *slog.Logger) *Server {
+// NewServer creates a new API server. backups may be nil when the store
+// does not support snapshots.
+func NewServer(cfg *config.Config, store store.Store, backups *backup.Manager, logger *slog.Logger) *Server {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 
 	s := &Server{
-		echo:   e,
-		config: cfg,
-		logger: logger,
-		store:  store,
+		echo:    e,
+		config:  cfg,
+		logger:  logger,
+		store:   store,
+		backups: backups,
 	}
 
 	s.setupMiddleware()
@@ -53,14 +57,17 @@ func (s *Server) setupMiddleware() {
 	// CORS middleware
 	s.echo.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
 	}))
 
 	// Request ID middleware
 	s.echo.Use(middleware.RequestID())
 
-	// Timeout middleware
+	// Timeout middleware (watch streams are long-lived and archives can be large)
 	s.echo.Use(middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+		Skipper: func(c echo.Context) bool {
+			return handlers.IsWatchRequest(c) || handlers.IsArchiveRequest(c)
+		},
 		Timeout: 30 * time.Second,
 	}))
 }
@@ -80,19 +87,57 @@ func (s *Server) setupRoutes() {
 	v1.POST("/volumes", volumeHandler.HandleCreate)
 	v1.GET("/volumes", volumeHandler.HandleList)
 	v1.GET("/volumes/:id", volumeHandler.HandleGet)
+	v1.PATCH("/volumes/:id", volumeHandler.HandleUpdate)
+	v1.POST("/volumes/:id/refresh", volumeHandler.HandleRefresh)
+	v1.GET("/volumes/:id/usage", volumeHandler.HandleUsage)
+	v1.POST("/volumes/:id/usage", volumeHandler.HandleReportUsage)
 	v1.DELETE("/volumes/:id", volumeHandler.HandleDelete)
 	v1.POST("/volumes/:id/stage", volumeHandler.HandleStage)
+	v1.DELETE("/volumes/:id/stage", volumeHandler.HandleUnstage)
 	v1.POST("/volumes/:id/publish", volumeHandler.HandlePublish)
+	v1.DELETE("/volumes/:id/publish", volumeHandler.HandleUnpublish)
+	v1.POST("/volumes/:id/condition", volumeHandler.HandleCondition)
+
+	// Snapshot routes
+	snapshotHandler := handlers.NewSnapshotHandler(s.store, s.logger)
+	v1.POST("/volumes/:id/snapshots", snapshotHandler.HandleCreate)
+	v1.GET("/volumes/:id/snapshots", snapshotHandler.HandleList)
+	v1.GET("/volumes/:id/snapshots/:snapshot_id", snapshotHandler.HandleGet)
+	v1.DELETE("/volumes/:id/snapshots/:snapshot_id", snapshotHandler.HandleDelete)
+	v1.GET("/snapshots", snapshotHandler.HandleList)
+	v1.GET("/snapshots/:snapshot_id", snapshotHandler.HandleGet)
+	v1.DELETE("/snapshots/:snapshot_id", snapshotHandler.HandleDelete)
+	v1.POST("/snapshots/:snapshot_id/created", snapshotHandler.HandleCreated)
+	v1.POST("/snapshots/:snapshot_id/deleted", snapshotHandler.HandleDeleted)
+
+	// Node routes (registration of CSI nodes and their topology)
+	nodeHandler := handlers.NewNodeHandler(s.store, s.logger)
+	v1.PUT("/nodes/:id", nodeHandler.HandleRegister)
+	v1.GET("/nodes", nodeHandler.HandleList)
+
+	// Archive routes (content of archive-seeded volumes)
+	archiveHandler := handlers.NewArchiveHandler(s.store, s.logger)
+	v1.PUT("/volumes/:id/archive", archiveHandler.HandleUpload)
+	v1.GET("/volumes/:id/archive", archiveHandler.HandleDownload)
+	v1.DELETE("/backends/:name/uploads/:id", archiveHandler.HandleDeleteUpload)
 
 	// File operations routes (RESTful - files as resources)
 	fileHandler := handlers.NewFileHandler(s.store, s.logger)
-	v1.GET("/volumes/:id/files/*", fileHandler.HandleGet)         // Read file or list directory
-	v1.PUT("/volumes/:id/files/*", fileHandler.HandlePut)         // Create/update file
-	v1.DELETE("/volumes/:id/files/*", fileHandler.HandleDelete)   // Delete file/directory
+	v1.GET("/volumes/:id/files/*", fileHandler.HandleGet)       // Read file or list directory
+	v1.PUT("/volumes/:id/files/*", fileHandler.HandlePut)       // Create/update file
+	v1.DELETE("/volumes/:id/files/*", fileHandler.HandleDelete) // Delete file/directory
 
 	// Backend routes
 	backendHandler := handlers.NewBackendHandler(s.logger)
 	v1.GET("/backends", backendHandler.HandleList)
+	v1.GET("/backends/:name/capacity", backendHandler.HandleCapacity)
+
+	// Admin routes
+	adminHandler := handlers.NewAdminHandler(s.store, s.backups, s.logger)
+	v1.GET("/admin/cluster/members", adminHandler.HandleListMembers)
+	v1.POST("/admin/snapshots", adminHandler.HandleCreateSnapshot)
+	v1.GET("/admin/snapshots", adminHandler.HandleListSnapshots)
+	v1.GET("/admin/snapshots/:name", adminHandler.HandleDownloadSnapshot)
 }
 
 // Start starts the HTTP server
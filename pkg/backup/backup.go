@@ -0,0 +1,209 @@
+// Package backup writes periodic snapshots of the metadata store to disk and
+// prunes old ones.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sistemica/docker-volume-manager/pkg/store"
+)
+
+const (
+	filePrefix = "snapshot-"
+	fileSuffix = ".db"
+
+	// timeFormat sorts lexically in creation order
+	timeFormat = "20060102T150405.000Z"
+)
+
+// ErrNotFound is returned when a snapshot file does not exist
+var ErrNotFound = errors.New("snapshot not found")
+
+// Info describes a snapshot file
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manager creates, lists and prunes snapshots in a directory
+type Manager struct {
+	store     store.SnapshotStore
+	dir       string
+	retention int
+	logger    *slog.Logger
+	mu        sync.Mutex // one snapshot at a time
+}
+
+// NewManager creates a snapshot manager writing to dir and keeping the newest
+// retention snapshots (0 keeps all)
+func NewManager(s store.SnapshotStore, dir string, retention int, logger *slog.Logger) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory %s: %w", dir, err)
+	}
+
+	return &Manager{
+		store:     s,
+		dir:       dir,
+		retention: retention,
+		logger:    logger.With("component", "backup"),
+	}, nil
+}
+
+// Run takes a snapshot every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	m.logger.Info("scheduled snapshots enabled", "dir", m.dir, "interval", interval, "retention", m.retention)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Create(ctx); err != nil {
+				m.logger.Error("scheduled snapshot failed", "error", err)
+			}
+		}
+	}
+}
+
+// Create writes a new snapshot and prunes old ones beyond the retention
+func (m *Manager) Create(ctx context.Context) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	createdAt := time.Now().UTC()
+	name := filePrefix + createdAt.Format(timeFormat) + fileSuffix
+	path := filepath.Join(m.dir, name)
+
+	// Write to a temporary name so listings never show a partial snapshot
+	tmp, err := os.CreateTemp(m.dir, ".partial-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := m.store.Snapshot(ctx, tmp); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to snapshot store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+
+	info := &Info{Name: name, Size: stat.Size(), CreatedAt: createdAt}
+	m.logger.Info("snapshot created", "name", name, "size", info.Size)
+
+	m.prune()
+	return info, nil
+}
+
+// List returns all snapshots, newest first
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	snapshots := make([]Info, 0, len(entries))
+	for _, entry := range entries {
+		createdAt, ok := parseName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Info{Name: entry.Name(), Size: stat.Size(), CreatedAt: createdAt})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name > snapshots[j].Name })
+	return snapshots, nil
+}
+
+// Open opens a snapshot for reading by name
+func (m *Manager) Open(name string) (*os.File, *Info, error) {
+	createdAt, ok := parseName(name)
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(m.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+
+	return f, &Info{Name: name, Size: stat.Size(), CreatedAt: createdAt}, nil
+}
+
+// prune removes the oldest snapshots beyond the retention; callers hold m.mu
+func (m *Manager) prune() {
+	if m.retention <= 0 {
+		return
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		m.logger.Warn("failed to list snapshots for pruning", "error", err)
+		return
+	}
+
+	for i := m.retention; i < len(snapshots); i++ {
+		if err := os.Remove(filepath.Join(m.dir, snapshots[i].Name)); err != nil {
+			m.logger.Warn("failed to remove old snapshot", "name", snapshots[i].Name, "error", err)
+			continue
+		}
+		m.logger.Debug("removed old snapshot", "name", snapshots[i].Name)
+	}
+}
+
+// parseName validates a snapshot file name and returns its creation time.
+// Only names produced by Create are accepted, which also rules out paths.
+func parseName(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, fileSuffix)
+	if !ok {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(timeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
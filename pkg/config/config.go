@@ -3,11 +3,21 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Metadata store types
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+	StoreEtcd   = "etcd"
+)
+
 // Config holds the application configuration
 type Config struct {
 	// Server configuration
@@ -17,15 +27,37 @@ type Config struct {
 	LogLevel    string `json:"log_level"`
 
 	// Storage configuration
-	DataDir string `json:"data_dir"`
+	DataDir   string `json:"data_dir"`
+	StoreType string `json:"store_type"`
+
+	// Metadata snapshot configuration
+	SnapshotDir       string        `json:"snapshot_dir"`
+	SnapshotInterval  time.Duration `json:"snapshot_interval"`
+	SnapshotRetention int           `json:"snapshot_retention"`
 
 	// Etcd configuration
-	EtcdEnabled bool   `json:"etcd_enabled"`
-	ClusterSize int    `json:"cluster_size"`
-	ServiceName string `json:"service_name"`
-	TaskSlot    int    `json:"task_slot"`
-	EtcdClientPort int `json:"etcd_client_port"`
-	EtcdPeerPort   int `json:"etcd_peer_port"`
+	EtcdEnabled    bool   `json:"etcd_enabled"`
+	ClusterSize    int    `json:"cluster_size"`
+	ServiceName    string `json:"service_name"`
+	TaskSlot       int    `json:"task_slot"`
+	EtcdClientPort int    `json:"etcd_client_port"`
+	EtcdPeerPort   int    `json:"etcd_peer_port"`
+
+	// External etcd configuration (set EtcdEndpoints to skip the embedded server)
+	EtcdEndpoints []string `json:"etcd_endpoints"`
+	EtcdCertFile  string   `json:"etcd_cert_file"`
+	EtcdKeyFile   string   `json:"etcd_key_file"`
+	EtcdCAFile    string   `json:"etcd_ca_file"`
+	EtcdUsername  string   `json:"etcd_username"`
+	EtcdPassword  string   `json:"-"`
+	EtcdKeyPrefix string   `json:"etcd_key_prefix"`
+
+	// Embedded etcd TLS configuration
+	EtcdTLSMode      string `json:"etcd_tls_mode"`
+	EtcdTLSCertFile  string `json:"etcd_tls_cert_file"`
+	EtcdTLSKeyFile   string `json:"etcd_tls_key_file"`
+	EtcdTLSCAFile    string `json:"etcd_tls_ca_file"`
+	EtcdTLSCAKeyFile string `json:"etcd_tls_ca_key_file"`
 }
 
 // Load loads configuration from environment variables
@@ -33,20 +65,51 @@ func Load() (*Config, error) {
 	// Try to load .env file (ignore error if not exists)
 	_ = godotenv.Load()
 
+	snapshotInterval, err := getEnvDuration("SNAPSHOT_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	cfg := &Config{
-		Port:           getEnvInt("PORT", 9789),
-		Host:           getEnv("HOST", "0.0.0.0"),
-		Environment:    getEnv("ENVIRONMENT", "development"),
-		LogLevel:       getEnv("LOG_LEVEL", "info"),
-		DataDir:        getEnv("DATA_DIR", "/var/lib/volume-manager"),
+		Port:        getEnvInt("PORT", 9789),
+		Host:        getEnv("HOST", "0.0.0.0"),
+		Environment: getEnv("ENVIRONMENT", "development"),
+		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		DataDir:     getEnv("DATA_DIR", "/var/lib/volume-manager"),
+
+		SnapshotInterval:  snapshotInterval,
+		SnapshotRetention: getEnvInt("SNAPSHOT_RETENTION", 24),
+
 		EtcdEnabled:    getEnvBool("ETCD_ENABLED", false),
 		ClusterSize:    getEnvInt("CLUSTER_SIZE", 1),
 		ServiceName:    getEnv("SERVICE_NAME", "volume-manager"),
 		TaskSlot:       getEnvInt("TASK_SLOT", 1),
 		EtcdClientPort: getEnvInt("ETCD_CLIENT_PORT", 2379),
 		EtcdPeerPort:   getEnvInt("ETCD_PEER_PORT", 2380),
+		EtcdEndpoints:  getEnvList("ETCD_ENDPOINTS"),
+		EtcdCertFile:   getEnv("ETCD_CERT_FILE", ""),
+		EtcdKeyFile:    getEnv("ETCD_KEY_FILE", ""),
+		EtcdCAFile:     getEnv("ETCD_CA_FILE", ""),
+		EtcdUsername:   getEnv("ETCD_USERNAME", ""),
+		EtcdPassword:   getEnv("ETCD_PASSWORD", ""),
+		EtcdKeyPrefix:  getEnv("ETCD_KEY_PREFIX", ""),
+
+		EtcdTLSMode:      getEnv("ETCD_TLS_MODE", "none"),
+		EtcdTLSCertFile:  getEnv("ETCD_TLS_CERT_FILE", ""),
+		EtcdTLSKeyFile:   getEnv("ETCD_TLS_KEY_FILE", ""),
+		EtcdTLSCAFile:    getEnv("ETCD_TLS_CA_FILE", ""),
+		EtcdTLSCAKeyFile: getEnv("ETCD_TLS_CA_KEY_FILE", ""),
 	}
 
+	// STORE_TYPE takes precedence; ETCD_ENABLED keeps selecting etcd for older deployments
+	defaultStore := StoreMemory
+	if cfg.EtcdEnabled || len(cfg.EtcdEndpoints) > 0 {
+		defaultStore = StoreEtcd
+	}
+	cfg.StoreType = getEnv("STORE_TYPE", defaultStore)
+	cfg.EtcdEnabled = cfg.StoreType == StoreEtcd
+	cfg.SnapshotDir = getEnv("SNAPSHOT_DIR", filepath.Join(cfg.DataDir, "snapshots"))
+
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -64,6 +127,43 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %s", c.LogLevel)
 	}
 
+	switch c.StoreType {
+	case StoreMemory, StoreFile, StoreEtcd:
+	default:
+		return fmt.Errorf("invalid store type: %s (expected %s, %s or %s)", c.StoreType, StoreMemory, StoreFile, StoreEtcd)
+	}
+
+	if (c.EtcdCertFile == "") != (c.EtcdKeyFile == "") {
+		return fmt.Errorf("ETCD_CERT_FILE and ETCD_KEY_FILE must be set together")
+	}
+
+	if c.EtcdKeyPrefix != "" && !strings.HasPrefix(c.EtcdKeyPrefix, "/") {
+		return fmt.Errorf("invalid etcd key prefix: %s (must start with /)", c.EtcdKeyPrefix)
+	}
+
+	if c.SnapshotInterval < 0 || c.SnapshotRetention < 0 {
+		return fmt.Errorf("SNAPSHOT_INTERVAL and SNAPSHOT_RETENTION must not be negative")
+	}
+
+	switch c.EtcdTLSMode {
+	case "none", "auto":
+	case "files":
+		if c.EtcdTLSCertFile == "" || c.EtcdTLSKeyFile == "" || c.EtcdTLSCAFile == "" {
+			return fmt.Errorf("ETCD_TLS_MODE=files requires ETCD_TLS_CERT_FILE, ETCD_TLS_KEY_FILE and ETCD_TLS_CA_FILE")
+		}
+	case "ca":
+		if (c.EtcdTLSCAFile == "") != (c.EtcdTLSCAKeyFile == "") {
+			return fmt.Errorf("ETCD_TLS_CA_FILE and ETCD_TLS_CA_KEY_FILE must be set together")
+		}
+		// A generated CA lives in the DATA_DIR of one task and is not shared
+		// with the others, so their certificates would not trust each other
+		if c.EtcdTLSCAFile == "" && c.ClusterSize > 1 {
+			return fmt.Errorf("ETCD_TLS_MODE=ca with CLUSTER_SIZE %d needs a shared CA: set ETCD_TLS_CA_FILE and ETCD_TLS_CA_KEY_FILE", c.ClusterSize)
+		}
+	default:
+		return fmt.Errorf("invalid etcd TLS mode: %s (expected none, files, auto or ca)", c.EtcdTLSMode)
+	}
+
 	return nil
 }
 
@@ -85,6 +185,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList gets a comma-separated environment variable as a slice
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // getEnvInt gets an integer environment variable or returns a default value
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -95,6 +206,21 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvDuration gets a duration environment variable (e.g. "30m") or returns
+// a default value. A value that is not a duration is an error rather than
+// silently replaced by the default.
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q is not a duration (e.g. 30m or 1h)", key, value)
+	}
+	return duration, nil
+}
+
 // getEnvBool gets a boolean environment variable or returns a default value
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
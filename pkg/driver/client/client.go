@@ -1,18 +1,28 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
 
+// maxWatchEventSize bounds a single event of a volume watch stream
+const maxWatchEventSize = 4 << 20
+
+// ErrWatchExpired is returned by WatchVolumes when the revision to resume
+// from is no longer retained; the caller starts over from revision 0
+var ErrWatchExpired = errors.New("watch revision expired")
+
 // VolumeManagerClient is an HTTP client for the Volume Manager REST API
 type VolumeManagerClient struct {
 	baseURL    string
@@ -31,13 +41,27 @@ func NewVolumeManagerClient(baseURL string, logger *slog.Logger) *VolumeManagerC
 	}
 }
 
-// CreateVolume creates a new volume
-func (c *VolumeManagerClient) CreateVolume(ctx context.Context, name, backend string, parameters map[string]string) (*types.Volume, error) {
+// CreateVolume creates a new volume limited to capacityBytes (0 for no
+// limit), populated from source if it is not nil, pinned to node if it is
+// not empty and restricted to accessMode if it is not empty
+func (c *VolumeManagerClient) CreateVolume(ctx context.Context, name, backend string, parameters map[string]string, capacityBytes int64, source *types.VolumeSource, node string, accessMode types.AccessMode) (*types.Volume, error) {
 	req := map[string]interface{}{
 		"name":       name,
 		"backend":    backend,
 		"parameters": parameters,
 	}
+	if capacityBytes > 0 {
+		req["capacity_bytes"] = capacityBytes
+	}
+	if source != nil {
+		req["source"] = source
+	}
+	if node != "" {
+		req["node"] = node
+	}
+	if accessMode != "" {
+		req["access_mode"] = accessMode
+	}
 
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -59,8 +83,7 @@ func (c *VolumeManagerClient) CreateVolume(ctx context.Context, name, backend st
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return nil, responseError(resp)
 	}
 
 	var volume types.Volume
@@ -85,13 +108,45 @@ func (c *VolumeManagerClient) GetVolume(ctx context.Context, volumeID string) (*
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("volume not found")
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var volume types.Volume
+	if err := json.NewDecoder(resp.Body).Decode(&volume); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	return &volume, nil
+}
+
+// ExpandVolume grows a volume to capacityBytes
+func (c *VolumeManagerClient) ExpandVolume(ctx context.Context, volumeID string, capacityBytes int64) (*types.Volume, error) {
+	req := map[string]int64{
+		"capacity_bytes": capacityBytes,
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/volumes/%s", c.baseURL, volumeID)
+	httpReq, err := http.NewRequestWithContext(ctx, "PATCH", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return nil, responseError(resp)
 	}
 
 	var volume types.Volume
@@ -102,9 +157,9 @@ func (c *VolumeManagerClient) GetVolume(ctx context.Context, volumeID string) (*
 	return &volume, nil
 }
 
-// ListVolumes lists all volumes
-func (c *VolumeManagerClient) ListVolumes(ctx context.Context) ([]*types.Volume, error) {
-	url := fmt.Sprintf("%s/api/v1/volumes", c.baseURL)
+// GetVolumeUsage retrieves the space and inodes used by a volume
+func (c *VolumeManagerClient) GetVolumeUsage(ctx context.Context, volumeID string) (*types.VolumeUsage, error) {
+	url := fmt.Sprintf("%s/api/v1/volumes/%s/usage", c.baseURL, volumeID)
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -117,19 +172,107 @@ func (c *VolumeManagerClient) ListVolumes(ctx context.Context) ([]*types.Volume,
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return nil, responseError(resp)
+	}
+
+	var usage types.VolumeUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &usage, nil
+}
+
+// GetCapacity retrieves the bytes a backend has available for new volumes
+// created with parameters
+func (c *VolumeManagerClient) GetCapacity(ctx context.Context, backend string, parameters map[string]string) (int64, error) {
+	query := url.Values{}
+	for key, value := range parameters {
+		query.Set(key, value)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/backends/%s/capacity?%s", c.baseURL, backend, query.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError(resp)
 	}
 
 	var response struct {
-		Count   int              `json:"count"`
-		Volumes []*types.Volume `json:"volumes"`
+		AvailableBytes int64 `json:"available_bytes"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.AvailableBytes, nil
+}
+
+// ListVolumes lists the volumes matching filter, at most limit of them if it
+// is positive, continuing a previous listing if continueToken is not empty.
+// It returns the continue token of the next page, empty on the last page.
+func (c *VolumeManagerClient) ListVolumes(ctx context.Context, filter types.VolumeFilter, limit int, continueToken string) ([]*types.Volume, string, error) {
+	query := url.Values{}
+	if filter.Name != "" {
+		query.Set("name", filter.Name)
+	}
+	if filter.Backend != "" {
+		query.Set("backend", filter.Backend)
+	}
+	if filter.Status != "" {
+		query.Set("status", string(filter.Status))
+	}
+	if filter.Node != "" {
+		query.Set("node", filter.Node)
+	}
+	for key, value := range filter.Labels {
+		if value != "" {
+			key += "=" + value
+		}
+		query.Add("label", key)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if continueToken != "" {
+		query.Set("continue", continueToken)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/volumes?%s", c.baseURL, query.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", responseError(resp)
 	}
 
-	return response.Volumes, nil
+	var response struct {
+		Count    int             `json:"count"`
+		Volumes  []*types.Volume `json:"volumes"`
+		Continue string          `json:"continue"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Volumes, response.Continue, nil
 }
 
 // DeleteVolume deletes a volume
@@ -146,23 +289,20 @@ func (c *VolumeManagerClient) DeleteVolume(ctx context.Context, volumeID string)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("volume not found")
-	}
-
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
 	}
 
 	return nil
 }
 
-// StageVolume stages a volume on a node
-func (c *VolumeManagerClient) StageVolume(ctx context.Context, volumeID, stagingPath, nodeID string) error {
-	req := map[string]string{
+// StageVolume records that a volume is staged on a node with the content of
+// the given volume generation
+func (c *VolumeManagerClient) StageVolume(ctx context.Context, volumeID, stagingPath, nodeID string, generation int64) error {
+	req := map[string]interface{}{
 		"staging_path": stagingPath,
 		"node_id":      nodeID,
+		"generation":   generation,
 	}
 
 	data, err := json.Marshal(req)
@@ -185,17 +325,17 @@ func (c *VolumeManagerClient) StageVolume(ctx context.Context, volumeID, staging
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
 	}
 
 	return nil
 }
 
 // UnstageVolume unstages a volume from a node
-func (c *VolumeManagerClient) UnstageVolume(ctx context.Context, volumeID, stagingPath string) error {
+func (c *VolumeManagerClient) UnstageVolume(ctx context.Context, volumeID, stagingPath, nodeID string) error {
 	req := map[string]string{
 		"staging_path": stagingPath,
+		"node_id":      nodeID,
 	}
 
 	data, err := json.Marshal(req)
@@ -218,18 +358,18 @@ func (c *VolumeManagerClient) UnstageVolume(ctx context.Context, volumeID, stagi
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
 	}
 
 	return nil
 }
 
 // PublishVolume publishes (mounts) a volume
-func (c *VolumeManagerClient) PublishVolume(ctx context.Context, volumeID, stagingPath, targetPath string, readOnly bool) error {
+func (c *VolumeManagerClient) PublishVolume(ctx context.Context, volumeID, stagingPath, targetPath, nodeID string, readOnly bool) error {
 	req := map[string]interface{}{
 		"staging_path": stagingPath,
 		"target_path":  targetPath,
+		"node_id":      nodeID,
 		"read_only":    readOnly,
 	}
 
@@ -253,17 +393,17 @@ func (c *VolumeManagerClient) PublishVolume(ctx context.Context, volumeID, stagi
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
 	}
 
 	return nil
 }
 
 // UnpublishVolume unpublishes (unmounts) a volume
-func (c *VolumeManagerClient) UnpublishVolume(ctx context.Context, volumeID, targetPath string) error {
+func (c *VolumeManagerClient) UnpublishVolume(ctx context.Context, volumeID, targetPath, nodeID string) error {
 	req := map[string]string{
 		"target_path": targetPath,
+		"node_id":     nodeID,
 	}
 
 	data, err := json.Marshal(req)
@@ -286,9 +426,415 @@ func (c *VolumeManagerClient) UnpublishVolume(ctx context.Context, volumeID, tar
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// ReportFailure reports that a node failed to stage or publish a volume.
+// operation is "stage" or "publish".
+func (c *VolumeManagerClient) ReportFailure(ctx context.Context, volumeID, operation, nodeID string, cause error) error {
+	req := map[string]string{
+		"node_id": nodeID,
+		"error":   cause.Error(),
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/volumes/%s/%s", c.baseURL, volumeID, operation)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// ReportCondition records the result of a health check of a volume on a
+// node. A nil cause reports the volume healthy.
+func (c *VolumeManagerClient) ReportCondition(ctx context.Context, volumeID, nodeID string, cause error) error {
+	req := types.VolumeConditionRequest{NodeID: nodeID}
+	if cause != nil {
+		req.Abnormal = true
+		req.Message = cause.Error()
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/volumes/%s/condition", c.baseURL, volumeID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// ReportUsage records the usage of a node-local volume measured on the node
+// holding it. A nil usage reports that the node cannot tell.
+func (c *VolumeManagerClient) ReportUsage(ctx context.Context, volumeID, nodeID string, usage *types.VolumeUsage) error {
+	data, err := json.Marshal(types.VolumeUsageRequest{NodeID: nodeID, Usage: usage})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/volumes/%s/usage", c.baseURL, volumeID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// CreateSnapshot snapshots a volume. The snapshot of a node-local volume is
+// returned before its node has copied the data, with ReadyToUse false.
+func (c *VolumeManagerClient) CreateSnapshot(ctx context.Context, volumeID, name string) (*types.Snapshot, error) {
+	req := map[string]string{
+		"name": name,
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/volumes/%s/snapshots", c.baseURL, volumeID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Snapshots of node-local volumes are accepted and taken by their node
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		return nil, responseError(resp)
+	}
+
+	var snapshot types.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// GetSnapshot retrieves a snapshot by ID
+func (c *VolumeManagerClient) GetSnapshot(ctx context.Context, snapshotID string) (*types.Snapshot, error) {
+	url := fmt.Sprintf("%s/api/v1/snapshots/%s", c.baseURL, snapshotID)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var snapshot types.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// ListSnapshots lists snapshots, optionally only those of one source volume
+// or with a given name. Empty arguments do not filter.
+func (c *VolumeManagerClient) ListSnapshots(ctx context.Context, sourceVolumeID, name string) ([]*types.Snapshot, error) {
+	query := url.Values{}
+	if sourceVolumeID != "" {
+		query.Set("source_volume_id", sourceVolumeID)
+	}
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/snapshots?%s", c.baseURL, query.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var response struct {
+		Count     int               `json:"count"`
+		Snapshots []*types.Snapshot `json:"snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Snapshots, nil
+}
+
+// DeleteSnapshot deletes a snapshot. The data of a node-local snapshot is
+// removed by its node after the call returns.
+func (c *VolumeManagerClient) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	url := fmt.Sprintf("%s/api/v1/snapshots/%s", c.baseURL, snapshotID)
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// ListNodeSnapshots lists the snapshots whose data is held by a node
+func (c *VolumeManagerClient) ListNodeSnapshots(ctx context.Context, nodeID string) ([]*types.Snapshot, error) {
+	query := url.Values{}
+	query.Set("node", nodeID)
+
+	url := fmt.Sprintf("%s/api/v1/snapshots?%s", c.baseURL, query.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var response struct {
+		Count     int               `json:"count"`
+		Snapshots []*types.Snapshot `json:"snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Snapshots, nil
+}
+
+// ReportSnapshotResult reports that a node created or deleted the data of a
+// snapshot, or failed to. operation is "created" or "deleted".
+func (c *VolumeManagerClient) ReportSnapshotResult(ctx context.Context, snapshotID, operation string, result types.SnapshotResultRequest) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/snapshots/%s/%s", c.baseURL, snapshotID, operation)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
 	}
 
 	return nil
 }
+
+// RegisterNode records a node with the topology segments it is reachable in
+func (c *VolumeManagerClient) RegisterNode(ctx context.Context, nodeID string, topology map[string]string) error {
+	data, err := json.Marshal(types.RegisterNodeRequest{Topology: topology})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/nodes/%s", c.baseURL, nodeID)
+	httpReq, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// ListNodes lists the registered nodes
+func (c *VolumeManagerClient) ListNodes(ctx context.Context) ([]*types.Node, error) {
+	url := fmt.Sprintf("%s/api/v1/nodes", c.baseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var response struct {
+		Count int           `json:"count"`
+		Nodes []*types.Node `json:"nodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Nodes, nil
+}
+
+// WatchVolumes streams the volume changes after fromRevision to fn until ctx
+// is done, the stream ends or fn returns an error. With fromRevision 0 the
+// stream starts with every existing volume as an ADDED event. It returns the
+// revision of the last event passed to fn, from which the caller can resume,
+// and ErrWatchExpired if fromRevision is no longer retained.
+func (c *VolumeManagerClient) WatchVolumes(ctx context.Context, fromRevision int64, fn func(event *types.VolumeEvent) error) (int64, error) {
+	url := fmt.Sprintf("%s/api/v1/volumes?watch=true", c.baseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fromRevision, fmt.Errorf("failed to create request: %w", err)
+	}
+	if fromRevision > 0 {
+		httpReq.Header.Set("Last-Event-ID", strconv.FormatInt(fromRevision, 10))
+	}
+
+	// The stream stays open, so the request timeout of httpClient does not apply
+	watchClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := watchClient.Do(httpReq)
+	if err != nil {
+		return fromRevision, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return fromRevision, ErrWatchExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fromRevision, responseError(resp)
+	}
+
+	revision := fromRevision
+	var data []byte
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxWatchEventSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		// A blank line ends an event; comment lines are heartbeats
+		if len(line) == 0 {
+			if len(data) == 0 {
+				continue
+			}
+			var event types.VolumeEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				return revision, fmt.Errorf("failed to decode event: %w", err)
+			}
+			data = data[:0]
+
+			if err := fn(&event); err != nil {
+				return revision, err
+			}
+			revision = event.Revision
+			continue
+		}
+		if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimPrefix(payload, []byte(" "))...)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return revision, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// Errors returned by the client, matched with errors.Is against the *Error
+// of a failed request
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrAlreadyExists   = errors.New("already exists")
+	ErrSourceNotFound  = errors.New("source not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrNotSupported    = errors.New("not supported")
+	ErrInvalidContinue = errors.New("invalid continue token")
+
+	// ErrAccessModeConflict is returned by PublishVolume when the access
+	// mode of the volume does not allow the publish
+	ErrAccessModeConflict = errors.New("access mode conflict")
+
+	// ErrVolumeNotPinned is returned by CreateSnapshot when a node-local
+	// volume has not been staged yet, so no node holds its data
+	ErrVolumeNotPinned = errors.New("volume not pinned to a node")
+
+	// ErrSnapshotNotReady is returned by CreateVolume when the snapshot to
+	// restore is still being taken or removed
+	ErrSnapshotNotReady = errors.New("snapshot not ready")
+
+	// ErrVolumeInUse is returned by DeleteVolume while the volume is
+	// published on a node
+	ErrVolumeInUse = errors.New("volume in use")
+)
+
+// codeErrors maps the codes of error responses to the errors they match
+var codeErrors = map[string]error{
+	"not_found":            ErrNotFound,
+	"already_exists":       ErrAlreadyExists,
+	"source_not_found":     ErrSourceNotFound,
+	"validation_error":     ErrInvalidArgument,
+	"invalid_request":      ErrInvalidArgument,
+	"not_supported":        ErrNotSupported,
+	"shrink_not_supported": ErrNotSupported,
+	"access_mode_conflict": ErrAccessModeConflict,
+	"invalid_continue":     ErrInvalidContinue,
+	"volume_not_pinned":    ErrVolumeNotPinned,
+	"snapshot_not_ready":   ErrSnapshotNotReady,
+	"volume_in_use":        ErrVolumeInUse,
+}
+
+// Error is an error response of the Volume Manager. Code is the code of the
+// response, or its error if it has no code.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("unexpected status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is reports whether the code of the response maps to target
+func (e *Error) Is(target error) bool {
+	err, ok := codeErrors[e.Code]
+	return ok && err == target
+}
+
+// responseError returns the error for a response with an unexpected status
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	var errResp types.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil || (errResp.Error == "" && errResp.Message == "") {
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	code := errResp.Code
+	if code == "" {
+		code = errResp.Error
+	}
+	return &Error{StatusCode: resp.StatusCode, Code: code, Message: errResp.Message}
+}
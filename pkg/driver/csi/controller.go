@@ -2,14 +2,21 @@ package csi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"strconv"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/sistemica/docker-volume-manager/pkg/driver/client"
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
 
 // ControllerServer implements the CSI Controller service
@@ -50,26 +57,229 @@ func (s *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		}
 	}
 
-	s.logger.Info("creating volume", "name", volumeName, "backend", backend, "parameters", parameters)
+	// A volume gets the required size, or the limit if only that is set
+	var capacityBytes int64
+	if capacityRange := req.GetCapacityRange(); capacityRange != nil {
+		required, limit := capacityRange.GetRequiredBytes(), capacityRange.GetLimitBytes()
+		if required < 0 || limit < 0 || (limit > 0 && required > limit) {
+			return nil, status.Errorf(codes.OutOfRange, "invalid capacity range: required %d, limit %d", required, limit)
+		}
+		capacityBytes = required
+		if capacityBytes == 0 {
+			capacityBytes = limit
+		}
+	}
 
-	// Call Volume Manager to create the volume
-	volume, err := s.client.CreateVolume(ctx, volumeName, backend, parameters)
+	// Populate the volume from another volume or a snapshot if requested
+	var source *types.VolumeSource
+	if contentSource := req.GetVolumeContentSource(); contentSource != nil {
+		switch {
+		case contentSource.GetVolume() != nil:
+			source = &types.VolumeSource{VolumeID: contentSource.GetVolume().GetVolumeId()}
+		case contentSource.GetSnapshot() != nil:
+			source = &types.VolumeSource{SnapshotID: contentSource.GetSnapshot().GetSnapshotId()}
+		default:
+			return nil, status.Error(codes.InvalidArgument, "unsupported volume content source")
+		}
+	}
+
+	// The volume keeps the access mode it is created with
+	accessMode, err := volumeAccessMode(req.GetVolumeCapabilities())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to create volume: %v", err)
+		return nil, err
 	}
 
-	s.logger.Info("volume created", "volume_id", volume.ID, "name", volumeName)
+	// Node-local volumes are placed on a node in the topology the
+	// orchestrator asked for; without one they stay on the first node that
+	// stages them
+	var node string
+	var managed []string
+	if controller, err := storage.GetController(backend); err == nil {
+		capabilities := controller.Capabilities()
+		managed = capabilities.ManagedParameters
+		if !capabilities.SupportsAccessMode(accessMode) {
+			return nil, status.Errorf(codes.InvalidArgument, "backend %s does not support access mode %s", backend, accessMode)
+		}
+		if capabilities.NodeLocal {
+			node, err = s.placeVolume(ctx, req.GetAccessibilityRequirements(), source)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s.logger.Info("creating volume", "name", volumeName, "backend", backend, "parameters", parameters, "capacity_bytes", capacityBytes, "source", source, "node", node, "access_mode", accessMode)
+
+	// Call Volume Manager to create the volume
+	volume, err := s.client.CreateVolume(ctx, volumeName, backend, parameters, capacityBytes, source, node, accessMode)
+	switch {
+	case errors.Is(err, client.ErrAlreadyExists):
+		// A retry of an earlier create gets the volume that one created
+		volume, err = s.existingVolume(ctx, volumeName)
+		if err != nil {
+			return nil, err
+		}
+		if volume.Backend != backend || !sameParameters(volume.Parameters, parameters, managed) || volume.CapacityBytes != capacityBytes ||
+			!sameSource(volume.Source, source) || volume.AccessMode != accessMode {
+			return nil, status.Errorf(codes.AlreadyExists, "volume %s already exists with different parameters", volumeName)
+		}
+		s.logger.Info("volume already exists", "volume_id", volume.ID, "name", volumeName)
+	case errors.Is(err, client.ErrSourceNotFound):
+		return nil, status.Errorf(codes.NotFound, "volume content source not found: %v", err)
+	case errors.Is(err, client.ErrSnapshotNotReady):
+		return nil, status.Errorf(codes.Unavailable, "volume content source not ready: %v", err)
+	case errors.Is(err, client.ErrInvalidArgument), errors.Is(err, client.ErrNotSupported):
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create volume: %v", err)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to create volume: %v", err)
+	default:
+		s.logger.Info("volume created", "volume_id", volume.ID, "name", volumeName)
+	}
 
-	// For local filesystem, don't specify topology - volume is accessible from any node
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
-			VolumeId:      volume.ID,
-			CapacityBytes: 0, // Not applicable for local filesystem
-			VolumeContext: parameters,
+			VolumeId:           volume.ID,
+			CapacityBytes:      volume.CapacityBytes, // 0 means unlimited
+			VolumeContext:      parameters,
+			ContentSource:      req.GetVolumeContentSource(),
+			AccessibleTopology: accessibleTopology(volume),
 		},
 	}, nil
 }
 
+// placeVolume returns the node a new node-local volume is placed on, chosen
+// from the registered nodes by selectNode. Clones are placed on the node of
+// their source by the manager, so for them it only checks that the node lies
+// in a requisite topology.
+func (s *ControllerServer) placeVolume(ctx context.Context, requirements *csi.TopologyRequirement, source *types.VolumeSource) (string, error) {
+	if !hasTopology(requirements) {
+		return "", nil
+	}
+
+	nodes, err := s.client.ListNodes(ctx)
+	if err != nil {
+		return "", status.Errorf(codes.Unavailable, "failed to list nodes: %v", err)
+	}
+
+	if source != nil {
+		sourceNode := s.sourceNode(ctx, source)
+		if sourceNode != "" && !inRequisite(requirements, nodeSegments(sourceNode, nodes)) {
+			return "", status.Errorf(codes.ResourceExhausted, "volume content source lives on node %s, outside the requisite topology", sourceNode)
+		}
+		return "", nil
+	}
+
+	node, ok := selectNode(requirements, liveNodes(nodes, time.Now()))
+	if !ok {
+		return "", status.Error(codes.ResourceExhausted, "no registered node satisfies the topology requirements")
+	}
+	return node, nil
+}
+
+// sourceNode returns the node a volume content source lives on, or an empty
+// node if it is not pinned or cannot be read; the manager reports missing
+// sources when the volume is created
+func (s *ControllerServer) sourceNode(ctx context.Context, source *types.VolumeSource) string {
+	if source.SnapshotID != "" {
+		if snapshot, err := s.client.GetSnapshot(ctx, source.SnapshotID); err == nil {
+			return snapshot.Node
+		}
+		return ""
+	}
+	if volume, err := s.client.GetVolume(ctx, source.VolumeID); err == nil {
+		return volume.Node
+	}
+	return ""
+}
+
+// existingVolume returns the volume with the given name
+func (s *ControllerServer) existingVolume(ctx context.Context, name string) (*types.Volume, error) {
+	volumes, _, err := s.client.ListVolumes(ctx, types.VolumeFilter{Name: name}, 0, "")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to look up volume: %v", err)
+	}
+	if len(volumes) > 0 {
+		return volumes[0], nil
+	}
+	// The volume was deleted since the create failed; the caller retries
+	return nil, status.Errorf(codes.Aborted, "volume %s was deleted concurrently", name)
+}
+
+// sameParameters reports whether a volume has exactly the requested
+// parameters, apart from those its backend manages itself
+func sameParameters(volume, requested map[string]string, managed []string) bool {
+	unmanaged := func(params map[string]string) map[string]string {
+		params = maps.Clone(params)
+		for _, key := range managed {
+			delete(params, key)
+		}
+		return params
+	}
+	return maps.Equal(unmanaged(volume), unmanaged(requested))
+}
+
+// sameSource reports whether two volume sources name the same volume or
+// snapshot
+func sameSource(a, b *types.VolumeSource) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// accessibleTopology returns the topology a volume can be used from. Volumes
+// pinned to a node are only accessible there, which the node segment alone
+// identifies; all others are accessible from every node.
+func accessibleTopology(volume *types.Volume) []*csi.Topology {
+	if volume.Node == "" {
+		return nil
+	}
+	return []*csi.Topology{
+		{Segments: map[string]string{TopologyKeyNode: volume.Node}},
+	}
+}
+
+// accessMode maps a CSI access mode to the access mode of a volume.
+// Multi-node single writers are not supported.
+func accessMode(mode csi.VolumeCapability_AccessMode_Mode) (types.AccessMode, bool) {
+	switch mode {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER:
+		return types.AccessModeSingleNodeWriter, true
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:
+		return types.AccessModeSingleNodeMultiWriter, true
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY:
+		return types.AccessModeSingleNodeReaderOnly, true
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		return types.AccessModeMultiNodeReaderOnly, true
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER:
+		return types.AccessModeMultiNodeMultiWriter, true
+	default:
+		return "", false
+	}
+}
+
+// volumeAccessMode returns the access mode requested by the capabilities of
+// a new volume. All capabilities must request the same mode.
+func volumeAccessMode(capabilities []*csi.VolumeCapability) (types.AccessMode, error) {
+	if len(capabilities) == 0 {
+		return "", status.Error(codes.InvalidArgument, "volume capabilities are required")
+	}
+
+	var mode types.AccessMode
+	for _, capability := range capabilities {
+		m, ok := accessMode(capability.GetAccessMode().GetMode())
+		if !ok {
+			return "", status.Errorf(codes.InvalidArgument, "unsupported access mode %s", capability.GetAccessMode().GetMode())
+		}
+		if mode != "" && m != mode {
+			return "", status.Errorf(codes.InvalidArgument, "conflicting access modes %s and %s", mode, m)
+		}
+		mode = m
+	}
+	return mode, nil
+}
+
 // DeleteVolume deletes a volume
 func (s *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
 	volumeID := req.GetVolumeId()
@@ -82,10 +292,13 @@ func (s *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolu
 	// Call Volume Manager to delete the volume
 	if err := s.client.DeleteVolume(ctx, volumeID); err != nil {
 		// If volume not found, consider it a success (idempotent delete)
-		if err.Error() == "volume not found" {
+		if errors.Is(err, client.ErrNotFound) {
 			s.logger.Info("volume already deleted", "volume_id", volumeID)
 			return &csi.DeleteVolumeResponse{}, nil
 		}
+		if errors.Is(err, client.ErrVolumeInUse) {
+			return nil, status.Errorf(codes.FailedPrecondition, "volume is still published: %v", err)
+		}
 		return nil, status.Errorf(codes.Internal, "failed to delete volume: %v", err)
 	}
 
@@ -111,12 +324,23 @@ func (s *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *
 	}
 
 	// Check if volume exists
-	_, err := s.client.GetVolume(ctx, volumeID)
-	if err != nil {
+	volume, err := s.client.GetVolume(ctx, volumeID)
+	if errors.Is(err, client.ErrNotFound) {
 		return nil, status.Errorf(codes.NotFound, "volume not found: %v", err)
 	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get volume: %v", err)
+	}
+
+	// A volume supports the access mode it was created with
+	for _, capability := range req.GetVolumeCapabilities() {
+		if mode, ok := accessMode(capability.GetAccessMode().GetMode()); !ok || mode != volume.AccessMode {
+			return &csi.ValidateVolumeCapabilitiesResponse{
+				Message: fmt.Sprintf("access mode %s is not supported by the volume", capability.GetAccessMode().GetMode()),
+			}, nil
+		}
+	}
 
-	// All capabilities are supported for local filesystem
 	return &csi.ValidateVolumeCapabilitiesResponse{
 		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
 			VolumeCapabilities: req.GetVolumeCapabilities(),
@@ -125,9 +349,17 @@ func (s *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *
 	}, nil
 }
 
-// ListVolumes lists all volumes
+// ListVolumes lists volumes a page at a time. The starting and next tokens
+// are the continue tokens of the Volume Manager listing.
 func (s *ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	volumes, err := s.client.ListVolumes(ctx)
+	if req.GetMaxEntries() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid max entries %d", req.GetMaxEntries())
+	}
+
+	volumes, next, err := s.client.ListVolumes(ctx, types.VolumeFilter{}, int(req.GetMaxEntries()), req.GetStartingToken())
+	if errors.Is(err, client.ErrInvalidContinue) {
+		return nil, status.Errorf(codes.Aborted, "invalid starting token %q", req.GetStartingToken())
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to list volumes: %v", err)
 	}
@@ -135,22 +367,45 @@ func (s *ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumes
 	entries := make([]*csi.ListVolumesResponse_Entry, len(volumes))
 	for i, vol := range volumes {
 		entries[i] = &csi.ListVolumesResponse_Entry{
-			Volume: &csi.Volume{
-				VolumeId:      vol.ID,
-				CapacityBytes: 0,
+			Volume: csiVolume(vol),
+			Status: &csi.ListVolumesResponse_VolumeStatus{
+				PublishedNodeIds: vol.NodesInState(types.AttachmentStatePublished),
+				VolumeCondition:  volumeCondition(vol),
 			},
 		}
 	}
 
 	return &csi.ListVolumesResponse{
-		Entries: entries,
+		Entries:   entries,
+		NextToken: next,
 	}, nil
 }
 
-// GetCapacity returns available capacity (not applicable for local filesystem)
+// GetCapacity returns the space available to new volumes created with the
+// given parameters
 func (s *ControllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+	parameters := make(map[string]string, len(req.GetParameters()))
+	for key, value := range req.GetParameters() {
+		parameters[key] = value
+	}
+
+	backend := parameters["backend"]
+	if backend == "" {
+		backend = "local" // Default to local backend
+	}
+	delete(parameters, "backend")
+
+	available, err := s.client.GetCapacity(ctx, backend, parameters)
+	if errors.Is(err, client.ErrNotSupported) {
+		// Node-local storage is only known on its nodes
+		return nil, status.Errorf(codes.Unimplemented, "capacity of backend %s is unknown: %v", backend, err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get capacity: %v", err)
+	}
+
 	return &csi.GetCapacityResponse{
-		AvailableCapacity: 0, // Unlimited for local filesystem
+		AvailableCapacity: available,
 	}, nil
 }
 
@@ -172,33 +427,288 @@ func (s *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *c
 					},
 				},
 			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 	}, nil
 }
 
-// CreateSnapshot creates a snapshot (not implemented)
+// CreateSnapshot creates a snapshot of a volume. Retries with the same
+// name and source return the existing snapshot, which for node-local volumes
+// becomes ready once their node has copied the data.
 func (s *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "CreateSnapshot is not implemented")
+	name := req.GetName()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "snapshot name is required")
+	}
+
+	sourceVolumeID := req.GetSourceVolumeId()
+	if sourceVolumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "source volume ID is required")
+	}
+
+	existing, err := s.client.ListSnapshots(ctx, "", name)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to look up snapshot: %v", err)
+	}
+	if len(existing) > 0 {
+		if existing[0].SourceVolumeID != sourceVolumeID {
+			return nil, status.Errorf(codes.AlreadyExists, "snapshot %s already exists for volume %s", name, existing[0].SourceVolumeID)
+		}
+		// The node of a node-local volume could not copy its data
+		if existing[0].Status == types.SnapshotStatusFailed {
+			return nil, status.Errorf(codes.Internal, "snapshot %s failed: %s", name, existing[0].Error)
+		}
+		return &csi.CreateSnapshotResponse{Snapshot: csiSnapshot(existing[0])}, nil
+	}
+
+	s.logger.Info("creating snapshot", "name", name, "source_volume_id", sourceVolumeID)
+
+	snapshot, err := s.client.CreateSnapshot(ctx, sourceVolumeID, name)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "source volume %s not found", sourceVolumeID)
+		}
+		if errors.Is(err, client.ErrVolumeNotPinned) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to create snapshot: %v", err)
+	}
+
+	s.logger.Info("snapshot created", "snapshot_id", snapshot.ID, "name", name)
+
+	return &csi.CreateSnapshotResponse{Snapshot: csiSnapshot(snapshot)}, nil
 }
 
-// DeleteSnapshot deletes a snapshot (not implemented)
+// DeleteSnapshot deletes a snapshot
 func (s *ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "DeleteSnapshot is not implemented")
+	snapshotID := req.GetSnapshotId()
+	if snapshotID == "" {
+		return nil, status.Error(codes.InvalidArgument, "snapshot ID is required")
+	}
+
+	s.logger.Info("deleting snapshot", "snapshot_id", snapshotID)
+
+	if err := s.client.DeleteSnapshot(ctx, snapshotID); err != nil {
+		// If snapshot not found, consider it a success (idempotent delete)
+		if errors.Is(err, client.ErrNotFound) {
+			s.logger.Info("snapshot already deleted", "snapshot_id", snapshotID)
+			return &csi.DeleteSnapshotResponse{}, nil
+		}
+		return nil, status.Errorf(codes.Internal, "failed to delete snapshot: %v", err)
+	}
+
+	s.logger.Info("snapshot deleted", "snapshot_id", snapshotID)
+	return &csi.DeleteSnapshotResponse{}, nil
 }
 
-// ListSnapshots lists snapshots (not implemented)
+// ListSnapshots lists snapshots, optionally filtered by snapshot or source
+// volume ID. The starting token is the offset into the full listing.
 func (s *ControllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "ListSnapshots is not implemented")
+	var snapshots []*types.Snapshot
+	if snapshotID := req.GetSnapshotId(); snapshotID != "" {
+		snapshot, err := s.client.GetSnapshot(ctx, snapshotID)
+		if err != nil && !errors.Is(err, client.ErrNotFound) {
+			return nil, status.Errorf(codes.Internal, "failed to get snapshot: %v", err)
+		}
+		if snapshot != nil && (req.GetSourceVolumeId() == "" || snapshot.SourceVolumeID == req.GetSourceVolumeId()) {
+			snapshots = append(snapshots, snapshot)
+		}
+	} else {
+		var err error
+		snapshots, err = s.client.ListSnapshots(ctx, req.GetSourceVolumeId(), "")
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to list snapshots: %v", err)
+		}
+	}
+
+	start := 0
+	if token := req.GetStartingToken(); token != "" {
+		var err error
+		start, err = strconv.Atoi(token)
+		if err != nil || start < 0 || start > len(snapshots) {
+			return nil, status.Errorf(codes.Aborted, "invalid starting token %q", token)
+		}
+	}
+
+	end := len(snapshots)
+	if maxEntries := int(req.GetMaxEntries()); maxEntries > 0 && start+maxEntries < end {
+		end = start + maxEntries
+	}
+
+	entries := make([]*csi.ListSnapshotsResponse_Entry, 0, end-start)
+	for _, snapshot := range snapshots[start:end] {
+		entries = append(entries, &csi.ListSnapshotsResponse_Entry{
+			Snapshot: csiSnapshot(snapshot),
+		})
+	}
+
+	resp := &csi.ListSnapshotsResponse{Entries: entries}
+	if end < len(snapshots) {
+		resp.NextToken = strconv.Itoa(end)
+	}
+
+	return resp, nil
+}
+
+// csiSnapshot converts a manager snapshot to its CSI representation
+func csiSnapshot(snapshot *types.Snapshot) *csi.Snapshot {
+	return &csi.Snapshot{
+		SnapshotId:     snapshot.ID,
+		SourceVolumeId: snapshot.SourceVolumeID,
+		SizeBytes:      snapshot.SizeBytes,
+		CreationTime:   timestamppb.New(snapshot.CreatedAt),
+		ReadyToUse:     snapshot.ReadyToUse,
+	}
 }
 
-// ControllerExpandVolume expands a volume (not implemented)
+// ControllerExpandVolume grows the backing storage of a volume. For backends
+// with a filesystem of their own, the node then grows it in NodeExpandVolume.
 func (s *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "ControllerExpandVolume is not implemented")
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume ID is required")
+	}
+
+	capacityBytes := req.GetCapacityRange().GetRequiredBytes()
+	if capacityBytes == 0 {
+		capacityBytes = req.GetCapacityRange().GetLimitBytes()
+	}
+	if capacityBytes <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "capacity range is required")
+	}
+
+	volume, err := s.client.GetVolume(ctx, volumeID)
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "volume not found: %v", err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get volume: %v", err)
+	}
+
+	// Unlimited volumes already fit any size, and retries must succeed
+	if volume.CapacityBytes == 0 || volume.CapacityBytes >= capacityBytes {
+		return &csi.ControllerExpandVolumeResponse{
+			CapacityBytes:         max(volume.CapacityBytes, capacityBytes),
+			NodeExpansionRequired: false,
+		}, nil
+	}
+
+	s.logger.Info("expanding volume", "volume_id", volumeID, "capacity_bytes", capacityBytes)
+
+	volume, err = s.client.ExpandVolume(ctx, volumeID, capacityBytes)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to expand volume: %v", err)
+	}
+
+	s.logger.Info("volume expanded", "volume_id", volumeID, "capacity_bytes", volume.CapacityBytes)
+
+	// Only backends with a node-side expansion have a filesystem to grow
+	_, expanderErr := storage.GetExpander(volume.Backend)
+
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         volume.CapacityBytes,
+		NodeExpansionRequired: expanderErr == nil,
+	}, nil
 }
 
-// ControllerGetVolume gets volume information (not implemented)
+// ControllerGetVolume returns a volume with the nodes it is published on
+// and its condition
 func (s *ControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "ControllerGetVolume is not implemented")
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume ID is required")
+	}
+
+	volume, err := s.client.GetVolume(ctx, volumeID)
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "volume not found: %v", err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get volume: %v", err)
+	}
+
+	return &csi.ControllerGetVolumeResponse{
+		Volume: csiVolume(volume),
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: volume.NodesInState(types.AttachmentStatePublished),
+			VolumeCondition:  volumeCondition(volume),
+		},
+	}, nil
+}
+
+// csiVolume converts a volume of the manager to a CSI volume
+func csiVolume(volume *types.Volume) *csi.Volume {
+	return &csi.Volume{
+		VolumeId:           volume.ID,
+		CapacityBytes:      volume.CapacityBytes,
+		VolumeContext:      volume.Parameters,
+		AccessibleTopology: accessibleTopology(volume),
+	}
+}
+
+// volumeCondition returns the condition of a volume, abnormal if a node
+// reported an error or a failed health check for it
+func volumeCondition(volume *types.Volume) *csi.VolumeCondition {
+	condition := volume.Condition()
+	return &csi.VolumeCondition{
+		Abnormal: condition.Abnormal,
+		Message:  condition.Message,
+	}
 }
 
 // ControllerModifyVolume modifies a volume (not implemented)
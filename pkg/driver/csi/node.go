@@ -2,36 +2,65 @@ package csi
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/sistemica/docker-volume-manager/pkg/driver/client"
+	"github.com/sistemica/docker-volume-manager/pkg/state"
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/fsutil"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
 
-// NodeServer implements the CSI Node service
+// watchRetryInterval is how long the node waits before reconnecting a
+// failed volume watch
+const watchRetryInterval = 5 * time.Second
+
+// healthCheckInterval is how often the node checks the health of the
+// volumes staged on it
+const healthCheckInterval = time.Minute
+
+// snapshotSyncInterval is how often the node looks for snapshots of its
+// node-local volumes to take or remove
+const snapshotSyncInterval = 5 * time.Second
+
+// NodeServer implements the CSI Node service. Stage and publish operations
+// run the backend locally on this node and report the result to the manager.
 type NodeServer struct {
 	csi.UnimplementedNodeServer
-	nodeID    string
-	client    *client.VolumeManagerClient
-	logger    *slog.Logger
+	nodeID   string
+	topology map[string]string
+	client   *client.VolumeManagerClient
+	staged   stagedVolumes
+	mounter  mount.Mounter
+	logger   *slog.Logger
 }
 
-// NewNodeServer creates a new Node service
-func NewNodeServer(nodeID, managerURL string, logger *slog.Logger) (*NodeServer, error) {
+// NewNodeServer creates a new Node service. topology holds the segments
+// reported for this node, see NodeTopology. The volumes staged on the node
+// are recorded in stateDir.
+func NewNodeServer(nodeID, managerURL string, topology map[string]string, stateDir string, logger *slog.Logger) (*NodeServer, error) {
 	client := client.NewVolumeManagerClient(managerURL, logger)
 
 	return &NodeServer{
-		nodeID: nodeID,
-		client: client,
-		logger: logger.With("service", "csi-node"),
+		nodeID:   nodeID,
+		topology: topology,
+		client:   client,
+		staged:   stagedVolumes{dir: stateDir},
+		mounter:  mount.New(),
+		logger:   logger.With("service", "csi-node"),
 	}, nil
 }
 
-// NodeStageVolume prepares the volume (e.g., extract zip, setup filesystem)
+// NodeStageVolume prepares the volume (e.g., extract an archive, setup filesystem)
 func (s *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
 	// Validate request
 	volumeID := req.GetVolumeId()
@@ -51,11 +80,31 @@ func (s *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolu
 		return nil, status.Errorf(codes.Internal, "failed to create staging path: %v", err)
 	}
 
-	// Call Volume Manager to stage the volume
-	if err := s.client.StageVolume(ctx, volumeID, stagingPath, s.nodeID); err != nil {
+	volume, backend, err := s.getVolumeBackend(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The data of a pinned volume does not exist on other nodes
+	if volume.Node != "" && volume.Node != s.nodeID {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume is pinned to node %s", volume.Node)
+	}
+
+	// Stage the volume on this node, handing the CSI secrets to the backend
+	if err := backend.Stage(storage.WithSecrets(ctx, req.GetSecrets()), volume, stagingPath); err != nil {
+		s.reportFailure(ctx, volumeID, "stage", err)
 		return nil, status.Errorf(codes.Internal, "failed to stage volume: %v", err)
 	}
 
+	if err := s.staged.Save(volume); err != nil {
+		s.logger.Warn("failed to record staged volume locally", "volume_id", volumeID, "error", err)
+	}
+
+	// Report the staged volume to the Volume Manager
+	if err := s.client.StageVolume(ctx, volumeID, stagingPath, s.nodeID, volume.Generation); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to record staged volume: %v", err)
+	}
+
 	s.logger.Info("volume staged successfully", "volume_id", volumeID)
 	return &csi.NodeStageVolumeResponse{}, nil
 }
@@ -74,11 +123,33 @@ func (s *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstage
 
 	s.logger.Info("unstaging volume", "volume_id", volumeID, "staging_path", stagingPath)
 
-	// Call Volume Manager to unstage the volume
-	if err := s.client.UnstageVolume(ctx, volumeID, stagingPath); err != nil {
+	volume, backend, err := s.getVolumeBackend(ctx, volumeID)
+	if status.Code(err) == codes.NotFound {
+		// The volume was deleted while staged here; clean up all the same
+		if err := s.unstageDeleted(ctx, volumeID, stagingPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to unstage deleted volume: %v", err)
+		}
+		s.logger.Info("deleted volume unstaged", "volume_id", volumeID)
+		return &csi.NodeUnstageVolumeResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unstage the volume on this node
+	if err := backend.Unstage(ctx, volume, stagingPath); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to unstage volume: %v", err)
 	}
 
+	if err := s.staged.Remove(volumeID); err != nil {
+		s.logger.Warn("failed to remove staged volume record", "volume_id", volumeID, "error", err)
+	}
+
+	// Report the unstaged volume to the Volume Manager
+	if err := s.client.UnstageVolume(ctx, volumeID, stagingPath, s.nodeID); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to record unstaged volume: %v", err)
+	}
+
 	s.logger.Info("volume unstaged successfully", "volume_id", volumeID)
 	return &csi.NodeUnstageVolumeResponse{}, nil
 }
@@ -114,11 +185,35 @@ func (s *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublish
 		return nil, status.Errorf(codes.Internal, "failed to create target path: %v", err)
 	}
 
-	// Call Volume Manager to publish (bind mount) the volume
-	if err := s.client.PublishVolume(ctx, volumeID, stagingPath, targetPath, readOnly); err != nil {
+	volume, backend, err := s.getVolumeBackend(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse early what the access mode of the volume does not allow; the
+	// Volume Manager checks again when the publish is recorded
+	if err := state.CheckAccessMode(volume, s.nodeID, readOnly); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	// Publish (bind mount) the volume on this node
+	if err := backend.Publish(storage.WithSecrets(ctx, req.GetSecrets()), volume, stagingPath, targetPath, readOnly); err != nil {
+		s.reportFailure(ctx, volumeID, "publish", err)
 		return nil, status.Errorf(codes.Internal, "failed to publish volume: %v", err)
 	}
 
+	// Report the published volume to the Volume Manager
+	if err := s.client.PublishVolume(ctx, volumeID, stagingPath, targetPath, s.nodeID, readOnly); err != nil {
+		if errors.Is(err, client.ErrAccessModeConflict) {
+			// Another node published the volume in the meantime
+			if unpublishErr := backend.Unpublish(ctx, volume, targetPath); unpublishErr != nil {
+				s.logger.Error("failed to undo publish", "volume_id", volumeID, "target_path", targetPath, "error", unpublishErr)
+			}
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to record published volume: %v", err)
+	}
+
 	s.logger.Info("volume published successfully", "volume_id", volumeID, "target_path", targetPath)
 	return &csi.NodePublishVolumeResponse{}, nil
 }
@@ -137,11 +232,29 @@ func (s *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpub
 
 	s.logger.Info("unpublishing volume", "volume_id", volumeID, "target_path", targetPath)
 
-	// Call Volume Manager to unpublish (unmount) the volume
-	if err := s.client.UnpublishVolume(ctx, volumeID, targetPath); err != nil {
+	volume, backend, err := s.getVolumeBackend(ctx, volumeID)
+	if status.Code(err) == codes.NotFound {
+		// The volume was deleted while published here; unmount all the same
+		if err := s.unpublishDeleted(ctx, volumeID, targetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to unpublish deleted volume: %v", err)
+		}
+		s.logger.Info("deleted volume unpublished", "volume_id", volumeID, "target_path", targetPath)
+		return &csi.NodeUnpublishVolumeResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unpublish (unmount) the volume on this node
+	if err := backend.Unpublish(ctx, volume, targetPath); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to unpublish volume: %v", err)
 	}
 
+	// Report the unpublished volume to the Volume Manager
+	if err := s.client.UnpublishVolume(ctx, volumeID, targetPath, s.nodeID); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to record unpublished volume: %v", err)
+	}
+
 	s.logger.Info("volume unpublished successfully", "volume_id", volumeID)
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
@@ -157,6 +270,27 @@ func (s *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCa
 					},
 				},
 			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+					},
+				},
+			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 	}, nil
 }
@@ -165,16 +299,510 @@ func (s *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCa
 func (s *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
 	return &csi.NodeGetInfoResponse{
 		NodeId: s.nodeID,
-		// For local filesystem, no topology constraints - any node can access volumes
+		AccessibleTopology: &csi.Topology{
+			Segments: s.topology,
+		},
 	}, nil
 }
 
-// NodeGetVolumeStats returns volume statistics (optional)
+// NodeGetVolumeStats reports the space and inodes of the filesystem mounted
+// at the volume path
 func (s *NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeGetVolumeStats is not implemented")
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume ID is required")
+	}
+
+	volumePath := req.GetVolumePath()
+	if volumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume path is required")
+	}
+
+	if _, err := os.Stat(volumePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "volume path %s not found", volumePath)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to stat volume path: %v", err)
+	}
+
+	usage, err := fsutil.Usage(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get volume stats: %v", err)
+	}
+
+	volume, _, err := s.getVolumeBackend(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	condition := &csi.VolumeCondition{Message: "volume is healthy"}
+	if cause := s.checkVolume(ctx, volume); cause != nil {
+		condition = &csi.VolumeCondition{Abnormal: true, Message: cause.Error()}
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		VolumeCondition: condition,
+		Usage: []*csi.VolumeUsage{
+			{
+				Unit:      csi.VolumeUsage_BYTES,
+				Total:     usage.TotalBytes,
+				Used:      usage.UsedBytes,
+				Available: usage.AvailableBytes,
+			},
+			{
+				Unit:      csi.VolumeUsage_INODES,
+				Total:     usage.TotalInodes,
+				Used:      usage.UsedInodes,
+				Available: usage.AvailableInodes,
+			},
+		},
+	}, nil
 }
 
-// NodeExpandVolume expands the volume (optional)
+// NodeExpandVolume grows the filesystem of a volume on this node after the
+// controller has expanded it
 func (s *NodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeExpandVolume is not implemented")
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume ID is required")
+	}
+
+	volumePath := req.GetVolumePath()
+	if volumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume path is required")
+	}
+
+	volume, backend, err := s.getVolumeBackend(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	expander, ok := backend.(storage.Expander)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "backend %s does not support expansion", volume.Backend)
+	}
+
+	s.logger.Info("expanding volume", "volume_id", volumeID, "volume_path", volumePath, "capacity_bytes", volume.CapacityBytes)
+
+	if err := expander.NodeExpandVolume(ctx, volume, volumePath); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to expand volume: %v", err)
+	}
+
+	if err := s.staged.SetCapacity(volumeID, volume.CapacityBytes); err != nil {
+		s.logger.Warn("failed to record expanded volume locally", "volume_id", volumeID, "error", err)
+	}
+
+	s.logger.Info("volume expanded successfully", "volume_id", volumeID)
+	return &csi.NodeExpandVolumeResponse{
+		CapacityBytes: volume.CapacityBytes,
+	}, nil
+}
+
+// getVolumeBackend fetches a volume from the Volume Manager and resolves the
+// node-side part of its backend
+func (s *NodeServer) getVolumeBackend(ctx context.Context, volumeID string) (*types.Volume, storage.Node, error) {
+	volume, err := s.client.GetVolume(ctx, volumeID)
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, nil, status.Errorf(codes.NotFound, "volume not found: %v", err)
+	}
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "failed to get volume: %v", err)
+	}
+
+	backend, err := storage.GetNode(volume.Backend)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "failed to get backend %s: %v", volume.Backend, err)
+	}
+
+	return volume, backend, nil
+}
+
+// unstageDeleted unstages a volume the Volume Manager no longer knows. The
+// backend cleans up if the volume was recorded when it was staged here;
+// otherwise the staging path is unmounted and emptied.
+func (s *NodeServer) unstageDeleted(ctx context.Context, volumeID, stagingPath string) error {
+	volume, err := s.staged.Load(volumeID)
+	if err != nil {
+		s.logger.Warn("failed to load staged volume record", "volume_id", volumeID, "error", err)
+	}
+
+	if volume != nil {
+		backend, err := storage.GetNode(volume.Backend)
+		if err != nil {
+			return err
+		}
+		if err := backend.Unstage(ctx, volume, stagingPath); err != nil {
+			return err
+		}
+	} else if err := clearStagingPath(s.mounter, stagingPath); err != nil {
+		return err
+	}
+
+	return s.staged.Remove(volumeID)
+}
+
+// unpublishDeleted unpublishes a volume the Volume Manager no longer knows,
+// through its backend if the volume was recorded when it was staged here
+func (s *NodeServer) unpublishDeleted(ctx context.Context, volumeID, targetPath string) error {
+	volume, err := s.staged.Load(volumeID)
+	if err != nil {
+		s.logger.Warn("failed to load staged volume record", "volume_id", volumeID, "error", err)
+	}
+
+	if volume != nil {
+		backend, err := storage.GetNode(volume.Backend)
+		if err != nil {
+			return err
+		}
+		return backend.Unpublish(ctx, volume, targetPath)
+	}
+
+	return mount.UnmountIfMounted(s.mounter, targetPath)
+}
+
+// reportFailure records a failed node operation on the Volume Manager so the
+// error shows up on the volume's attachment for this node
+func (s *NodeServer) reportFailure(ctx context.Context, volumeID, operation string, cause error) {
+	if err := s.client.ReportFailure(ctx, volumeID, operation, s.nodeID, cause); err != nil {
+		s.logger.Warn("failed to report node failure",
+			"volume_id", volumeID,
+			"operation", operation,
+			"error", err,
+		)
+	}
+}
+
+// WatchRefreshes follows volume changes on the Volume Manager and refreshes
+// the content of volumes staged on this node whose generation has moved past
+// the one staged here, e.g. after a git volume was pointed at a new ref. It
+// also grows staged volumes whose capacity was raised. It runs until ctx is
+// done.
+func (s *NodeServer) WatchRefreshes(ctx context.Context) {
+	revision := int64(0)
+	// Generations already tried per volume, so a failure reported back to the
+	// manager does not trigger the same refresh again
+	attempted := make(map[string]int64)
+	// Capacities already grown to per volume; volumes not in it yet start
+	// from the capacity recorded when they were staged or last grown
+	expanded := make(map[string]int64)
+
+	for {
+		rev, err := s.client.WatchVolumes(ctx, revision, func(event *types.VolumeEvent) error {
+			s.handleVolumeEvent(ctx, event, attempted, expanded)
+			return nil
+		})
+		revision = rev
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, client.ErrWatchExpired) {
+			// Start over with a full listing
+			revision = 0
+		} else if err != nil {
+			s.logger.Warn("volume watch failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// stagedCapacity returns the capacity a volume staged on this node was last
+// grown to, or 0 if it is unknown
+func (s *NodeServer) stagedCapacity(volumeID string) int64 {
+	volume, err := s.staged.Load(volumeID)
+	if err != nil {
+		s.logger.Warn("failed to load staged volume record", "volume_id", volumeID, "error", err)
+	}
+	if volume == nil {
+		return 0
+	}
+	return volume.CapacityBytes
+}
+
+// handleVolumeEvent grows the volume of event if it is staged on this node and
+// its capacity was raised, and refreshes it if its content is older than the
+// volume generation
+func (s *NodeServer) handleVolumeEvent(ctx context.Context, event *types.VolumeEvent, attempted, expanded map[string]int64) {
+	volume := event.Volume
+	if event.Type == types.EventDeleted {
+		delete(attempted, volume.ID)
+		delete(expanded, volume.ID)
+		return
+	}
+
+	attachment := volume.Attachments[s.nodeID]
+	if attachment == nil || attachment.StagingPath == "" {
+		return
+	}
+
+	// Expansions through the API reach the node here; CSI orchestrators also
+	// call NodeExpandVolume, which is a no-op once the volume has grown
+	if _, ok := expanded[volume.ID]; !ok {
+		expanded[volume.ID] = s.stagedCapacity(volume.ID)
+	}
+	if volume.CapacityBytes > expanded[volume.ID] {
+		expanded[volume.ID] = volume.CapacityBytes
+		if expander, err := storage.GetExpander(volume.Backend); err == nil {
+			if err := expander.NodeExpandVolume(ctx, volume, attachment.StagingPath); err != nil {
+				s.logger.Error("failed to expand volume", "volume_id", volume.ID, "error", err)
+			} else if err := s.staged.SetCapacity(volume.ID, volume.CapacityBytes); err != nil {
+				s.logger.Warn("failed to record expanded volume locally", "volume_id", volume.ID, "error", err)
+			}
+		}
+	}
+
+	if attachment.Generation >= volume.Generation {
+		return
+	}
+	if attempted[volume.ID] >= volume.Generation {
+		return
+	}
+	attempted[volume.ID] = volume.Generation
+
+	refresher, err := storage.GetRefresher(volume.Backend)
+	if err != nil {
+		return
+	}
+
+	s.logger.Info("refreshing volume",
+		"volume_id", volume.ID,
+		"generation", volume.Generation,
+		"staging_path", attachment.StagingPath,
+	)
+
+	if err := refresher.Refresh(ctx, volume, attachment.StagingPath); err != nil {
+		s.logger.Error("failed to refresh volume", "volume_id", volume.ID, "error", err)
+		s.reportFailure(ctx, volume.ID, "stage", fmt.Errorf("refresh to generation %d failed: %w", volume.Generation, err))
+		return
+	}
+
+	// Record the new generation on this node's attachment
+	if err := s.client.StageVolume(ctx, volume.ID, attachment.StagingPath, s.nodeID, volume.Generation); err != nil {
+		s.logger.Warn("failed to record refreshed volume", "volume_id", volume.ID, "error", err)
+		return
+	}
+
+	s.logger.Info("volume refreshed successfully", "volume_id", volume.ID, "generation", volume.Generation)
+}
+
+// MonitorHealth checks the volumes staged on this node every
+// healthCheckInterval until ctx is done and reports changed conditions to the
+// manager, where they show up on the node's attachment
+func (s *NodeServer) MonitorHealth(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkHealth(ctx)
+		}
+	}
+}
+
+// checkHealth checks every volume staged on this node once
+func (s *NodeServer) checkHealth(ctx context.Context) {
+	volumes, _, err := s.client.ListVolumes(ctx, types.VolumeFilter{Node: s.nodeID}, 0, "")
+	if err != nil {
+		s.logger.Warn("failed to list volumes for health check", "error", err)
+		return
+	}
+
+	for _, volume := range volumes {
+		attachment := volume.Attachments[s.nodeID]
+		if attachment == nil || attachment.State == types.AttachmentStateFailed {
+			continue
+		}
+
+		s.reportUsage(ctx, volume, attachment)
+
+		cause := s.checkVolume(ctx, volume)
+
+		// Only changes are reported
+		if cause == nil && attachment.Condition == nil {
+			continue
+		}
+		if cause != nil && attachment.Condition != nil && attachment.Condition.Message == cause.Error() {
+			continue
+		}
+
+		if cause != nil {
+			s.logger.Warn("volume is abnormal", "volume_id", volume.ID, "error", cause)
+		} else {
+			s.logger.Info("volume is healthy again", "volume_id", volume.ID)
+		}
+		if err := s.client.ReportCondition(ctx, volume.ID, s.nodeID, cause); err != nil {
+			s.logger.Warn("failed to report volume condition", "volume_id", volume.ID, "error", err)
+		}
+	}
+}
+
+// reportUsage measures the usage of a node-local volume staged on this node,
+// where its data lives, and reports it to the manager if it changed. The
+// manager cannot measure it from its own host.
+func (s *NodeServer) reportUsage(ctx context.Context, volume *types.Volume, attachment *types.NodeAttachment) {
+	controller, err := storage.GetController(volume.Backend)
+	if err != nil || !controller.Capabilities().NodeLocal {
+		return
+	}
+	reporter, err := storage.GetUsageReporter(volume.Backend)
+	if err != nil {
+		return
+	}
+
+	usage, err := reporter.Usage(ctx, volume)
+	if err != nil {
+		if !errors.Is(err, storage.ErrUsageUnknown) {
+			s.logger.Warn("failed to measure volume usage", "volume_id", volume.ID, "error", err)
+		}
+		usage = nil
+	}
+
+	if (usage == nil && attachment.Usage == nil) || (usage != nil && attachment.Usage != nil && *usage == *attachment.Usage) {
+		return
+	}
+	if err := s.client.ReportUsage(ctx, volume.ID, s.nodeID, usage); err != nil {
+		s.logger.Warn("failed to report volume usage", "volume_id", volume.ID, "error", err)
+	}
+}
+
+// checkVolume runs the health check of the volume's backend against its
+// staging path on this node. Volumes of backends without a health check, or
+// not staged here, are assumed healthy.
+func (s *NodeServer) checkVolume(ctx context.Context, volume *types.Volume) error {
+	attachment := volume.Attachments[s.nodeID]
+	if attachment == nil || attachment.StagingPath == "" {
+		return nil
+	}
+
+	checker, err := storage.GetHealthChecker(volume.Backend)
+	if err != nil {
+		return nil
+	}
+
+	return checker.CheckHealth(ctx, volume, attachment.StagingPath)
+}
+
+// Register records this node and its topology with the manager, which
+// places new node-local volumes by it, and renews the registration every
+// nodeRegisterInterval until ctx is done. A failed registration is retried
+// after watchRetryInterval.
+func (s *NodeServer) Register(ctx context.Context) {
+	for {
+		interval := nodeRegisterInterval
+		if err := s.client.RegisterNode(ctx, s.nodeID, s.topology); err != nil {
+			s.logger.Warn("failed to register node", "error", err)
+			interval = watchRetryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// SyncSnapshots takes and removes the snapshots whose data is held by this
+// node, as the manager records them, every snapshotSyncInterval until ctx is
+// done. Node-local volumes can only be copied on the node they live on.
+func (s *NodeServer) SyncSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(snapshotSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.syncSnapshots(ctx)
+		}
+	}
+}
+
+// syncSnapshots handles every pending snapshot of this node once
+func (s *NodeServer) syncSnapshots(ctx context.Context) {
+	snapshots, err := s.client.ListNodeSnapshots(ctx, s.nodeID)
+	if err != nil {
+		s.logger.Warn("failed to list snapshots", "error", err)
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		switch {
+		case snapshot.Status == types.SnapshotStatusCreating:
+			s.createSnapshot(ctx, snapshot)
+		case snapshot.Status == types.SnapshotStatusDeleting && snapshot.Error == "":
+			// A failed removal is retried when the snapshot is deleted again
+			s.deleteSnapshot(ctx, snapshot)
+		}
+	}
+}
+
+// createSnapshot copies the data of a snapshot's volume and reports the result
+func (s *NodeServer) createSnapshot(ctx context.Context, snapshot *types.Snapshot) {
+	result := types.SnapshotResultRequest{NodeID: s.nodeID}
+
+	volume, err := s.client.GetVolume(ctx, snapshot.SourceVolumeID)
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		result.Error = fmt.Sprintf("source volume %s was deleted", snapshot.SourceVolumeID)
+	case err != nil:
+		// Tried again on the next sync
+		s.logger.Warn("failed to get snapshot source volume", "snapshot_id", snapshot.ID, "error", err)
+		return
+	default:
+		s.logger.Info("creating snapshot", "snapshot_id", snapshot.ID, "volume_id", volume.ID)
+
+		snapshotter, err := storage.GetNodeSnapshotter(snapshot.Backend)
+		if err == nil {
+			err = snapshotter.NodeCreateSnapshot(ctx, volume, snapshot)
+		}
+		if err != nil {
+			s.logger.Error("failed to create snapshot", "snapshot_id", snapshot.ID, "error", err)
+			result.Error = err.Error()
+		} else {
+			result.Parameters = snapshot.Parameters
+			result.SizeBytes = snapshot.SizeBytes
+		}
+	}
+
+	if err := s.client.ReportSnapshotResult(ctx, snapshot.ID, "created", result); err != nil {
+		s.logger.Warn("failed to report created snapshot", "snapshot_id", snapshot.ID, "error", err)
+		return
+	}
+
+	if result.Error == "" {
+		s.logger.Info("snapshot created successfully", "snapshot_id", snapshot.ID)
+	}
+}
+
+// deleteSnapshot removes the data of a snapshot and reports the result
+func (s *NodeServer) deleteSnapshot(ctx context.Context, snapshot *types.Snapshot) {
+	s.logger.Info("deleting snapshot", "snapshot_id", snapshot.ID)
+
+	result := types.SnapshotResultRequest{NodeID: s.nodeID}
+	snapshotter, err := storage.GetNodeSnapshotter(snapshot.Backend)
+	if err == nil {
+		err = snapshotter.NodeDeleteSnapshot(ctx, snapshot)
+	}
+	if err != nil {
+		s.logger.Error("failed to delete snapshot", "snapshot_id", snapshot.ID, "error", err)
+		result.Error = err.Error()
+	}
+
+	if err := s.client.ReportSnapshotResult(ctx, snapshot.ID, "deleted", result); err != nil {
+		s.logger.Warn("failed to report deleted snapshot", "snapshot_id", snapshot.ID, "error", err)
+		return
+	}
+
+	if result.Error == "" {
+		s.logger.Info("snapshot deleted successfully", "snapshot_id", snapshot.ID)
+	}
 }
@@ -0,0 +1,129 @@
+package csi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// stagedVolumes keeps a copy of every volume staged on this node, one JSON
+// file per volume in dir. Orchestrators may unstage a volume after it was
+// deleted on the Volume Manager; the copy lets the backend clean up anyway.
+type stagedVolumes struct {
+	dir string
+}
+
+// path returns the file holding the copy of a volume
+func (s stagedVolumes) path(volumeID string) string {
+	return filepath.Join(s.dir, volumeID+".json")
+}
+
+// Save records volume as staged on this node
+func (s stagedVolumes) Save(volume *types.Volume) error {
+	if s.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+
+	// Write and rename, so a crash never leaves a truncated record
+	tmp := s.path(volume.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(volume.ID))
+}
+
+// Load returns the recorded copy of a volume, or nil if there is none
+func (s stagedVolumes) Load(volumeID string) (*types.Volume, error) {
+	if s.dir == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(s.path(volumeID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var volume types.Volume
+	if err := json.Unmarshal(data, &volume); err != nil {
+		return nil, fmt.Errorf("invalid staged volume record %s: %w", s.path(volumeID), err)
+	}
+	return &volume, nil
+}
+
+// SetCapacity records that the filesystem of a staged volume was grown to
+// capacityBytes. Staging grows a volume to its capacity, so the recorded
+// capacity is the one applied on this node.
+func (s stagedVolumes) SetCapacity(volumeID string, capacityBytes int64) error {
+	volume, err := s.Load(volumeID)
+	if err != nil || volume == nil {
+		return err
+	}
+	volume.CapacityBytes = capacityBytes
+	return s.Save(volume)
+}
+
+// Remove drops the record of a volume that is no longer staged
+func (s stagedVolumes) Remove(volumeID string) error {
+	if s.dir == "" {
+		return nil
+	}
+	if err := os.Remove(s.path(volumeID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// clearStagingPath unmounts whatever is mounted at stagingPath and removes
+// its content, leaving the directory itself to the orchestrator. Nothing is
+// removed while other mounts live below it, as their data is not ours.
+func clearStagingPath(m mount.Mounter, stagingPath string) error {
+	if err := mount.UnmountIfMounted(m, stagingPath); err != nil {
+		return err
+	}
+
+	resolved, err := filepath.EvalSymlinks(stagingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mounts, err := mount.ReadMountInfo()
+	if err != nil {
+		return err
+	}
+	for _, mi := range mounts {
+		if strings.HasPrefix(mi.MountPoint, resolved+string(filepath.Separator)) {
+			return fmt.Errorf("%s is still mounted below %s", mi.MountPoint, stagingPath)
+		}
+	}
+
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(resolved, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package csi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// Topology segment keys reported by every node
+const (
+	topologyPrefix = "topology.sistemica.io/"
+
+	// TopologyKeyNode carries the node ID, which node-local volumes are pinned to
+	TopologyKeyNode = topologyPrefix + "node"
+
+	// TopologyKeyHostname carries the host name of the node
+	TopologyKeyHostname = topologyPrefix + "hostname"
+)
+
+// nodeRegisterInterval is how often a node registers its topology with the
+// manager
+const nodeRegisterInterval = time.Minute
+
+// nodeExpiry is how long a registration is trusted; nodes that have not
+// renewed it for longer are no longer chosen for new volumes
+const nodeExpiry = 5 * nodeRegisterInterval
+
+// labelNamePattern matches the names allowed for node labels
+var labelNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9_.]{0,61}[a-z0-9])?$`)
+
+// NodeTopology returns the topology segments of a node: its ID, its host
+// name and the labels given as comma-separated key=value pairs, e.g.
+// "zone=eu-1,rack=r3". Labels become segments under the same prefix.
+func NodeTopology(nodeID, hostname, labels string) (map[string]string, error) {
+	segments := map[string]string{
+		TopologyKeyNode:     nodeID,
+		TopologyKeyHostname: hostname,
+	}
+
+	for _, label := range strings.Split(labels, ",") {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(label, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !labelNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid node label %q, expected name=value", label)
+		}
+		key := topologyPrefix + name
+		if key == TopologyKeyNode || key == TopologyKeyHostname {
+			return nil, fmt.Errorf("node label %q is reserved", name)
+		}
+		segments[key] = strings.TrimSpace(value)
+	}
+
+	return segments, nil
+}
+
+// selectNode returns the node a new node-local volume is placed on, choosing
+// among the registered nodes in ID order. Each preferred topology is tried
+// in turn, then each requisite one; a node lies in a topology if it has all
+// of its segments, and must also lie in one of the requisite topologies if
+// there are any. A topology naming a node that has not registered selects
+// that node, so volumes can be placed before their node reports in. It
+// returns false if no node satisfies the requirements, and an empty node
+// if there are none.
+func selectNode(requirements *csi.TopologyRequirement, nodes []*types.Node) (string, bool) {
+	if !hasTopology(requirements) {
+		return "", true
+	}
+
+	registered := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		registered[node.ID] = true
+	}
+
+	for _, topologies := range [][]*csi.Topology{requirements.GetPreferred(), requirements.GetRequisite()} {
+		for _, topology := range topologies {
+			for _, node := range nodes {
+				if inTopology(node.Topology, topology) && inRequisite(requirements, node.Topology) {
+					return node.ID, true
+				}
+			}
+
+			segments := topology.GetSegments()
+			if node := segments[TopologyKeyNode]; node != "" && !registered[node] && inRequisite(requirements, segments) {
+				return node, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// hasTopology reports whether requirements restrict where a volume is placed
+func hasTopology(requirements *csi.TopologyRequirement) bool {
+	return len(requirements.GetRequisite()) > 0 || len(requirements.GetPreferred()) > 0
+}
+
+// inTopology reports whether a node with the given segments lies in topology
+func inTopology(segments map[string]string, topology *csi.Topology) bool {
+	for key, value := range topology.GetSegments() {
+		if segments[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// inRequisite reports whether a node with the given segments lies in one of
+// the requisite topologies, or whether there are none
+func inRequisite(requirements *csi.TopologyRequirement, segments map[string]string) bool {
+	requisite := requirements.GetRequisite()
+	if len(requisite) == 0 {
+		return true
+	}
+	for _, topology := range requisite {
+		if inTopology(segments, topology) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSegments returns the topology segments a node registered, or only its
+// node segment if it has not registered
+func nodeSegments(nodeID string, nodes []*types.Node) map[string]string {
+	for _, node := range nodes {
+		if node.ID == nodeID {
+			return node.Topology
+		}
+	}
+	return map[string]string{TopologyKeyNode: nodeID}
+}
+
+// liveNodes returns the nodes that registered within nodeExpiry before now
+func liveNodes(nodes []*types.Node, now time.Time) []*types.Node {
+	live := make([]*types.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if now.Sub(node.UpdatedAt) <= nodeExpiry {
+			live = append(live, node)
+		}
+	}
+	return live
+}
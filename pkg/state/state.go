@@ -0,0 +1,339 @@
+// Package state implements the per-node volume state machine.
+//
+// Each node a volume is attached to moves through
+//
+//	(none) -> staged -> published -> staged -> (none)
+//
+// and may be marked failed when the node reports an error. The volume status
+// is derived from the states of all of its attachments.
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// Machine-readable codes for rejected transitions
+const (
+	CodePublishBeforeStage    = "publish_before_stage"
+	CodeUnstageWhilePublished = "unstage_while_published"
+	CodeStagingPathMismatch   = "staging_path_mismatch"
+	CodeVolumeInUse           = "volume_in_use"
+	CodeNodeMismatch          = "node_mismatch"
+	CodeAccessModeConflict    = "access_mode_conflict"
+)
+
+// TransitionError is returned when a transition is not allowed from the current state
+type TransitionError struct {
+	Code    string
+	NodeID  string
+	From    types.AttachmentState
+	Message string
+}
+
+// Error implements the error interface
+func (e *TransitionError) Error() string {
+	if e.NodeID == "" {
+		return e.Message
+	}
+	from := string(e.From)
+	if from == "" {
+		from = "unattached"
+	}
+	return fmt.Sprintf("%s (node %s is %s)", e.Message, e.NodeID, from)
+}
+
+// Stage records that the volume has been staged on a node with the content
+// of the given volume generation. Staging again at the same path records a
+// newer generation, as reported after a refresh, and is otherwise a no-op.
+// A volume pinned to a node is only staged there.
+func Stage(volume *types.Volume, nodeID, stagingPath string, generation int64, now time.Time) error {
+	attachment := volume.Attachments[nodeID]
+
+	// The data of a pinned volume exists only on its node
+	if volume.Node != "" && volume.Node != nodeID {
+		from := types.AttachmentState("")
+		if attachment != nil {
+			from = attachment.State
+		}
+		return &TransitionError{
+			Code:    CodeNodeMismatch,
+			NodeID:  nodeID,
+			From:    from,
+			Message: fmt.Sprintf("volume is pinned to node %s", volume.Node),
+		}
+	}
+
+	if attachment != nil && attachment.State != types.AttachmentStateFailed {
+		if attachment.StagingPath != stagingPath {
+			return &TransitionError{
+				Code:    CodeStagingPathMismatch,
+				NodeID:  nodeID,
+				From:    attachment.State,
+				Message: fmt.Sprintf("volume is already staged at %s", attachment.StagingPath),
+			}
+		}
+		if generation > attachment.Generation {
+			attachment.Generation = generation
+			attachment.LastError = ""
+			attachment.UpdatedAt = now
+			touch(volume, now)
+		}
+		return nil
+	}
+
+	if volume.Attachments == nil {
+		volume.Attachments = make(map[string]*types.NodeAttachment)
+	}
+	volume.Attachments[nodeID] = &types.NodeAttachment{
+		State:       types.AttachmentStateStaged,
+		StagingPath: stagingPath,
+		Generation:  generation,
+		StagedAt:    &now,
+		UpdatedAt:   now,
+	}
+
+	touch(volume, now)
+	return nil
+}
+
+// Publish records that the volume has been published at a target path on a node.
+// The volume must already be staged on that node, and its access mode must
+// allow the publish.
+func Publish(volume *types.Volume, nodeID, targetPath string, readOnly bool, now time.Time) error {
+	attachment := volume.Attachments[nodeID]
+
+	if attachment == nil || attachment.State == types.AttachmentStateFailed {
+		from := types.AttachmentState("")
+		if attachment != nil {
+			from = attachment.State
+		}
+		return &TransitionError{
+			Code:    CodePublishBeforeStage,
+			NodeID:  nodeID,
+			From:    from,
+			Message: "volume must be staged before it is published",
+		}
+	}
+
+	for _, path := range attachment.TargetPaths {
+		if path == targetPath {
+			return nil
+		}
+	}
+
+	if err := CheckAccessMode(volume, nodeID, readOnly); err != nil {
+		return err
+	}
+
+	if attachment.State != types.AttachmentStatePublished {
+		attachment.State = types.AttachmentStatePublished
+		attachment.PublishedAt = &now
+	}
+	attachment.TargetPaths = append(attachment.TargetPaths, targetPath)
+	attachment.LastError = ""
+	attachment.UpdatedAt = now
+
+	touch(volume, now)
+	return nil
+}
+
+// CheckAccessMode returns an error if publishing the volume on a node
+// would violate its access mode: a read-write publish of a reader-only
+// volume, or a publish of a single-node volume while another node has it
+// published. A volume recorded without a mode is treated as single-node-writer.
+func CheckAccessMode(volume *types.Volume, nodeID string, readOnly bool) error {
+	var from types.AttachmentState
+	if attachment := volume.Attachments[nodeID]; attachment != nil {
+		from = attachment.State
+	}
+
+	mode := volume.AccessMode
+	if mode == "" {
+		mode = types.AccessModeSingleNodeWriter
+	}
+
+	if mode.ReadOnly() && !readOnly {
+		return &TransitionError{
+			Code:    CodeAccessModeConflict,
+			NodeID:  nodeID,
+			From:    from,
+			Message: "volume can only be published read-only",
+		}
+	}
+
+	if mode.SingleNode() {
+		for _, other := range volume.NodesInState(types.AttachmentStatePublished) {
+			if other != nodeID {
+				return &TransitionError{
+					Code:    CodeAccessModeConflict,
+					NodeID:  nodeID,
+					From:    from,
+					Message: fmt.Sprintf("volume is %s and already published on node %s", mode, other),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Unpublish records that the volume has been removed from a target path on a node.
+// An empty target path removes all targets. Unpublishing a volume that is not
+// published on the node is a no-op.
+func Unpublish(volume *types.Volume, nodeID, targetPath string, now time.Time) error {
+	attachment := volume.Attachments[nodeID]
+	if attachment == nil || attachment.State != types.AttachmentStatePublished {
+		return nil
+	}
+
+	remaining := attachment.TargetPaths[:0]
+	for _, path := range attachment.TargetPaths {
+		if targetPath != "" && path != targetPath {
+			remaining = append(remaining, path)
+		}
+	}
+
+	if len(remaining) == 0 {
+		attachment.State = types.AttachmentStateStaged
+		attachment.TargetPaths = nil
+		attachment.PublishedAt = nil
+	} else {
+		attachment.TargetPaths = remaining
+	}
+	attachment.UpdatedAt = now
+
+	touch(volume, now)
+	return nil
+}
+
+// Unstage records that the volume has been unstaged from a node.
+// The volume must be unpublished on that node first.
+func Unstage(volume *types.Volume, nodeID string, now time.Time) error {
+	attachment := volume.Attachments[nodeID]
+	if attachment == nil {
+		return nil
+	}
+
+	if attachment.State == types.AttachmentStatePublished {
+		return &TransitionError{
+			Code:    CodeUnstageWhilePublished,
+			NodeID:  nodeID,
+			From:    attachment.State,
+			Message: "volume must be unpublished before it is unstaged",
+		}
+	}
+
+	delete(volume.Attachments, nodeID)
+	if len(volume.Attachments) == 0 {
+		volume.Attachments = nil
+	}
+
+	touch(volume, now)
+	return nil
+}
+
+// Fail records an error reported by a node. A node without an attachment is
+// marked failed; an existing attachment keeps its state and records the error.
+func Fail(volume *types.Volume, nodeID, message string, now time.Time) {
+	if volume.Attachments == nil {
+		volume.Attachments = make(map[string]*types.NodeAttachment)
+	}
+
+	attachment := volume.Attachments[nodeID]
+	if attachment == nil {
+		attachment = &types.NodeAttachment{State: types.AttachmentStateFailed}
+		volume.Attachments[nodeID] = attachment
+	}
+	attachment.LastError = message
+	attachment.UpdatedAt = now
+
+	touch(volume, now)
+}
+
+// SetCondition records the result of a health check of the volume on a node.
+// A node without an attachment has nothing to check, so its report is
+// ignored, as is a condition that is already recorded.
+func SetCondition(volume *types.Volume, nodeID string, condition types.VolumeCondition, now time.Time) {
+	attachment := volume.Attachments[nodeID]
+	if attachment == nil {
+		return
+	}
+
+	var next *types.VolumeCondition
+	if condition.Abnormal {
+		next = &condition
+	}
+	if (attachment.Condition == nil && next == nil) ||
+		(attachment.Condition != nil && next != nil && *attachment.Condition == *next) {
+		return
+	}
+
+	attachment.Condition = next
+	attachment.UpdatedAt = now
+
+	touch(volume, now)
+}
+
+// SetUsage records the usage of a node-local volume measured on a node. It
+// is a no-op if the volume is not attached to the node or the usage has not
+// changed.
+func SetUsage(volume *types.Volume, nodeID string, usage *types.VolumeUsage, now time.Time) {
+	attachment := volume.Attachments[nodeID]
+	if attachment == nil {
+		return
+	}
+
+	if (attachment.Usage == nil && usage == nil) ||
+		(attachment.Usage != nil && usage != nil && *attachment.Usage == *usage) {
+		return
+	}
+
+	attachment.Usage = usage
+	attachment.UpdatedAt = now
+
+	touch(volume, now)
+}
+
+// CheckDeletable returns an error if the volume is still published on any node
+func CheckDeletable(volume *types.Volume) error {
+	if nodes := volume.NodesInState(types.AttachmentStatePublished); len(nodes) > 0 {
+		return &TransitionError{
+			Code:    CodeVolumeInUse,
+			Message: fmt.Sprintf("volume is still published on %v, unpublish first", nodes),
+		}
+	}
+	return nil
+}
+
+// Status derives the volume status from its attachments
+func Status(volume *types.Volume) types.VolumeStatus {
+	var staged, failed bool
+	for _, attachment := range volume.Attachments {
+		switch attachment.State {
+		case types.AttachmentStatePublished:
+			return types.VolumeStatusPublished
+		case types.AttachmentStateStaged:
+			staged = true
+		case types.AttachmentStateFailed:
+			failed = true
+		}
+	}
+
+	switch {
+	case staged:
+		return types.VolumeStatusStaged
+	case failed:
+		return types.VolumeStatusFailed
+	default:
+		return types.VolumeStatusCreated
+	}
+}
+
+// touch refreshes the derived status and update timestamp of a volume
+func touch(volume *types.Volume, now time.Time) {
+	volume.Status = Status(volume)
+	volume.UpdatedAt = now
+}
@@ -0,0 +1,326 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+var now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// step applies one transition to a volume
+type step func(volume *types.Volume) error
+
+func stage(nodeID, stagingPath string) step {
+	return func(volume *types.Volume) error {
+		return Stage(volume, nodeID, stagingPath, 0, now)
+	}
+}
+
+func publish(nodeID, targetPath string, readOnly bool) step {
+	return func(volume *types.Volume) error {
+		return Publish(volume, nodeID, targetPath, readOnly, now)
+	}
+}
+
+func unpublish(nodeID, targetPath string) step {
+	return func(volume *types.Volume) error {
+		return Unpublish(volume, nodeID, targetPath, now)
+	}
+}
+
+func unstage(nodeID string) step {
+	return func(volume *types.Volume) error {
+		return Unstage(volume, nodeID, now)
+	}
+}
+
+func fail(nodeID string) step {
+	return func(volume *types.Volume) error {
+		Fail(volume, nodeID, "stage failed", now)
+		return nil
+	}
+}
+
+// code returns the code of a transition error, or "" for nil
+func code(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("error = %v, want a *TransitionError", err)
+	}
+	return transitionErr.Code
+}
+
+func TestTransitions(t *testing.T) {
+	tests := map[string]struct {
+		mode       types.AccessMode
+		node       string // Node the volume is pinned to
+		setup      []step
+		op         step
+		wantCode   string
+		wantStatus types.VolumeStatus
+	}{
+		"stage": {
+			op:         stage("n1", "/staging"),
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"stage again at the same path": {
+			setup:      []step{stage("n1", "/staging")},
+			op:         stage("n1", "/staging"),
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"stage at another path": {
+			setup:      []step{stage("n1", "/staging")},
+			op:         stage("n1", "/other"),
+			wantCode:   CodeStagingPathMismatch,
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"stage after a failure": {
+			setup:      []step{fail("n1")},
+			op:         stage("n1", "/staging"),
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"stage on its node": {
+			node:       "n1",
+			op:         stage("n1", "/staging"),
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"stage on another node than its own": {
+			node:       "n2",
+			op:         stage("n1", "/staging"),
+			wantCode:   CodeNodeMismatch,
+			wantStatus: types.VolumeStatusCreated,
+		},
+		"publish before stage": {
+			op:         publish("n1", "/target", false),
+			wantCode:   CodePublishBeforeStage,
+			wantStatus: types.VolumeStatusCreated,
+		},
+		"publish after a failure": {
+			setup:      []step{fail("n1")},
+			op:         publish("n1", "/target", false),
+			wantCode:   CodePublishBeforeStage,
+			wantStatus: types.VolumeStatusFailed,
+		},
+		"publish": {
+			setup:      []step{stage("n1", "/staging")},
+			op:         publish("n1", "/target", false),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"publish the same target again": {
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false)},
+			op:         publish("n1", "/target", false),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"unpublish one of two targets": {
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/a", false), publish("n1", "/b", false)},
+			op:         unpublish("n1", "/a"),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"unpublish all targets": {
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/a", false), publish("n1", "/b", false)},
+			op:         unpublish("n1", ""),
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"unpublish when not published": {
+			setup:      []step{stage("n1", "/staging")},
+			op:         unpublish("n1", "/target"),
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"unstage while published": {
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false)},
+			op:         unstage("n1"),
+			wantCode:   CodeUnstageWhilePublished,
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"unstage after unpublish": {
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false), unpublish("n1", "/target")},
+			op:         unstage("n1"),
+			wantStatus: types.VolumeStatusCreated,
+		},
+		"unstage when not staged": {
+			op:         unstage("n1"),
+			wantStatus: types.VolumeStatusCreated,
+		},
+		"single-node-writer on a second node": {
+			mode:       types.AccessModeSingleNodeWriter,
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false), stage("n2", "/staging")},
+			op:         publish("n2", "/target", true),
+			wantCode:   CodeAccessModeConflict,
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"single-node-writer read-only": {
+			mode:       types.AccessModeSingleNodeWriter,
+			setup:      []step{stage("n1", "/staging")},
+			op:         publish("n1", "/target", true),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"single-node-writer after the other node unpublished": {
+			mode: types.AccessModeSingleNodeWriter,
+			setup: []step{
+				stage("n1", "/staging"), publish("n1", "/target", false), unpublish("n1", "/target"),
+				stage("n2", "/staging"),
+			},
+			op:         publish("n2", "/target", false),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"single-node-multi-writer on a second node": {
+			mode:       types.AccessModeSingleNodeMultiWriter,
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false), stage("n2", "/staging")},
+			op:         publish("n2", "/target", false),
+			wantCode:   CodeAccessModeConflict,
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"single-node-reader-only read-write": {
+			mode:       types.AccessModeSingleNodeReaderOnly,
+			setup:      []step{stage("n1", "/staging")},
+			op:         publish("n1", "/target", false),
+			wantCode:   CodeAccessModeConflict,
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"single-node-reader-only on a second node": {
+			mode:       types.AccessModeSingleNodeReaderOnly,
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", true), stage("n2", "/staging")},
+			op:         publish("n2", "/target", true),
+			wantCode:   CodeAccessModeConflict,
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"multi-node-reader-only read-write": {
+			mode:       types.AccessModeMultiNodeReaderOnly,
+			setup:      []step{stage("n1", "/staging")},
+			op:         publish("n1", "/target", false),
+			wantCode:   CodeAccessModeConflict,
+			wantStatus: types.VolumeStatusStaged,
+		},
+		"multi-node-reader-only on a second node": {
+			mode:       types.AccessModeMultiNodeReaderOnly,
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", true), stage("n2", "/staging")},
+			op:         publish("n2", "/target", true),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"multi-node-multi-writer on a second node": {
+			mode:       types.AccessModeMultiNodeMultiWriter,
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false), stage("n2", "/staging")},
+			op:         publish("n2", "/target", false),
+			wantStatus: types.VolumeStatusPublished,
+		},
+		"no access mode on a second node": {
+			setup:      []step{stage("n1", "/staging"), publish("n1", "/target", false), stage("n2", "/staging")},
+			op:         publish("n2", "/target", false),
+			wantCode:   CodeAccessModeConflict,
+			wantStatus: types.VolumeStatusPublished,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			volume := &types.Volume{ID: "vol-1", AccessMode: tt.mode, Node: tt.node, Status: types.VolumeStatusCreated}
+			for i, s := range tt.setup {
+				if err := s(volume); err != nil {
+					t.Fatalf("setup step %d error = %v", i, err)
+				}
+			}
+
+			if got := code(t, tt.op(volume)); got != tt.wantCode {
+				t.Errorf("code = %q, want %q", got, tt.wantCode)
+			}
+			if volume.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", volume.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStageRecordsNewerGeneration(t *testing.T) {
+	volume := &types.Volume{ID: "vol-1"}
+
+	for _, generation := range []int64{1, 3, 2} {
+		if err := Stage(volume, "n1", "/staging", generation, now); err != nil {
+			t.Fatalf("Stage(generation %d) error = %v", generation, err)
+		}
+	}
+
+	if got := volume.Attachments["n1"].Generation; got != 3 {
+		t.Errorf("generation = %d, want 3", got)
+	}
+}
+
+func TestFailKeepsState(t *testing.T) {
+	volume := &types.Volume{ID: "vol-1"}
+	if err := Stage(volume, "n1", "/staging", 0, now); err != nil {
+		t.Fatal(err)
+	}
+
+	Fail(volume, "n1", "refresh failed", now)
+
+	attachment := volume.Attachments["n1"]
+	if attachment.State != types.AttachmentStateStaged || attachment.LastError != "refresh failed" {
+		t.Errorf("attachment = %+v, want staged with the error recorded", attachment)
+	}
+	if volume.Status != types.VolumeStatusStaged {
+		t.Errorf("status = %s, want %s", volume.Status, types.VolumeStatusStaged)
+	}
+}
+
+func TestCheckDeletable(t *testing.T) {
+	tests := map[string]struct {
+		setup    []step
+		wantCode string
+	}{
+		"never staged": {},
+		"staged":       {setup: []step{stage("n1", "/staging")}},
+		"failed":       {setup: []step{fail("n1")}},
+		"published": {
+			setup:    []step{stage("n1", "/staging"), publish("n1", "/target", false)},
+			wantCode: CodeVolumeInUse,
+		},
+		"unpublished": {
+			setup: []step{stage("n1", "/staging"), publish("n1", "/target", false), unpublish("n1", "")},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			volume := &types.Volume{ID: "vol-1", AccessMode: types.AccessModeSingleNodeWriter}
+			for i, s := range tt.setup {
+				if err := s(volume); err != nil {
+					t.Fatalf("setup step %d error = %v", i, err)
+				}
+			}
+
+			if got := code(t, CheckDeletable(volume)); got != tt.wantCode {
+				t.Errorf("code = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestReportsIgnoredWhenUnchanged(t *testing.T) {
+	volume := &types.Volume{ID: "vol-1"}
+	if err := Stage(volume, "n1", "/staging", 0, now); err != nil {
+		t.Fatal(err)
+	}
+
+	later := now.Add(time.Minute)
+	SetCondition(volume, "n1", types.VolumeCondition{}, later)
+	SetUsage(volume, "n1", nil, later)
+	SetCondition(volume, "n2", types.VolumeCondition{Abnormal: true}, later)
+	if !volume.UpdatedAt.Equal(now) {
+		t.Fatalf("updated at = %v, want %v: unchanged reports must not touch the volume", volume.UpdatedAt, now)
+	}
+
+	usage := &types.VolumeUsage{UsedBytes: 10}
+	SetUsage(volume, "n1", usage, later)
+	if got := volume.Attachments["n1"].Usage; got == nil || *got != *usage {
+		t.Errorf("usage = %v, want %v", got, usage)
+	}
+	if !volume.UpdatedAt.Equal(later) {
+		t.Errorf("updated at = %v, want %v", volume.UpdatedAt, later)
+	}
+}
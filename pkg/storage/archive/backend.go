@@ -0,0 +1,521 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/fsutil"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+const (
+	// defaultDataDir matches the DATA_DIR default of the volume manager
+	defaultDataDir = "/var/lib/volume-manager"
+
+	// defaultManagerURL matches the MANAGER_URL default of the CSI plugin
+	defaultManagerURL = "http://volume-manager:9789"
+
+	// replicaParam records the volume manager replica holding an uploaded
+	// archive
+	replicaParam = "archive_replica"
+
+	// checksumPrefix is the only supported checksum algorithm
+	checksumPrefix = "sha256:"
+
+	// stagedMarker is written to the staging path once a writable copy of
+	// the archive is complete
+	stagedMarker = ".archive-staged"
+)
+
+func init() {
+	// Register the archive backend on import
+	if err := storage.RegisterBackend("archive", NewBackend); err != nil {
+		panic(fmt.Sprintf("failed to register archive backend: %v", err))
+	}
+}
+
+// Backend implements a storage backend whose volumes are seeded from a tar
+// or zip archive, fetched from a URL or uploaded to the volume manager.
+// Nodes extract every archive once into a cache keyed by its SHA-256 and
+// share it read-only between volumes, or give writable volumes a copy.
+type Backend struct {
+	mounter    mount.Mounter
+	uploadDir  string
+	cacheDir   string
+	managerURL string
+	replicaURL string
+	logger     *slog.Logger
+}
+
+// NewBackend creates a new archive backend. Uploaded archives are kept in
+// DATA_DIR/archives on the volume manager replica that received them and
+// extracted archives in DATA_DIR/archive-cache on nodes.
+func NewBackend() (storage.Backend, error) {
+	dataDir := getEnv("DATA_DIR", defaultDataDir)
+	managerURL := getEnv("MANAGER_URL", defaultManagerURL)
+
+	// Other replicas reach this one through the <SERVICE_NAME>.<TASK_SLOT>
+	// hostname Swarm gives each task, unless ADVERTISE_URL overrides it
+	replicaURL := os.Getenv("ADVERTISE_URL")
+	if replicaURL == "" {
+		replicaURL = fmt.Sprintf("http://%s.%s:%s",
+			getEnv("SERVICE_NAME", "volume-manager"),
+			getEnv("TASK_SLOT", "1"),
+			getEnv("PORT", "9789"),
+		)
+	}
+
+	return &Backend{
+		mounter:    mount.New(),
+		uploadDir:  filepath.Join(dataDir, "archives"),
+		cacheDir:   filepath.Join(dataDir, "archive-cache"),
+		managerURL: strings.TrimSuffix(managerURL, "/"),
+		replicaURL: strings.TrimSuffix(replicaURL, "/"),
+		logger:     slog.Default().With("backend", "archive"),
+	}, nil
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Name returns the backend name
+func (b *Backend) Name() string {
+	return "archive"
+}
+
+// Capabilities returns the backend capabilities
+func (b *Backend) Capabilities() types.BackendCapability {
+	return types.BackendCapability{
+		SupportsReadOnly:  true,
+		SupportsReadWrite: true,
+		AccessModes: []types.AccessMode{
+			types.AccessModeSingleNodeWriter,
+			types.AccessModeSingleNodeMultiWriter,
+			types.AccessModeSingleNodeReaderOnly,
+			types.AccessModeMultiNodeReaderOnly,
+		},
+		ManagedParameters: []string{"checksum", replicaParam},
+	}
+}
+
+// Validate validates the volume parameters
+func (b *Backend) Validate(params map[string]string) error {
+	source := params["source"]
+	checksum := params["checksum"]
+
+	if params[replicaParam] != "" {
+		return fmt.Errorf("parameter '%s' is set by the upload", replicaParam)
+	}
+
+	if source == "" {
+		// The checksum of an uploaded archive is recorded by the upload
+		if checksum != "" {
+			return errors.New("parameter 'checksum' requires 'source'; uploaded archives are checksummed on upload")
+		}
+	} else {
+		u, err := url.Parse(source)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("source must be an http or https URL: %s", source)
+		}
+		if checksum == "" {
+			return errors.New("parameter 'checksum' is required with 'source'")
+		}
+		if _, err := parseChecksum(checksum); err != nil {
+			return err
+		}
+	}
+
+	if writable := params["writable"]; writable != "" {
+		if _, err := strconv.ParseBool(writable); err != nil {
+			return fmt.Errorf("writable must be true or false: %s", writable)
+		}
+	}
+
+	return nil
+}
+
+// parseChecksum returns the hex digest of a sha256:<hex> checksum
+func parseChecksum(checksum string) (string, error) {
+	sum, ok := strings.CutPrefix(checksum, checksumPrefix)
+	if !ok {
+		return "", fmt.Errorf("checksum must be sha256:<hex>: %s", checksum)
+	}
+	sum = strings.ToLower(sum)
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("checksum must be sha256:<hex>: %s", checksum)
+	}
+	return sum, nil
+}
+
+// writable reports whether a volume gets its own writable copy of the archive
+func writable(volume *types.Volume) bool {
+	w, _ := strconv.ParseBool(volume.Parameters["writable"])
+	return w
+}
+
+// Provision allocates the backing storage for a new volume
+func (b *Backend) Provision(ctx context.Context, volume *types.Volume) error {
+	// There is nothing to allocate; nodes fetch the archive when staging
+	b.logger.Info("provisioned volume",
+		"volume_id", volume.ID,
+		"source", volume.Parameters["source"],
+	)
+
+	return nil
+}
+
+// Delete removes the uploaded archive of a volume, on the replica holding
+// it. Extracted copies in node caches are shared by content and stay until
+// the cache is cleared.
+func (b *Backend) Delete(ctx context.Context, volume *types.Volume) error {
+	if owner := b.UploadOwner(volume); owner != "" {
+		if err := b.deleteRemote(ctx, owner, volume); err != nil {
+			return err
+		}
+	} else if err := b.DeleteUpload(ctx, volume); err != nil {
+		return err
+	}
+
+	b.logger.Info("deleted volume",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
+
+// deleteRemote asks the replica holding the archive of a volume to remove it.
+// The volume record is already gone by then, so the request names the
+// backend rather than the volume.
+func (b *Backend) deleteRemote(ctx context.Context, owner string, volume *types.Volume) error {
+	uploadURL := owner + "/api/v1/backends/" + b.Name() + "/uploads/" + url.PathEscape(volume.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, uploadURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to remove archive on %s: %w", owner, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to remove archive on %s: unexpected status %d", owner, resp.StatusCode)
+	}
+	return nil
+}
+
+// volumeUploadDir returns where the archives uploaded for a volume are kept
+func (b *Backend) volumeUploadDir(volume *types.Volume) string {
+	return filepath.Join(b.uploadDir, volume.ID)
+}
+
+// uploadPath returns where the uploaded archive with the given SHA-256 is
+// kept. Naming each upload by its content means the archive a checksum
+// parameter refers to is never replaced underneath it.
+func (b *Backend) uploadPath(volume *types.Volume, sum string) string {
+	return filepath.Join(b.volumeUploadDir(volume), sum+".archive")
+}
+
+// UploadOwner returns the replica holding the uploaded archive of a volume
+// if it is not this one
+func (b *Backend) UploadOwner(volume *types.Volume) string {
+	if owner := volume.Parameters[replicaParam]; owner != b.replicaURL {
+		return owner
+	}
+	return ""
+}
+
+// Upload stores an archive for a volume on this volume manager replica and
+// returns its checksum and the replica as parameters. The archive the volume
+// currently refers to is kept, so nodes staging it finish with the content
+// they were given; older uploads are removed.
+func (b *Backend) Upload(ctx context.Context, volume *types.Volume, r io.Reader) (map[string]string, error) {
+	if source := volume.Parameters["source"]; source != "" {
+		return nil, fmt.Errorf("%w: volume %s is seeded from %s", storage.ErrInvalidUpload, volume.ID, source)
+	}
+
+	dir := b.volumeUploadDir(volume)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(tmp, io.TeeReader(r, h))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	format, err := detectFormat(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", storage.ErrInvalidUpload, err)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.Rename(tmp.Name(), b.uploadPath(volume, sum)); err != nil {
+		return nil, fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	if err := b.pruneUploads(volume); err != nil {
+		b.logger.Warn("failed to remove old archives", "volume_id", volume.ID, "error", err)
+	}
+
+	checksum := checksumPrefix + sum
+
+	b.logger.Info("archive uploaded",
+		"volume_id", volume.ID,
+		"format", format,
+		"size", size,
+		"checksum", checksum,
+	)
+
+	return map[string]string{
+		"checksum":   checksum,
+		replicaParam: b.replicaURL,
+	}, nil
+}
+
+// pruneUploads removes the archives of a volume uploaded before the one its
+// parameters refer to. Newer ones belong to uploads that have not been
+// recorded yet and are left alone.
+func (b *Backend) pruneUploads(volume *types.Volume) error {
+	sum, err := parseChecksum(volume.Parameters["checksum"])
+	if err != nil || b.UploadOwner(volume) != "" {
+		return nil
+	}
+	current, err := os.Stat(b.uploadPath(volume, sum))
+	if err != nil {
+		return nil
+	}
+
+	entries, err := os.ReadDir(b.volumeUploadDir(volume))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".archive") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(current.ModTime()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.volumeUploadDir(volume), entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenUpload opens the uploaded archive the checksum parameter of a volume
+// refers to
+func (b *Backend) OpenUpload(ctx context.Context, volume *types.Volume) (*os.File, error) {
+	sum, err := parseChecksum(volume.Parameters["checksum"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errNotUploaded, fs.ErrNotExist)
+	}
+	return os.Open(b.uploadPath(volume, sum))
+}
+
+// DeleteUpload removes every archive this replica holds for a volume
+func (b *Backend) DeleteUpload(ctx context.Context, volume *types.Volume) error {
+	dir := b.volumeUploadDir(volume)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove archives %s: %w", dir, err)
+	}
+	return nil
+}
+
+// archivePath returns the API path serving the uploaded archive of a volume
+func archivePath(volume *types.Volume) string {
+	return "/api/v1/volumes/" + url.PathEscape(volume.ID) + "/archive"
+}
+
+// archiveURL returns where nodes fetch the archive of a volume from. Any
+// replica serves an uploaded archive, proxying to the one holding it.
+func (b *Backend) archiveURL(volume *types.Volume) string {
+	if source := volume.Parameters["source"]; source != "" {
+		return source
+	}
+	return b.managerURL + archivePath(volume)
+}
+
+// dataPath returns where the content of a volume is placed in its staging path
+func dataPath(stagingPath string) string {
+	return filepath.Join(stagingPath, "data")
+}
+
+// Stage makes the extracted archive available in the staging path. Read-only
+// volumes bind-mount the shared cache entry; writable volumes copy it.
+func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("staging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	checksum := volume.Parameters["checksum"]
+	if checksum == "" {
+		return fmt.Errorf("%w: %s", errNotUploaded, volume.ID)
+	}
+	sum, err := parseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+
+	target := dataPath(stagingPath)
+	markerPath := filepath.Join(stagingPath, stagedMarker)
+
+	// Staging again is a no-op once the content is in place
+	if writable(volume) {
+		if _, err := os.Stat(markerPath); err == nil {
+			return nil
+		}
+	} else if mounted, err := b.mounter.IsMountPoint(target); err != nil {
+		return fmt.Errorf("failed to check staging path: %w", err)
+	} else if mounted {
+		return nil
+	}
+
+	entry, err := b.ensureCached(ctx, b.archiveURL(volume), sum)
+	if err != nil {
+		return err
+	}
+
+	if writable(volume) {
+		// A copy interrupted by an earlier attempt is started over
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to clear staging path: %w", err)
+		}
+		size, err := fsutil.CopyTree(entry, target)
+		if err != nil {
+			return fmt.Errorf("failed to copy archive content: %w", err)
+		}
+		if err := os.WriteFile(markerPath, nil, 0600); err != nil {
+			return err
+		}
+		b.logger.Info("volume staged successfully",
+			"volume_id", volume.ID,
+			"size", size,
+		)
+		return nil
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create staging path: %w", err)
+	}
+	// The cache entry is shared with every volume of the same archive
+	if err := mount.BindMount(b.mounter, entry, target, true); err != nil {
+		return fmt.Errorf("failed to bind mount: %w", err)
+	}
+
+	b.logger.Info("volume staged successfully",
+		"volume_id", volume.ID,
+		"sha256", sum,
+	)
+
+	return nil
+}
+
+// Unstage removes the content from the staging path. The changes made to a
+// writable volume are discarded with it.
+func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("unstaging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	target := dataPath(stagingPath)
+	if err := mount.UnmountIfMounted(b.mounter, target); err != nil {
+		return fmt.Errorf("failed to unmount archive: %w", err)
+	}
+	if err := os.Remove(filepath.Join(stagingPath, stagedMarker)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return fmt.Errorf("failed to remove staged content: %w", err)
+	}
+
+	return nil
+}
+
+// Publish bind-mounts the staged content at the target path. Volumes that
+// share the cache are always mounted read-only.
+func (b *Backend) Publish(ctx context.Context, volume *types.Volume, stagingPath, targetPath string, readOnly bool) error {
+	readOnly = readOnly || !writable(volume)
+
+	b.logger.Info("publishing volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+		"target_path", targetPath,
+		"read_only", readOnly,
+	)
+
+	source := dataPath(stagingPath)
+	if _, err := os.Stat(source); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s does not exist", storage.ErrVolumeNotStaged, source)
+		}
+		return fmt.Errorf("failed to check staging path: %w", err)
+	}
+
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create target path: %w", err)
+	}
+
+	if err := mount.BindMount(b.mounter, source, targetPath, readOnly); err != nil {
+		return fmt.Errorf("failed to bind mount: %w", err)
+	}
+
+	b.logger.Info("volume published successfully",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	return nil
+}
+
+// Unpublish removes the bind mount at the target path
+func (b *Backend) Unpublish(ctx context.Context, volume *types.Volume, targetPath string) error {
+	b.logger.Info("unpublishing volume",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, targetPath); err != nil {
+		return fmt.Errorf("failed to remove bind mount: %w", err)
+	}
+
+	b.logger.Info("volume unpublished successfully",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
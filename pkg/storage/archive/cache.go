@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// errNotUploaded is returned when the manager has no archive for a volume
+var errNotUploaded = errors.New("no archive has been uploaded for the volume")
+
+// cacheLocks serializes filling the cache entry of one checksum, so volumes
+// seeded from the same archive download and extract it once
+var cacheLocks sync.Map
+
+// ensureCached returns the directory holding the extracted archive with the
+// given SHA-256, downloading it from url and extracting it first if it is
+// not cached on this node yet. The download is verified against the checksum
+// before anything is extracted, and the entry only appears in the cache once
+// it is complete.
+func (b *Backend) ensureCached(ctx context.Context, url, sum string) (string, error) {
+	entry := filepath.Join(b.cacheDir, sum)
+
+	lock, _ := cacheLocks.LoadOrStore(sum, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if info, err := os.Stat(entry); err == nil && info.IsDir() {
+		b.logger.Debug("using cached archive", "sha256", sum)
+		return entry, nil
+	}
+
+	if err := os.MkdirAll(b.cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive cache: %w", err)
+	}
+
+	archiveFile, err := os.CreateTemp(b.cacheDir, ".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archiveFile.Name())
+
+	b.logger.Info("downloading archive", "url", url, "sha256", sum)
+
+	got, err := download(ctx, url, archiveFile)
+	if closeErr := archiveFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if got != sum {
+		return "", fmt.Errorf("checksum mismatch for %s: expected sha256:%s, got sha256:%s", url, sum, got)
+	}
+
+	tmpDir, err := os.MkdirTemp(b.cacheDir, ".extract-")
+	if err != nil {
+		return "", err
+	}
+	if err := Extract(archiveFile.Name(), tmpDir); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to extract archive: %w", err)
+	}
+	// MkdirTemp creates the directory as 0700, which would hide the tree
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return "", err
+	}
+	if err := os.Rename(tmpDir, entry); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to add archive to cache: %w", err)
+	}
+
+	b.logger.Info("archive cached", "sha256", sum, "path", entry)
+
+	return entry, nil
+}
+
+// download writes the body of url to w and returns its hex-encoded SHA-256
+func download(ctx context.Context, url string, w io.Writer) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(w, io.TeeReader(resp.Body, h)); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,418 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive formats, detected from the leading bytes of the file
+const (
+	formatTar   = "tar"
+	formatTarGz = "tar.gz"
+	formatZip   = "zip"
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	tarMagic  = []byte("ustar")
+)
+
+// maxSymlinkSize bounds the target of a symlink stored in a zip archive
+const maxSymlinkSize = 4096
+
+// errUnsafePath is returned for entries that would land outside the
+// extraction directory
+var errUnsafePath = errors.New("archive entry escapes the volume")
+
+// detectFormat returns the format of the archive at path
+func detectFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return "", errors.New("archive is empty")
+		}
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return formatZip, nil
+	case bytes.HasPrefix(header, gzipMagic):
+		return formatTarGz, nil
+	case len(header) >= 262 && bytes.Equal(header[257:262], tarMagic):
+		return formatTar, nil
+	default:
+		return "", errors.New("not a tar, tar.gz or zip archive")
+	}
+}
+
+// Extract unpacks the archive at archivePath into dest, which is created if
+// needed. Entries whose path leaves dest, directly or through a symlink
+// created by an earlier entry, fail the extraction. Symlinks are recreated
+// as-is but never followed. Modes keep only the permission bits, and
+// ownership from tar archives is applied when running as root. Device
+// nodes and fifos are skipped.
+func Extract(archivePath, dest string) error {
+	format, err := detectFormat(archivePath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	x := &extractor{root: dest, chown: os.Geteuid() == 0}
+
+	switch format {
+	case formatZip:
+		err = x.extractZip(archivePath)
+	case formatTarGz:
+		err = x.extractTarGz(archivePath)
+	default:
+		err = x.extractTarFile(archivePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	return x.finishDirs()
+}
+
+// extractor writes archive entries below root
+type extractor struct {
+	root  string
+	chown bool
+	// Directory modes and mtimes are applied last, once nothing is written
+	// into them anymore
+	dirs []extractedDir
+}
+
+// extractedDir remembers the metadata to apply to an extracted directory
+type extractedDir struct {
+	path  string
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+func (x *extractor) extractTarFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return x.extractTar(f)
+}
+
+func (x *extractor) extractTarGz(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return x.extractTar(gz)
+}
+
+func (x *extractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		target, err := x.entryPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := x.mkdir(target, mode, hdr.ModTime); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := x.writeFile(target, tr, mode, hdr.ModTime); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+			}
+
+		case tar.TypeSymlink:
+			if err := x.symlink(target, hdr.Linkname); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+			}
+
+		case tar.TypeLink:
+			if err := x.hardlink(target, hdr.Linkname); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+			}
+			// The link shares the inode, so ownership is already set
+			continue
+
+		default:
+			// Device nodes, fifos and extended headers are not volume data
+			continue
+		}
+
+		if x.chown {
+			if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+				return err
+			}
+			// chown clears the mode of some files, so it is applied again
+			if hdr.Typeflag == tar.TypeReg {
+				if err := os.Chmod(target, mode); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (x *extractor) extractZip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		target, err := x.entryPath(f.Name)
+		if err != nil {
+			return err
+		}
+		mode := f.Mode()
+
+		switch {
+		case mode.IsDir():
+			perm := mode.Perm()
+			if perm == 0 {
+				// Archives made on Windows carry no permissions
+				perm = 0755
+			}
+			if err := x.mkdir(target, perm, f.Modified); err != nil {
+				return err
+			}
+
+		case mode.IsRegular():
+			perm := mode.Perm()
+			if perm == 0 {
+				perm = 0644
+			}
+			if err := x.extractZipFile(f, target, perm); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+			}
+
+		case mode&fs.ModeSymlink != 0:
+			link, err := readZipSymlink(f)
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+			}
+			if err := x.symlink(target, link); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (x *extractor) extractZipFile(f *zip.File, target string, perm fs.FileMode) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return x.writeFile(target, rc, perm, f.Modified)
+}
+
+// readZipSymlink returns the target of a symlink entry, which zip stores
+// as the content of the entry
+func readZipSymlink(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	link, err := io.ReadAll(io.LimitReader(rc, maxSymlinkSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(link) > maxSymlinkSize {
+		return "", errors.New("symlink target is too long")
+	}
+	return string(link), nil
+}
+
+// entryPath returns where the entry name is extracted to. The name must be
+// relative and stay below root, and none of its existing parents may be a
+// symlink, so a malicious archive cannot write outside root by first
+// creating a link and then extracting through it.
+func (x *extractor) entryPath(name string) (string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s", errUnsafePath, name)
+	}
+	rel = filepath.Clean(rel)
+
+	parent := x.root
+	for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		parent = filepath.Join(parent, part)
+
+		info, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			// The rest of the path is created by the extraction itself
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("%w: %s passes through a non-directory", errUnsafePath, name)
+		}
+	}
+
+	return filepath.Join(x.root, rel), nil
+}
+
+// prepare creates the parents of target and removes an earlier entry of the
+// same name, so the new entry replaces it instead of being written through it
+func (x *extractor) prepare(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is already a directory", target)
+	}
+	return os.Remove(target)
+}
+
+func (x *extractor) mkdir(target string, mode fs.FileMode, mtime time.Time) error {
+	info, err := os.Lstat(target)
+	switch {
+	case os.IsNotExist(err):
+		// Created writable; the archived mode is applied in finishDirs
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return fmt.Errorf("%w: %s is not a directory", errUnsafePath, target)
+	}
+
+	x.dirs = append(x.dirs, extractedDir{path: target, mode: mode, mtime: mtime})
+	return nil
+}
+
+func (x *extractor) writeFile(target string, r io.Reader, mode fs.FileMode, mtime time.Time) error {
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+
+	// O_EXCL never follows a symlink left at target
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// The umask may have masked the mode at creation
+	if err := os.Chmod(target, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(target, time.Time{}, mtime)
+}
+
+func (x *extractor) symlink(target, link string) error {
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	return os.Symlink(link, target)
+}
+
+// hardlink links target to the earlier entry linkName, which must be a
+// regular file inside root
+func (x *extractor) hardlink(target, linkName string) error {
+	source, err := x.entryPath(linkName)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("link target %s is not a regular file", linkName)
+	}
+
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	return os.Link(source, target)
+}
+
+// finishDirs applies the archived modes and mtimes of directories, deepest
+// first so setting a parent is not undone by its children
+func (x *extractor) finishDirs() error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		dir := x.dirs[i]
+		if err := os.Chmod(dir.path, dir.mode); err != nil {
+			return err
+		}
+		if !dir.mtime.IsZero() {
+			if err := os.Chtimes(dir.path, time.Time{}, dir.mtime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
@@ -17,10 +19,42 @@ var (
 
 	// ErrVolumeNotStaged is returned when trying to publish a volume that is not staged
 	ErrVolumeNotStaged = errors.New("volume not staged")
+
+	// ErrSnapshotNotSupported is returned when a backend cannot snapshot volumes
+	ErrSnapshotNotSupported = errors.New("backend does not support snapshots")
+
+	// ErrCloneNotSupported is returned when a backend cannot populate a volume from a source
+	ErrCloneNotSupported = errors.New("backend does not support cloning")
+
+	// ErrUsageNotSupported is returned when a backend cannot report usage or capacity
+	ErrUsageNotSupported = errors.New("backend does not support usage reporting")
+
+	// ErrUsageUnknown is returned when a backend cannot tell the usage of a volume
+	ErrUsageUnknown = errors.New("volume usage is unknown")
+
+	// ErrCapacityUnknown is returned when a backend cannot tell the space
+	// available to new volumes
+	ErrCapacityUnknown = errors.New("available capacity is unknown")
+
+	// ErrExpandNotSupported is returned when a backend cannot grow volumes
+	ErrExpandNotSupported = errors.New("backend does not support expansion")
+
+	// ErrRefreshNotSupported is returned when a backend cannot refresh staged content
+	ErrRefreshNotSupported = errors.New("backend does not support refresh")
+
+	// ErrHealthCheckNotSupported is returned when a backend cannot check the health of volumes
+	ErrHealthCheckNotSupported = errors.New("backend does not support health checks")
+
+	// ErrUploadNotSupported is returned when a backend does not accept uploaded content
+	ErrUploadNotSupported = errors.New("backend does not support uploads")
+
+	// ErrInvalidUpload is returned when uploaded content is rejected by the backend
+	ErrInvalidUpload = errors.New("invalid upload")
 )
 
-// Backend defines the interface that all storage backends must implement
-type Backend interface {
+// Controller is the control-plane part of a backend. It runs inside the
+// volume manager and never touches the node where the volume is used.
+type Controller interface {
 	// Name returns the backend name
 	Name() string
 
@@ -30,6 +64,234 @@ type Backend interface {
 	// Validate validates the volume parameters for this backend
 	Validate(params map[string]string) error
 
+	// Provision allocates the backing storage for a new volume
+	Provision(ctx context.Context, volume *types.Volume) error
+
+	// Delete releases the backing storage of a volume
+	Delete(ctx context.Context, volume *types.Volume) error
+}
+
+// Snapshotter is implemented by controllers that can take point-in-time
+// copies of a volume. It is optional and detected with a type assertion.
+type Snapshotter interface {
+	// CreateSnapshot copies the data of volume and records where it was
+	// stored in snapshot.Parameters, along with snapshot.SizeBytes
+	CreateSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error
+
+	// DeleteSnapshot removes the data of a snapshot
+	DeleteSnapshot(ctx context.Context, snapshot *types.Snapshot) error
+}
+
+// GetSnapshotter returns the snapshot support of a backend by name
+func GetSnapshotter(name string) (Snapshotter, error) {
+	controller, err := GetController(name)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotter, ok := controller.(Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotSupported, name)
+	}
+	return snapshotter, nil
+}
+
+// NodeSnapshotter is implemented by backends of node-local volumes, whose
+// data can only be copied on the node it lives on. The manager records the
+// snapshot and the node plugin of that node creates and removes its data. It
+// is optional and detected with a type assertion.
+type NodeSnapshotter interface {
+	// NodeCreateSnapshot copies the data of volume and records where it was
+	// stored in snapshot.Parameters, along with snapshot.SizeBytes. It may be
+	// retried after a crash and replaces a partial copy.
+	NodeCreateSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error
+
+	// NodeDeleteSnapshot removes the data of a snapshot. A snapshot whose data
+	// was never stored is a no-op.
+	NodeDeleteSnapshot(ctx context.Context, snapshot *types.Snapshot) error
+}
+
+// GetNodeSnapshotter returns the node-side snapshot support of a backend by name
+func GetNodeSnapshotter(name string) (NodeSnapshotter, error) {
+	node, err := GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotter, ok := node.(NodeSnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotSupported, name)
+	}
+	return snapshotter, nil
+}
+
+// Cloner is implemented by controllers that can populate a newly provisioned
+// volume from an existing volume or snapshot of the same backend. It is
+// optional and detected with a type assertion.
+type Cloner interface {
+	// CloneVolume copies the data of source into volume
+	CloneVolume(ctx context.Context, volume, source *types.Volume) error
+
+	// RestoreSnapshot copies the data of snapshot into volume
+	RestoreSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error
+}
+
+// GetCloner returns the clone support of a backend by name
+func GetCloner(name string) (Cloner, error) {
+	controller, err := GetController(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cloner, ok := controller.(Cloner)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCloneNotSupported, name)
+	}
+	return cloner, nil
+}
+
+// UsageReporter is implemented by controllers that can report how full a
+// volume is and how much space is left for new volumes. It is optional and
+// detected with a type assertion. The usage of a node-local volume can only
+// be measured on the node holding its data, whose node plugin reports it to
+// the manager.
+type UsageReporter interface {
+	// Usage reports the space and inodes used by a volume
+	Usage(ctx context.Context, volume *types.Volume) (*types.VolumeUsage, error)
+
+	// AvailableCapacity reports the bytes available to new volumes created
+	// with params
+	AvailableCapacity(ctx context.Context, params map[string]string) (int64, error)
+}
+
+// GetUsageReporter returns the usage reporting of a backend by name
+func GetUsageReporter(name string) (UsageReporter, error) {
+	controller, err := GetController(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reporter, ok := controller.(UsageReporter)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUsageNotSupported, name)
+	}
+	return reporter, nil
+}
+
+// Expander is implemented by backends whose volumes can grow. Like Backend
+// it has a control-plane and a node-side half. It is optional and detected
+// with a type assertion.
+type Expander interface {
+	// ExpandVolume raises the limit of volume to capacityBytes in the backing
+	// storage. volume.CapacityBytes still holds the old capacity.
+	ExpandVolume(ctx context.Context, volume *types.Volume, capacityBytes int64) error
+
+	// NodeExpandVolume grows the filesystem of a volume staged on this node
+	// to volume.CapacityBytes. volumePath is where the volume is staged or
+	// published. It must be a no-op if there is nothing to grow.
+	NodeExpandVolume(ctx context.Context, volume *types.Volume, volumePath string) error
+}
+
+// GetExpander returns the expansion support of a backend by name
+func GetExpander(name string) (Expander, error) {
+	backend, err := GetBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	expander, ok := backend.(Expander)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrExpandNotSupported, name)
+	}
+	return expander, nil
+}
+
+// Refresher is implemented by backends whose content follows a source that
+// can change after the volume is staged, such as a git branch. It is
+// optional and detected with a type assertion.
+type Refresher interface {
+	// Refresh brings the content staged at stagingPath up to date with the
+	// source described by volume.Parameters
+	Refresh(ctx context.Context, volume *types.Volume, stagingPath string) error
+}
+
+// GetRefresher returns the refresh support of a backend by name
+func GetRefresher(name string) (Refresher, error) {
+	backend, err := GetBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	refresher, ok := backend.(Refresher)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRefreshNotSupported, name)
+	}
+	return refresher, nil
+}
+
+// HealthChecker is implemented by backends that can tell whether a volume
+// staged on the node is still usable. It is optional and detected with a
+// type assertion.
+type HealthChecker interface {
+	// CheckHealth returns an error describing what is wrong with the volume
+	// staged at stagingPath, or nil if it is healthy
+	CheckHealth(ctx context.Context, volume *types.Volume, stagingPath string) error
+}
+
+// GetHealthChecker returns the health check support of a backend by name
+func GetHealthChecker(name string) (HealthChecker, error) {
+	node, err := GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+
+	checker, ok := node.(HealthChecker)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrHealthCheckNotSupported, name)
+	}
+	return checker, nil
+}
+
+// Uploader is implemented by controllers whose volumes are seeded from
+// content uploaded to the volume manager. It is optional and detected with a
+// type assertion.
+type Uploader interface {
+	// UploadOwner returns the base URL of the volume manager replica that
+	// holds the content uploaded for volume, or "" if it is this replica or
+	// nothing was uploaded yet. Requests for the content are proxied there.
+	UploadOwner(volume *types.Volume) string
+
+	// Upload stores the content read from r for volume and returns the
+	// parameters to merge into volume.Parameters. The content the current
+	// parameters refer to stays available until they are replaced.
+	Upload(ctx context.Context, volume *types.Volume, r io.Reader) (map[string]string, error)
+
+	// OpenUpload opens the content volume.Parameters refer to. The caller
+	// must close it. The error matches fs.ErrNotExist if nothing was
+	// uploaded.
+	OpenUpload(ctx context.Context, volume *types.Volume) (*os.File, error)
+
+	// DeleteUpload removes the content this replica holds for volume
+	DeleteUpload(ctx context.Context, volume *types.Volume) error
+}
+
+// GetUploader returns the upload support of a backend by name
+func GetUploader(name string) (Uploader, error) {
+	controller, err := GetController(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uploader, ok := controller.(Uploader)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUploadNotSupported, name)
+	}
+	return uploader, nil
+}
+
+// Node is the node-side part of a backend. It runs inside the CSI node
+// plugin on the host where the container using the volume is scheduled.
+type Node interface {
 	// Stage prepares the volume on a node (download, extract, etc.)
 	Stage(ctx context.Context, volume *types.Volume, stagingPath string) error
 
@@ -43,6 +305,12 @@ type Backend interface {
 	Unpublish(ctx context.Context, volume *types.Volume, targetPath string) error
 }
 
+// Backend defines the interface that all storage backends must implement
+type Backend interface {
+	Controller
+	Node
+}
+
 // Factory is a function that creates a new backend instance
 type Factory func() (Backend, error)
 
@@ -67,6 +335,16 @@ func GetBackend(name string) (Backend, error) {
 	return factory()
 }
 
+// GetController returns the control-plane part of a backend by name
+func GetController(name string) (Controller, error) {
+	return GetBackend(name)
+}
+
+// GetNode returns the node-side part of a backend by name
+func GetNode(name string) (Node, error) {
+	return GetBackend(name)
+}
+
 // ListBackends returns a list of all registered backends
 func ListBackends() ([]types.Backend, error) {
 	backends := make([]types.Backend, 0, len(registry))
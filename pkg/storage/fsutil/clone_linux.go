@@ -0,0 +1,53 @@
+//go:build linux
+
+package fsutil
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile shares the extents of src with dst using the FICLONE ioctl.
+// It fails on filesystems without reflink support (e.g. ext4), in which
+// case the caller falls back to a regular copy.
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
+
+// copySparse copies the first size bytes of src to dst, skipping the holes
+// found with SEEK_DATA and SEEK_HOLE so they stay holes in dst. Filesystems
+// without hole detection report the whole file as data.
+func copySparse(dst, src *os.File, size int64) error {
+	var offset int64
+	for offset < size {
+		start, err := src.Seek(offset, unix.SEEK_DATA)
+		if err != nil {
+			if errors.Is(err, unix.ENXIO) {
+				// Only a hole is left
+				break
+			}
+			return err
+		}
+		end, err := src.Seek(start, unix.SEEK_HOLE)
+		if err != nil {
+			return err
+		}
+
+		if _, err := src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := dst.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, src, end-start); err != nil {
+			return err
+		}
+		offset = end
+	}
+
+	// A trailing hole only exists once the size is set
+	return dst.Truncate(size)
+}
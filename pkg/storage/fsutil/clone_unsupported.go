@@ -0,0 +1,21 @@
+//go:build !linux
+
+package fsutil
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// cloneFile is not available on this platform, so files are always copied
+func cloneFile(dst, src *os.File) error {
+	return errors.New("reflink not supported on this platform")
+}
+
+// copySparse copies src to dst. Holes are not detected on this platform, so
+// they are written out as zeros.
+func copySparse(dst, src *os.File, size int64) error {
+	_, err := io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,177 @@
+package fsutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CopyTree copies the directory tree at src to dst, which must not exist yet
+// or be an empty directory. Regular files are reflinked when the filesystem
+// supports it and copied otherwise; symlinks are recreated as-is. Ownership,
+// modes, extended attributes and mtimes are preserved.
+// It returns the number of bytes in the copied regular files.
+func CopyTree(src, dst string) (int64, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return 0, fmt.Errorf("%s is not a directory", src)
+	}
+	if entries, err := os.ReadDir(dst); err == nil {
+		if len(entries) > 0 {
+			return 0, fmt.Errorf("%s is not empty", dst)
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	var size int64
+	// Directory mtimes change as entries are created, so they are applied last
+	var dirs []dirTimes
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			// The top-level directory may already exist as an empty directory
+			if err := os.Mkdir(target, mode.Perm()); err != nil && !(rel == "." && os.IsExist(err)) {
+				return err
+			}
+			if err := copyMetadata(path, target, info); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTimes{path: target, mtime: info.ModTime()})
+
+		case mode.IsRegular():
+			if err := copyFile(path, target, info); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", path, err)
+			}
+			size += info.Size()
+
+		case mode&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			if err := copyOwnership(target, info); err != nil {
+				return err
+			}
+
+		default:
+			// Sockets, fifos and device nodes are not volume data
+			return nil
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].path, time.Time{}, dirs[i].mtime); err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// CopyFile copies the regular file at src to dst, which must not exist yet.
+// Like the files in CopyTree, it is reflinked when the filesystem supports it
+// and keeps its ownership, mode, extended attributes and mtime. Holes in
+// sparse files such as disk images are preserved.
+// It returns the size of the file.
+func CopyFile(src, dst string) (int64, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("%s is not a regular file", src)
+	}
+
+	if err := copyFile(src, dst, info); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// dirTimes remembers the modification time to restore on a copied directory
+type dirTimes struct {
+	path  string
+	mtime time.Time
+}
+
+// copyFile copies a single regular file, preferring a reflink
+func copyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := cloneFile(out, in); err != nil {
+		if err := copySparse(out, in, info.Size()); err != nil {
+			out.Close()
+			return err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := copyMetadata(src, dst, info); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, time.Time{}, info.ModTime())
+}
+
+// copyMetadata applies the ownership, mode and extended attributes of src to
+// dst. Ownership comes first because chown clears the setuid and setgid bits
+// and file capabilities.
+func copyMetadata(src, dst string, info fs.FileInfo) error {
+	if err := copyOwnership(dst, info); err != nil {
+		return err
+	}
+
+	// Mkdir and OpenFile are subject to the umask, so set the mode explicitly
+	if err := os.Chmod(dst, permBits(info.Mode())); err != nil {
+		return err
+	}
+
+	return copyXattrs(src, dst)
+}
+
+// permBits returns the permission bits of mode including setuid, setgid and sticky
+func permBits(mode fs.FileMode) fs.FileMode {
+	return mode.Perm() | mode&(fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyOwnership sets the owner and group of path to those in info
+func copyOwnership(path string, info fs.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return os.Lchown(path, int(st.Uid), int(st.Gid))
+}
+
+// copyXattrs copies the extended attributes of src to dst. Attributes are
+// skipped when either filesystem does not support them.
+func copyXattrs(src, dst string) error {
+	size, err := unix.Llistxattr(src, nil)
+	if err != nil {
+		if xattrUnsupported(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list xattrs of %s: %w", src, err)
+	}
+	if size == 0 {
+		return nil
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(src, buf)
+	if err != nil {
+		return fmt.Errorf("failed to list xattrs of %s: %w", src, err)
+	}
+
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		valueSize, err := unix.Lgetxattr(src, name, nil)
+		if err != nil {
+			return fmt.Errorf("failed to read xattr %s of %s: %w", name, src, err)
+		}
+
+		value := make([]byte, valueSize)
+		if _, err := unix.Lgetxattr(src, name, value); err != nil {
+			return fmt.Errorf("failed to read xattr %s of %s: %w", name, src, err)
+		}
+
+		if err := unix.Lsetxattr(dst, name, value, 0); err != nil {
+			if xattrUnsupported(err) {
+				continue
+			}
+			return fmt.Errorf("failed to set xattr %s on %s: %w", name, dst, err)
+		}
+	}
+
+	return nil
+}
+
+// xattrUnsupported reports whether err means the filesystem has no xattr support
+func xattrUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP)
+}
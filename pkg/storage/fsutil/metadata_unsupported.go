@@ -0,0 +1,15 @@
+//go:build !linux
+
+package fsutil
+
+import "io/fs"
+
+// copyOwnership is a no-op on platforms without POSIX ownership
+func copyOwnership(path string, info fs.FileInfo) error {
+	return nil
+}
+
+// copyXattrs is a no-op on platforms without Linux extended attributes
+func copyXattrs(src, dst string) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package fsutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// SyncFS writes the cached data of the filesystem holding path to its device
+func SyncFS(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return unix.Syncfs(int(f.Fd()))
+}
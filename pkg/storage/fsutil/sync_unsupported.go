@@ -0,0 +1,10 @@
+//go:build !linux
+
+package fsutil
+
+import "errors"
+
+// SyncFS is not available on this platform
+func SyncFS(path string) error {
+	return errors.New("syncfs not supported on this platform")
+}
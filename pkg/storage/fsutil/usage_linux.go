@@ -0,0 +1,42 @@
+//go:build linux
+
+package fsutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// Usage reports the space and inodes of the filesystem holding path. For a
+// directory under a project quota, ext4 and XFS report the quota instead.
+func Usage(path string) (*types.VolumeUsage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+
+	blockSize := int64(st.Bsize)
+	return &types.VolumeUsage{
+		TotalBytes:      int64(st.Blocks) * blockSize,
+		UsedBytes:       int64(st.Blocks-st.Bfree) * blockSize,
+		AvailableBytes:  int64(st.Bavail) * blockSize,
+		TotalInodes:     int64(st.Files),
+		UsedInodes:      int64(st.Files - st.Ffree),
+		AvailableInodes: int64(st.Ffree),
+	}, nil
+}
+
+// Allocated reports the disk space allocated to the file at path, which is
+// less than its size for sparse files
+func Allocated(path string) (int64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	// st_blocks is always in 512-byte units
+	return st.Blocks * 512, nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package fsutil
+
+import (
+	"errors"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// Usage is not available on this platform
+func Usage(path string) (*types.VolumeUsage, error) {
+	return nil, errors.New("filesystem usage not supported on this platform")
+}
+
+// Allocated is not available on this platform
+func Allocated(path string) (int64, error) {
+	return 0, errors.New("allocated size not supported on this platform")
+}
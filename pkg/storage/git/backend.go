@@ -0,0 +1,337 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// defaultRef is checked out unless a volume sets the ref parameter
+const defaultRef = "HEAD"
+
+// stagingLocks serializes git operations on one staging path, so a refresh
+// never runs while the same volume is staged or unstaged
+var stagingLocks sync.Map
+
+func init() {
+	// Register the git backend on import
+	if err := storage.RegisterBackend("git", NewBackend); err != nil {
+		panic(fmt.Sprintf("failed to register git backend: %v", err))
+	}
+}
+
+// Backend implements a storage backend that checks out a ref of a git
+// repository on the node and publishes it read-only
+type Backend struct {
+	mounter mount.Mounter
+	logger  *slog.Logger
+}
+
+// NewBackend creates a new git backend
+func NewBackend() (storage.Backend, error) {
+	return &Backend{
+		mounter: mount.New(),
+		logger:  slog.Default().With("backend", "git"),
+	}, nil
+}
+
+// Name returns the backend name
+func (b *Backend) Name() string {
+	return "git"
+}
+
+// Capabilities returns the backend capabilities
+func (b *Backend) Capabilities() types.BackendCapability {
+	return types.BackendCapability{
+		SupportsReadOnly: true,
+		AccessModes: []types.AccessMode{
+			types.AccessModeMultiNodeReaderOnly,
+			types.AccessModeSingleNodeReaderOnly,
+		},
+	}
+}
+
+// Validate validates the volume parameters
+func (b *Backend) Validate(params map[string]string) error {
+	repo := params["repo"]
+	if repo == "" {
+		return errors.New("parameter 'repo' is required")
+	}
+	// Both are passed to git as arguments, so they must not look like options
+	if strings.HasPrefix(repo, "-") {
+		return fmt.Errorf("invalid repo: %s", repo)
+	}
+	if ref := params["ref"]; strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n:") {
+		return fmt.Errorf("invalid ref: %s", ref)
+	}
+
+	if subpath := params["subpath"]; subpath != "" && !filepath.IsLocal(subpath) {
+		return fmt.Errorf("subpath must be a relative path inside the repository: %s", subpath)
+	}
+
+	return nil
+}
+
+// Provision allocates the backing storage for a new volume
+func (b *Backend) Provision(ctx context.Context, volume *types.Volume) error {
+	// The repository is external; nodes fetch it when staging
+	b.logger.Info("provisioned volume",
+		"volume_id", volume.ID,
+		"repo", volume.Parameters["repo"],
+		"ref", ref(volume),
+	)
+
+	return nil
+}
+
+// Delete releases the backing storage of a volume
+func (b *Backend) Delete(ctx context.Context, volume *types.Volume) error {
+	// The repository is not ours to remove
+	b.logger.Info("deleted volume",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
+
+// ref returns the ref a volume follows
+func ref(volume *types.Volume) string {
+	if r := volume.Parameters["ref"]; r != "" {
+		return r
+	}
+	return defaultRef
+}
+
+// gitDir returns where the repository metadata of a volume is kept. It is
+// separate from the checkout, so .git is not published.
+func gitDir(stagingPath string) string {
+	return filepath.Join(stagingPath, "git")
+}
+
+// treePath returns where the ref of a volume is checked out
+func treePath(stagingPath string) string {
+	return filepath.Join(stagingPath, "tree")
+}
+
+// lockStagingPath locks the staging path of a volume and returns the unlock
+// function
+func lockStagingPath(stagingPath string) func() {
+	lock, _ := stagingLocks.LoadOrStore(stagingPath, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Stage clones the repository into the staging path, or fetches into the
+// clone left by an earlier stage, and checks out the ref of the volume
+func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("staging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	defer lockStagingPath(stagingPath)()
+
+	if err := b.checkout(ctx, volume, stagingPath); err != nil {
+		return err
+	}
+
+	b.logger.Info("volume staged successfully",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
+
+// Refresh fetches the ref of the volume again and checks it out in place.
+// Containers see the new files without being restarted.
+func (b *Backend) Refresh(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	defer lockStagingPath(stagingPath)()
+
+	if _, err := os.Stat(gitDir(stagingPath)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: no clone at %s", storage.ErrVolumeNotStaged, stagingPath)
+		}
+		return err
+	}
+
+	return b.checkout(ctx, volume, stagingPath)
+}
+
+// checkout fetches the ref of volume into the clone in stagingPath, creating
+// the clone first if needed, and checks it out. Only the tip of the ref is
+// fetched.
+func (b *Backend) checkout(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	repo := volume.Parameters["repo"]
+	dir := gitDir(stagingPath)
+	tree := treePath(stagingPath)
+
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); os.IsNotExist(err) {
+		// A clone interrupted by an earlier attempt is started over
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to clear staging path: %w", err)
+		}
+		if err := runGit(ctx, "", "init", "--quiet", "--bare", dir); err != nil {
+			return err
+		}
+		if err := runGit(ctx, dir, "remote", "add", "origin", repo); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to check staging path: %w", err)
+	} else if err := runGit(ctx, dir, "remote", "set-url", "origin", repo); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(tree, 0755); err != nil {
+		return fmt.Errorf("failed to create checkout directory: %w", err)
+	}
+
+	if err := runGit(ctx, dir, "fetch", "--quiet", "--depth", "1", "--no-tags", "origin", ref(volume)); err != nil {
+		return err
+	}
+	if err := runGit(ctx, dir, "--work-tree="+tree, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return err
+	}
+
+	commit, err := outputGit(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+
+	b.logger.Info("checked out repository",
+		"volume_id", volume.ID,
+		"repo", repo,
+		"ref", ref(volume),
+		"commit", commit,
+	)
+
+	return nil
+}
+
+// runGit runs a git command against the repository in gitDir, or outside
+// any repository if gitDir is empty
+func runGit(ctx context.Context, gitDir string, args ...string) error {
+	_, err := outputGit(ctx, gitDir, args...)
+	return err
+}
+
+// outputGit runs a git command like runGit and returns its trimmed output
+func outputGit(ctx context.Context, gitDir string, args ...string) (string, error) {
+	// The first argument that is not a global option names the command
+	command := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			command = arg
+			break
+		}
+	}
+	if gitDir != "" {
+		args = append([]string{"--git-dir", gitDir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	// Never wait for credentials on a terminal that is not there
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", command, err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Unstage removes the clone from the staging path
+func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("unstaging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	defer lockStagingPath(stagingPath)()
+
+	if err := os.RemoveAll(treePath(stagingPath)); err != nil {
+		return fmt.Errorf("failed to remove checkout: %w", err)
+	}
+	if err := os.RemoveAll(gitDir(stagingPath)); err != nil {
+		return fmt.Errorf("failed to remove clone: %w", err)
+	}
+
+	return nil
+}
+
+// Publish bind-mounts the checkout, or its subpath, read-only at the target
+// path
+func (b *Backend) Publish(ctx context.Context, volume *types.Volume, stagingPath, targetPath string, readOnly bool) error {
+	b.logger.Info("publishing volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+		"target_path", targetPath,
+	)
+
+	source := treePath(stagingPath)
+	if _, err := os.Stat(filepath.Join(gitDir(stagingPath), "HEAD")); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: no clone at %s", storage.ErrVolumeNotStaged, stagingPath)
+		}
+		return fmt.Errorf("failed to check staging path: %w", err)
+	}
+
+	if subpath := volume.Parameters["subpath"]; subpath != "" {
+		// The subpath comes from the repository, which may have made any part
+		// of it a symlink pointing anywhere on the node
+		for _, part := range strings.Split(filepath.Clean(subpath), string(filepath.Separator)) {
+			source = filepath.Join(source, part)
+			info, err := os.Lstat(source)
+			if err != nil {
+				return fmt.Errorf("subpath %s not found in the repository: %w", subpath, err)
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("subpath %s is not a directory", subpath)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create target path: %w", err)
+	}
+
+	// The checkout is owned by git, so it is never published writable
+	if err := mount.BindMount(b.mounter, source, targetPath, true); err != nil {
+		return fmt.Errorf("failed to bind mount: %w", err)
+	}
+
+	b.logger.Info("volume published successfully",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	return nil
+}
+
+// Unpublish removes the bind mount at the target path
+func (b *Backend) Unpublish(ctx context.Context, volume *types.Volume, targetPath string) error {
+	b.logger.Info("unpublishing volume",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, targetPath); err != nil {
+		return fmt.Errorf("failed to remove bind mount: %w", err)
+	}
+
+	b.logger.Info("volume unpublished successfully",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount/mounttest"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/storagetest"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+func newTestBackend(m *mounttest.FakeMounter) *Backend {
+	return &Backend{
+		mounter: m,
+		logger:  storagetest.Logger(),
+	}
+}
+
+// testRepo is a bare repository to stage from, with a work tree to commit to
+type testRepo struct {
+	t    *testing.T
+	url  string
+	work string
+}
+
+// newTestRepo creates a bare repository whose main branch holds files
+func newTestRepo(t *testing.T, files map[string]string) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "origin.git")
+	r := &testRepo{t: t, url: "file://" + bare, work: filepath.Join(dir, "work")}
+
+	r.git("", "init", "--quiet", "--bare", bare)
+	r.git(bare, "symbolic-ref", "HEAD", "refs/heads/main")
+	r.git("", "init", "--quiet", r.work)
+	r.git(r.work, "remote", "add", "origin", bare)
+	r.commit(files)
+
+	return r
+}
+
+// git runs a git command in dir
+func (r *testRepo) git(dir string, args ...string) {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		r.t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+// commit writes files to the work tree, removing those with empty content,
+// and pushes the result to main
+func (r *testRepo) commit(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.work, name)
+		if content == "" {
+			if err := os.Remove(path); err != nil {
+				r.t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git(r.work, "add", "--all")
+	r.git(r.work, "commit", "--quiet", "--message", "update")
+	r.git(r.work, "push", "--quiet", "origin", "HEAD:refs/heads/main")
+}
+
+func newTestVolume(repo string, params map[string]string) *types.Volume {
+	return storagetest.NewVolume("git", map[string]string{"repo": repo}, params)
+}
+
+// readTree returns the content of a file in the checkout, or "" if it is missing
+func readTree(t *testing.T, stagingPath, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(treePath(stagingPath), name))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestValidate(t *testing.T) {
+	b := newTestBackend(&mounttest.FakeMounter{})
+	valid := map[string]string{"repo": "https://example.com/site.git", "ref": "v1.2", "subpath": "docs/public"}
+	storagetest.RunValidate(t, b.Validate, valid, map[string]map[string]string{
+		"no repo":           {"repo": ""},
+		"repo option":       {"repo": "--upload-pack=touch /tmp/x"},
+		"ref option":        {"ref": "--output=/tmp/x"},
+		"ref with space":    {"ref": "main extra"},
+		"ref refspec":       {"ref": "main:refs/heads/x"},
+		"absolute subpath":  {"subpath": "/etc"},
+		"subpath outside":   {"subpath": "../etc"},
+		"subpath traversal": {"subpath": "docs/../../etc"},
+	})
+}
+
+func TestStage(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"index.html": "v1", "docs/readme.md": "docs"})
+	b := newTestBackend(&mounttest.FakeMounter{})
+	stagingPath := t.TempDir()
+
+	if err := b.Stage(context.Background(), newTestVolume(repo.url, map[string]string{"ref": "main"}), stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	if got := readTree(t, stagingPath, "index.html"); got != "v1" {
+		t.Errorf("index.html = %q, want v1", got)
+	}
+	if got := readTree(t, stagingPath, "docs/readme.md"); got != "docs" {
+		t.Errorf("docs/readme.md = %q, want docs", got)
+	}
+	// The metadata is kept outside the published checkout
+	if _, err := os.Stat(filepath.Join(treePath(stagingPath), ".git")); !os.IsNotExist(err) {
+		t.Errorf(".git is in the checkout: %v", err)
+	}
+
+	// Staging again fetches into the existing clone
+	repo.commit(map[string]string{"index.html": "v2"})
+	if err := b.Stage(context.Background(), newTestVolume(repo.url, nil), stagingPath); err != nil {
+		t.Fatalf("Stage() again error = %v", err)
+	}
+	if got := readTree(t, stagingPath, "index.html"); got != "v2" {
+		t.Errorf("index.html = %q, want v2", got)
+	}
+
+	if err := b.Unstage(context.Background(), newTestVolume(repo.url, nil), stagingPath); err != nil {
+		t.Fatalf("Unstage() error = %v", err)
+	}
+	for _, path := range []string{treePath(stagingPath), gitDir(stagingPath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after unstage: %v", path, err)
+		}
+	}
+}
+
+func TestStageUnknownRef(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"index.html": "v1"})
+
+	err := newTestBackend(&mounttest.FakeMounter{}).Stage(context.Background(), newTestVolume(repo.url, map[string]string{"ref": "missing"}), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "git fetch failed") {
+		t.Fatalf("Stage() error = %v, want a failed fetch", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"index.html": "v1", "old.html": "old"})
+	b := newTestBackend(&mounttest.FakeMounter{})
+	stagingPath := t.TempDir()
+	volume := newTestVolume(repo.url, map[string]string{"ref": "main"})
+
+	if err := b.Stage(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	repo.commit(map[string]string{"index.html": "v2", "old.html": "", "new.html": "new"})
+
+	if err := b.Refresh(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	for name, want := range map[string]string{"index.html": "v2", "new.html": "new", "old.html": ""} {
+		if got := readTree(t, stagingPath, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRefreshNotStaged(t *testing.T) {
+	err := newTestBackend(&mounttest.FakeMounter{}).Refresh(context.Background(), newTestVolume("file:///nonexistent", nil), t.TempDir())
+	if !errors.Is(err, storage.ErrVolumeNotStaged) {
+		t.Fatalf("Refresh() error = %v, want %v", err, storage.ErrVolumeNotStaged)
+	}
+}
+
+func TestPublishSubpath(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"index.html": "v1", "docs/public/readme.md": "docs"})
+
+	// A repository can point symlinks anywhere on the node
+	for name, target := range map[string]string{"etc": "/etc", "docs/up": ".."} {
+		if err := os.Symlink(target, filepath.Join(repo.work, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.commit(nil)
+
+	m := &mounttest.FakeMounter{}
+	b := newTestBackend(m)
+	dir := t.TempDir()
+	stagingPath := filepath.Join(dir, "staging")
+	if err := b.Stage(context.Background(), newTestVolume(repo.url, nil), stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		targetPath := filepath.Join(dir, "target")
+		if err := b.Publish(context.Background(), newTestVolume(repo.url, map[string]string{"subpath": "docs/public"}), stagingPath, targetPath, false); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+
+		got, ok := m.MountAt(targetPath)
+		if !ok || got.Source != filepath.Join(treePath(stagingPath), "docs", "public") {
+			t.Fatalf("mount = %+v, %v, want the subpath", got, ok)
+		}
+		// The checkout is published read-only even if read-write was asked for
+		if !got.ReadOnly() {
+			t.Error("mount is read-write, want read-only")
+		}
+	})
+
+	for _, subpath := range []string{"etc", "etc/ssl", "docs/up", "docs/up/docs", "index.html", "missing"} {
+		t.Run(subpath, func(t *testing.T) {
+			targetPath := filepath.Join(dir, "target-"+strings.ReplaceAll(subpath, "/", "-"))
+			err := b.Publish(context.Background(), newTestVolume(repo.url, map[string]string{"subpath": subpath}), stagingPath, targetPath, true)
+			if err == nil {
+				t.Fatal("Publish() error = nil, want the subpath rejected")
+			}
+			if mounted, _ := m.IsMountPoint(targetPath); mounted {
+				t.Error("target path was mounted")
+			}
+		})
+	}
+}
+
+func TestPublishNotStaged(t *testing.T) {
+	dir := t.TempDir()
+
+	err := newTestBackend(&mounttest.FakeMounter{}).Publish(context.Background(), newTestVolume("file:///nonexistent", nil), filepath.Join(dir, "staging"), filepath.Join(dir, "target"), true)
+	if !errors.Is(err, storage.ErrVolumeNotStaged) {
+		t.Fatalf("Publish() error = %v, want %v", err, storage.ErrVolumeNotStaged)
+	}
+}
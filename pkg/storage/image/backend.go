@@ -0,0 +1,504 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/fsutil"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/imagefs"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// defaultDataDir matches the DATA_DIR default of the volume manager
+const defaultDataDir = "/var/lib/volume-manager"
+
+func init() {
+	// Register the image backend on import
+	if err := storage.RegisterBackend("image", NewBackend); err != nil {
+		panic(fmt.Sprintf("failed to register image backend: %v", err))
+	}
+}
+
+// Backend implements a storage backend that gives every volume its own
+// filesystem in a sparse image file, mounted through a loop device
+type Backend struct {
+	mounter  mount.Mounter
+	imageDir string
+	logger   *slog.Logger
+}
+
+// NewBackend creates a new image file backend. Images are kept in
+// DATA_DIR/images unless a volume sets the image_dir parameter.
+func NewBackend() (storage.Backend, error) {
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = defaultDataDir
+	}
+
+	return &Backend{
+		mounter:  mount.New(),
+		imageDir: filepath.Join(dataDir, "images"),
+		logger:   slog.Default().With("backend", "image"),
+	}, nil
+}
+
+// Name returns the backend name
+func (b *Backend) Name() string {
+	return "image"
+}
+
+// Capabilities returns the backend capabilities
+func (b *Backend) Capabilities() types.BackendCapability {
+	return types.BackendCapability{
+		SupportsReadOnly:  true,
+		SupportsReadWrite: true,
+		SupportsSnapshot:  true,
+		SupportsClone:     true,
+		SupportsCapacity:  true,
+		RequiresCapacity:  true,
+		NodeLocal:         true,
+		AccessModes: []types.AccessMode{
+			types.AccessModeSingleNodeWriter,
+			types.AccessModeSingleNodeMultiWriter,
+			types.AccessModeSingleNodeReaderOnly,
+			types.AccessModeMultiNodeReaderOnly,
+		},
+	}
+}
+
+// Validate validates the volume parameters
+func (b *Backend) Validate(params map[string]string) error {
+	if fsType := params["fs_type"]; fsType != "" && !imagefs.ValidFSType(fsType) {
+		return fmt.Errorf("fs_type must be %s or %s: %s", imagefs.FSExt4, imagefs.FSXFS, fsType)
+	}
+
+	if imageDir := params["image_dir"]; imageDir != "" && !filepath.IsAbs(imageDir) {
+		return fmt.Errorf("image_dir must be absolute: %s", imageDir)
+	}
+
+	return nil
+}
+
+// Provision allocates the backing storage for a new volume
+func (b *Backend) Provision(ctx context.Context, volume *types.Volume) error {
+	// Like the source directory of a local volume, the image lives on the node
+	// that uses the volume, so it is created when the volume is staged there
+	b.logger.Info("provisioned volume",
+		"volume_id", volume.ID,
+		"image_path", b.imagePath(volume),
+	)
+
+	return nil
+}
+
+// Delete removes the image file of a volume. Unlike the host directory of
+// a local volume, the image belongs to the backend.
+func (b *Backend) Delete(ctx context.Context, volume *types.Volume) error {
+	imagePath := b.imagePath(volume)
+
+	if err := os.Remove(imagePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove image %s: %w", imagePath, err)
+	}
+
+	b.logger.Info("deleted volume",
+		"volume_id", volume.ID,
+		"image_path", imagePath,
+	)
+
+	return nil
+}
+
+// CreateSnapshot copies the image file of a volume into a .snapshots
+// directory next to it. The copy is reflinked where the filesystem supports
+// it and sparse otherwise. A mounted image is flushed first if it is mounted
+// on this host, but writes are not paused, so the snapshot is crash-consistent.
+// The copy runs on the volume manager host, so the image must be reachable
+// from there.
+func (b *Backend) CreateSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error {
+	imagePath := b.imagePath(volume)
+	if _, err := os.Stat(imagePath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("volume %s has not been staged yet, so it has no image", volume.ID)
+		}
+		return err
+	}
+
+	if stagingPath, ok := b.localStagingPath(volume); ok {
+		if err := fsutil.SyncFS(stagingPath); err != nil {
+			return fmt.Errorf("failed to flush %s: %w", stagingPath, err)
+		}
+	}
+
+	snapshotDir := filepath.Join(filepath.Dir(imagePath), ".snapshots")
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	snapshotPath := filepath.Join(snapshotDir, snapshot.ID+".img")
+	size, err := fsutil.CopyFile(imagePath, snapshotPath)
+	if err != nil {
+		_ = os.Remove(snapshotPath)
+		return fmt.Errorf("failed to copy %s: %w", imagePath, err)
+	}
+
+	if snapshot.Parameters == nil {
+		snapshot.Parameters = make(map[string]string)
+	}
+	snapshot.Parameters["path"] = snapshotPath
+	snapshot.Parameters["fs_type"] = b.fsType(volume)
+	snapshot.SizeBytes = size
+
+	b.logger.Info("created snapshot",
+		"volume_id", volume.ID,
+		"snapshot_id", snapshot.ID,
+		"snapshot_path", snapshotPath,
+		"size_bytes", size,
+	)
+
+	return nil
+}
+
+// DeleteSnapshot removes the image file of a snapshot
+func (b *Backend) DeleteSnapshot(ctx context.Context, snapshot *types.Snapshot) error {
+	snapshotPath := snapshot.Parameters["path"]
+	if snapshotPath == "" || !filepath.IsAbs(snapshotPath) {
+		return fmt.Errorf("snapshot %s has no valid path", snapshot.ID)
+	}
+
+	if err := os.Remove(snapshotPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", snapshotPath, err)
+	}
+
+	b.logger.Info("deleted snapshot",
+		"snapshot_id", snapshot.ID,
+		"snapshot_path", snapshotPath,
+	)
+
+	return nil
+}
+
+// CloneVolume creates the image of a new volume as a copy of the image of
+// another volume. Like snapshots, the copy runs on the manager host.
+func (b *Backend) CloneVolume(ctx context.Context, volume, source *types.Volume) error {
+	if b.fsType(volume) != b.fsType(source) {
+		return fmt.Errorf("fs_type %s does not match the source's %s", b.fsType(volume), b.fsType(source))
+	}
+	if stagingPath, ok := b.localStagingPath(source); ok {
+		if err := fsutil.SyncFS(stagingPath); err != nil {
+			return fmt.Errorf("failed to flush %s: %w", stagingPath, err)
+		}
+	}
+	return b.populate(volume, b.imagePath(source))
+}
+
+// RestoreSnapshot creates the image of a new volume as a copy of a snapshot
+func (b *Backend) RestoreSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error {
+	if fsType := snapshot.Parameters["fs_type"]; fsType != b.fsType(volume) {
+		return fmt.Errorf("fs_type %s does not match the snapshot's %s", b.fsType(volume), fsType)
+	}
+	return b.populate(volume, snapshot.Parameters["path"])
+}
+
+// populate copies the image at sourcePath to the image of volume. The copy
+// is grown to the volume capacity when the volume is staged.
+func (b *Backend) populate(volume *types.Volume, sourcePath string) error {
+	if sourcePath == "" || !filepath.IsAbs(sourcePath) {
+		return fmt.Errorf("invalid source path: %q", sourcePath)
+	}
+
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	if info.Size() > volume.CapacityBytes {
+		return fmt.Errorf("capacity %d is smaller than the source image of %d bytes", volume.CapacityBytes, info.Size())
+	}
+
+	imagePath := b.imagePath(volume)
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0700); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	if _, err := fsutil.CopyFile(sourcePath, imagePath); err != nil {
+		_ = os.Remove(imagePath)
+		return fmt.Errorf("failed to copy %s: %w", sourcePath, err)
+	}
+
+	b.logger.Info("populated volume",
+		"volume_id", volume.ID,
+		"source_path", sourcePath,
+		"image_path", imagePath,
+	)
+
+	return nil
+}
+
+// Usage reports the usage of the volume filesystem if it is mounted on this
+// host. Otherwise it falls back to the space allocated by the image file. It
+// runs on the node holding the image.
+func (b *Backend) Usage(ctx context.Context, volume *types.Volume) (*types.VolumeUsage, error) {
+	if stagingPath, ok := b.localStagingPath(volume); ok {
+		return fsutil.Usage(stagingPath)
+	}
+
+	allocated, err := fsutil.Allocated(b.imagePath(volume))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Never staged, so nothing is used yet
+			allocated = 0
+		} else {
+			return nil, err
+		}
+	}
+
+	return &types.VolumeUsage{
+		TotalBytes:     volume.CapacityBytes,
+		UsedBytes:      allocated,
+		AvailableBytes: max(volume.CapacityBytes-allocated, 0),
+	}, nil
+}
+
+// AvailableCapacity is unknown: images are created on whichever node their
+// volume is placed on, not on the host of the manager
+func (b *Backend) AvailableCapacity(ctx context.Context, params map[string]string) (int64, error) {
+	return 0, fmt.Errorf("%w: image volumes are created on the node they are placed on", storage.ErrCapacityUnknown)
+}
+
+// ExpandVolume grows the image file of a volume. Its filesystem is grown by
+// NodeExpandVolume where it is mounted, or when it is staged next.
+func (b *Backend) ExpandVolume(ctx context.Context, volume *types.Volume, capacityBytes int64) error {
+	imagePath := b.imagePath(volume)
+
+	if _, err := imagefs.Resize(imagePath, capacityBytes); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// NodeExpandVolume grows the mounted filesystem of a volume to its capacity
+func (b *Backend) NodeExpandVolume(ctx context.Context, volume *types.Volume, volumePath string) error {
+	imagePath := b.imagePath(volume)
+
+	if _, err := imagefs.Resize(imagePath, volume.CapacityBytes); err != nil {
+		return err
+	}
+
+	grow, err := imagefs.NeedsGrow(imagePath, b.fsType(volume))
+	if err != nil || !grow {
+		return err
+	}
+
+	b.logger.Info("growing volume filesystem online",
+		"volume_id", volume.ID,
+		"volume_path", volumePath,
+		"capacity_bytes", volume.CapacityBytes,
+	)
+
+	return imagefs.GrowMounted(volumePath)
+}
+
+// localStagingPath returns a staging path where the image of volume is
+// mounted on this host, if there is one
+func (b *Backend) localStagingPath(volume *types.Volume) (string, bool) {
+	for _, attachment := range volume.Attachments {
+		if attachment.StagingPath == "" {
+			continue
+		}
+		if mounted, err := b.mounter.IsMountPoint(attachment.StagingPath); err == nil && mounted {
+			return attachment.StagingPath, true
+		}
+	}
+	return "", false
+}
+
+// imagePath returns where the image file of a volume is kept
+func (b *Backend) imagePath(volume *types.Volume) string {
+	imageDir := volume.Parameters["image_dir"]
+	if imageDir == "" {
+		imageDir = b.imageDir
+	}
+	return filepath.Join(imageDir, volume.ID+".img")
+}
+
+// fsType returns the filesystem type of a volume's image
+func (b *Backend) fsType(volume *types.Volume) string {
+	if fsType := volume.Parameters["fs_type"]; fsType != "" {
+		return fsType
+	}
+	return imagefs.FSExt4
+}
+
+// Stage creates and formats the image of the volume on first use and mounts
+// it at the staging path
+func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("staging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	if volume.CapacityBytes <= 0 {
+		return fmt.Errorf("volume %s has no capacity", volume.ID)
+	}
+
+	// Staging again must not touch the filesystem while it is mounted
+	mounted, err := b.mounter.IsMountPoint(stagingPath)
+	if err != nil {
+		return fmt.Errorf("failed to check staging path: %w", err)
+	}
+	if mounted {
+		return nil
+	}
+
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return fmt.Errorf("failed to create staging path: %w", err)
+	}
+
+	imagePath := b.imagePath(volume)
+	fsType := b.fsType(volume)
+
+	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+		if err := b.createImage(imagePath, fsType, volume.CapacityBytes); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if err := b.growUnmountedImage(imagePath, fsType, volume.CapacityBytes); err != nil {
+		return err
+	}
+
+	if err := imagefs.Mount(b.mounter, imagePath, stagingPath, fsType, nil); err != nil {
+		return err
+	}
+
+	// xfs can only grow once it is mounted
+	if grow, err := imagefs.NeedsGrow(imagePath, fsType); err != nil {
+		return err
+	} else if grow {
+		if err := imagefs.GrowMounted(stagingPath); err != nil {
+			return fmt.Errorf("failed to grow filesystem: %w", err)
+		}
+	}
+
+	b.logger.Info("volume staged successfully",
+		"volume_id", volume.ID,
+		"image_path", imagePath,
+	)
+
+	return nil
+}
+
+// createImage allocates a sparse image file and formats it
+func (b *Backend) createImage(imagePath, fsType string, sizeBytes int64) error {
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0700); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	if err := imagefs.Create(imagePath, sizeBytes); err != nil {
+		return fmt.Errorf("failed to create image: %w", err)
+	}
+
+	if err := imagefs.Format(imagePath, fsType, ""); err != nil {
+		_ = os.Remove(imagePath)
+		return fmt.Errorf("failed to format image: %w", err)
+	}
+
+	b.logger.Info("created image",
+		"image_path", imagePath,
+		"fs_type", fsType,
+		"size_bytes", sizeBytes,
+	)
+
+	return nil
+}
+
+// growUnmountedImage resizes an image that is not mounted to sizeBytes and
+// grows its filesystem offline where the filesystem allows it
+func (b *Backend) growUnmountedImage(imagePath, fsType string, sizeBytes int64) error {
+	if _, err := imagefs.Resize(imagePath, sizeBytes); err != nil {
+		return err
+	}
+
+	if fsType != imagefs.FSExt4 {
+		return nil
+	}
+
+	grow, err := imagefs.NeedsGrow(imagePath, fsType)
+	if err != nil || !grow {
+		return err
+	}
+
+	b.logger.Info("growing volume filesystem offline", "image_path", imagePath)
+	return imagefs.GrowOffline(imagePath, fsType)
+}
+
+// Unstage unmounts the image, which releases its loop device
+func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("unstaging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, stagingPath); err != nil {
+		return fmt.Errorf("failed to unmount image: %w", err)
+	}
+
+	return nil
+}
+
+// Publish bind-mounts the staged filesystem at the target path
+func (b *Backend) Publish(ctx context.Context, volume *types.Volume, stagingPath, targetPath string, readOnly bool) error {
+	b.logger.Info("publishing volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+		"target_path", targetPath,
+		"read_only", readOnly,
+	)
+
+	mounted, err := b.mounter.IsMountPoint(stagingPath)
+	if err != nil {
+		return fmt.Errorf("failed to check staging path: %w", err)
+	}
+	if !mounted {
+		return fmt.Errorf("%w: nothing is mounted at %s", storage.ErrVolumeNotStaged, stagingPath)
+	}
+
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create target path: %w", err)
+	}
+
+	if err := mount.BindMount(b.mounter, stagingPath, targetPath, readOnly); err != nil {
+		return fmt.Errorf("failed to bind mount: %w", err)
+	}
+
+	b.logger.Info("volume published successfully",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	return nil
+}
+
+// Unpublish removes the bind mount at the target path
+func (b *Backend) Unpublish(ctx context.Context, volume *types.Volume, targetPath string) error {
+	b.logger.Info("unpublishing volume",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, targetPath); err != nil {
+		return fmt.Errorf("failed to remove bind mount: %w", err)
+	}
+
+	b.logger.Info("volume unpublished successfully",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
@@ -0,0 +1,253 @@
+// Package imagefs manages filesystems that live in sparse image files and
+// are mounted through loop devices.
+package imagefs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+)
+
+// ErrNotSupported is returned when loop devices are not available on this platform
+var ErrNotSupported = errors.New("loop devices not supported on this platform")
+
+// Filesystem types that images can be formatted with
+const (
+	FSExt4 = "ext4"
+	FSXFS  = "xfs"
+)
+
+// ValidFSType reports whether images can be formatted with fsType
+func ValidFSType(fsType string) bool {
+	return fsType == FSExt4 || fsType == FSXFS
+}
+
+// Create allocates a sparse image file of sizeBytes. The file must not exist.
+func Create(path string, sizeBytes int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(sizeBytes); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to allocate %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// Format creates a filesystem of fsType in the image file. If sourceDir is
+// set, the new filesystem is populated with its contents (ext4 only).
+func Format(path, fsType, sourceDir string) error {
+	var cmd *exec.Cmd
+	switch fsType {
+	case FSExt4:
+		args := []string{"-q", "-F", "-m", "0"}
+		if sourceDir != "" {
+			args = append(args, "-d", sourceDir)
+		}
+		cmd = exec.Command("mkfs.ext4", append(args, path)...)
+	case FSXFS:
+		if sourceDir != "" {
+			return fmt.Errorf("populating %s filesystems is not supported", fsType)
+		}
+		cmd = exec.Command("mkfs.xfs", "-q", "-f", path)
+	default:
+		return fmt.Errorf("unsupported filesystem type: %s", fsType)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Mount attaches the image file to a free loop device and mounts it at
+// target. The loop device is released automatically when it is unmounted.
+// It is a no-op if target is already a mount point.
+func Mount(m mount.Mounter, path, target, fsType string, options []string) error {
+	mounted, err := m.IsMountPoint(target)
+	if err != nil {
+		return fmt.Errorf("failed to check mount point %s: %w", target, err)
+	}
+	if mounted {
+		return nil
+	}
+
+	device, err := attach(path)
+	if err != nil {
+		return fmt.Errorf("failed to attach %s to a loop device: %w", path, err)
+	}
+	// Closing the last reference detaches the device unless it is mounted
+	defer device.Close()
+
+	if err := m.Mount(device.Name(), target, fsType, options); err != nil {
+		return fmt.Errorf("failed to mount %s at %s: %w", device.Name(), target, err)
+	}
+
+	return nil
+}
+
+// Resize grows the image file to sizeBytes. Images never shrink, so a smaller
+// size is a no-op. It reports whether the file grew.
+func Resize(path string, sizeBytes int64) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.Size() >= sizeBytes {
+		return false, nil
+	}
+
+	if err := os.Truncate(path, sizeBytes); err != nil {
+		return false, fmt.Errorf("failed to grow %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// growSlack is how much smaller than its image a filesystem may be before it
+// is grown; resize2fs may leave a tail too small for a block group unused
+const growSlack = 1 << 20
+
+// NeedsGrow reports whether the filesystem in the image file is smaller than
+// the file, i.e. the image was resized since the filesystem was last grown
+func NeedsGrow(path, fsType string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	fsSize, err := filesystemSize(path, fsType)
+	if err != nil {
+		return false, err
+	}
+
+	return info.Size()-fsSize >= growSlack, nil
+}
+
+// filesystemSize reads the size of the filesystem from its superblock
+func filesystemSize(path, fsType string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	switch fsType {
+	case FSExt4:
+		// The superblock starts at byte 1024 and is little-endian
+		sb := make([]byte, 1024)
+		if _, err := f.ReadAt(sb, 1024); err != nil && err != io.EOF {
+			return 0, err
+		}
+		if binary.LittleEndian.Uint16(sb[0x38:]) != 0xEF53 {
+			return 0, fmt.Errorf("%s does not hold an ext4 filesystem", path)
+		}
+		blocks := uint64(binary.LittleEndian.Uint32(sb[0x04:]))
+		if features := binary.LittleEndian.Uint32(sb[0x60:]); features&0x80 != 0 { // INCOMPAT_64BIT
+			blocks |= uint64(binary.LittleEndian.Uint32(sb[0x150:])) << 32
+		}
+		blockSize := uint64(1024) << binary.LittleEndian.Uint32(sb[0x18:])
+		return int64(blocks * blockSize), nil
+
+	case FSXFS:
+		// The superblock starts at byte 0 and is big-endian
+		sb := make([]byte, 16)
+		if _, err := f.ReadAt(sb, 0); err != nil {
+			return 0, err
+		}
+		if string(sb[0:4]) != "XFSB" {
+			return 0, fmt.Errorf("%s does not hold an xfs filesystem", path)
+		}
+		blockSize := uint64(binary.BigEndian.Uint32(sb[4:]))
+		blocks := binary.BigEndian.Uint64(sb[8:])
+		return int64(blocks * blockSize), nil
+
+	default:
+		return 0, fmt.Errorf("unsupported filesystem type: %s", fsType)
+	}
+}
+
+// GrowOffline grows the filesystem in an unmounted image file to the size of
+// the file. Only ext4 can grow offline; xfs must be grown with GrowFS after
+// it is mounted.
+func GrowOffline(path, fsType string) error {
+	if fsType != FSExt4 {
+		return fmt.Errorf("%s filesystems can only grow while mounted", fsType)
+	}
+
+	// resize2fs insists on a freshly checked filesystem. Exit codes below 4
+	// mean e2fsck fixed what it found, e.g. replayed the journal of an image
+	// copied while it was mounted.
+	cmd := exec.Command("e2fsck", "-f", "-p", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() >= 4 {
+			return fmt.Errorf("e2fsck failed: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	cmd = exec.Command("resize2fs", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("resize2fs failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// GrowFS grows the mounted filesystem of an image to the current size of
+// the image file. device is the loop device it is mounted from. Growing ext4
+// online needs CAP_SYS_RESOURCE.
+func GrowFS(device, mountPoint, fsType string) error {
+	// The loop device keeps the size it had when it was attached
+	if err := refreshCapacity(device); err != nil {
+		return fmt.Errorf("failed to refresh size of %s: %w", device, err)
+	}
+
+	var cmd *exec.Cmd
+	switch fsType {
+	case FSExt4:
+		cmd = exec.Command("resize2fs", device)
+	case FSXFS:
+		cmd = exec.Command("xfs_growfs", mountPoint)
+	default:
+		return fmt.Errorf("unsupported filesystem type: %s", fsType)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// GrowMounted grows the image filesystem mounted at path, which may also be
+// a bind mount of it, to the current size of its image file
+func GrowMounted(path string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	mounts, err := mount.ReadMountInfo()
+	if err != nil {
+		return err
+	}
+
+	mi, ok := mount.FindMount(mounts, resolved)
+	if !ok || mi.MountPoint != resolved {
+		return fmt.Errorf("%s is not a mount point", path)
+	}
+
+	return GrowFS(mi.Source, resolved, mi.FSType)
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package imagefs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// attachRetries bounds how often attach retries when another process grabs
+// the free loop device first
+const attachRetries = 10
+
+// attach binds the image file at path to a free loop device with autoclear
+// set, so the device detaches when its last user (open file or mount) goes
+// away. The returned file keeps the device attached until it is closed.
+func attach(path string) (*os.File, error) {
+	image, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer image.Close()
+
+	control, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer control.Close()
+
+	for i := 0; i < attachRetries; i++ {
+		n, err := unix.IoctlRetInt(int(control.Fd()), unix.LOOP_CTL_GET_FREE)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a free loop device: %w", err)
+		}
+
+		device, err := os.OpenFile(fmt.Sprintf("/dev/loop%d", n), os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := unix.IoctlSetInt(int(device.Fd()), unix.LOOP_SET_FD, int(image.Fd())); err != nil {
+			device.Close()
+			if errors.Is(err, unix.EBUSY) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to attach %s: %w", device.Name(), err)
+		}
+
+		info := unix.LoopInfo64{Flags: unix.LO_FLAGS_AUTOCLEAR}
+		copy(info.File_name[:len(info.File_name)-1], path)
+		if err := unix.IoctlLoopSetStatus64(int(device.Fd()), &info); err != nil {
+			_ = unix.IoctlSetInt(int(device.Fd()), unix.LOOP_CLR_FD, 0)
+			device.Close()
+			return nil, fmt.Errorf("failed to configure %s: %w", device.Name(), err)
+		}
+
+		return device, nil
+	}
+
+	return nil, errors.New("no free loop device")
+}
+
+// refreshCapacity makes a loop device pick up the new size of its backing file
+func refreshCapacity(device string) error {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return unix.IoctlSetInt(int(f.Fd()), unix.LOOP_SET_CAPACITY, 0)
+}
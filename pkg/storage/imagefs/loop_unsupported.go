@@ -0,0 +1,15 @@
+//go:build !linux
+
+package imagefs
+
+import "os"
+
+// attach always returns ErrNotSupported
+func attach(path string) (*os.File, error) {
+	return nil, ErrNotSupported
+}
+
+// refreshCapacity always returns ErrNotSupported
+func refreshCapacity(device string) error {
+	return ErrNotSupported
+}
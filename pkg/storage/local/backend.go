@@ -7,8 +7,13 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/fsutil"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/imagefs"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/quota"
 	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
 
@@ -21,13 +26,15 @@ func init() {
 
 // Backend implements the local filesystem storage backend
 type Backend struct {
-	logger *slog.Logger
+	mounter mount.Mounter
+	logger  *slog.Logger
 }
 
 // NewBackend creates a new local filesystem backend
 func NewBackend() (storage.Backend, error) {
 	return &Backend{
-		logger: slog.Default().With("backend", "local"),
+		mounter: mount.New(),
+		logger:  slog.Default().With("backend", "local"),
 	}, nil
 }
 
@@ -41,8 +48,17 @@ func (b *Backend) Capabilities() types.BackendCapability {
 	return types.BackendCapability{
 		SupportsReadOnly:  true,
 		SupportsReadWrite: true,
-		SupportsSnapshot:  false,
-		SupportsClone:     false,
+		SupportsSnapshot:  true,
+		SupportsClone:     true,
+		SupportsCapacity:  true,
+		NodeLocal:         true,
+		AccessModes: []types.AccessMode{
+			types.AccessModeSingleNodeWriter,
+			types.AccessModeSingleNodeMultiWriter,
+			types.AccessModeSingleNodeReaderOnly,
+			types.AccessModeMultiNodeReaderOnly,
+		},
+		ManagedParameters: []string{"populate_from", "populate_from_image"},
 	}
 }
 
@@ -58,9 +74,432 @@ func (b *Backend) Validate(params map[string]string) error {
 		return fmt.Errorf("path must be absolute: %s", path)
 	}
 
+	// So must the paths CloneVolume and RestoreSnapshot record
+	for _, name := range []string{"snapshot_path", "populate_from", "populate_from_image"} {
+		if value := params[name]; value != "" && !filepath.IsAbs(value) {
+			return fmt.Errorf("%s must be absolute: %s", name, value)
+		}
+	}
+
+	return nil
+}
+
+// Provision allocates the backing storage for a new volume
+func (b *Backend) Provision(ctx context.Context, volume *types.Volume) error {
+	// The source directory lives on the node that uses the volume, so it is
+	// created when the volume is staged there rather than on the manager host
+	b.logger.Info("provisioned volume",
+		"volume_id", volume.ID,
+		"source_path", volume.Parameters["path"],
+	)
+
+	return nil
+}
+
+// Delete releases the backing storage of a volume
+func (b *Backend) Delete(ctx context.Context, volume *types.Volume) error {
+	// The source directory and a capacity image are owned by the host of the
+	// node and left in place. The image was unmounted when it was unstaged.
+	b.logger.Info("deleted volume",
+		"volume_id", volume.ID,
+		"source_path", volume.Parameters["path"],
+	)
+
+	return nil
+}
+
+// NodeCreateSnapshot copies the source directory of a volume on the node it
+// lives on. Snapshots are stored next to the source in a .snapshots directory
+// unless the volume sets the snapshot_path parameter.
+func (b *Backend) NodeCreateSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error {
+	sourcePath := volume.Parameters["path"]
+
+	snapshotDir := volume.Parameters["snapshot_path"]
+	if snapshotDir == "" {
+		snapshotDir = filepath.Join(filepath.Dir(sourcePath), ".snapshots")
+	}
+	if !filepath.IsAbs(snapshotDir) {
+		return fmt.Errorf("snapshot_path must be absolute: %s", snapshotDir)
+	}
+
+	if err := b.ensureDirectoryExists(snapshotDir); err != nil {
+		return fmt.Errorf("failed to ensure snapshot directory: %w", err)
+	}
+
+	// A clone that was never staged has not been copied from its source yet
+	if volume.Parameters["populate_from"] != "" {
+		if err := b.populate(volume); err != nil {
+			return fmt.Errorf("failed to populate volume: %w", err)
+		}
+	}
+
+	// A partial copy left by an interrupted attempt is replaced
+	snapshotPath := filepath.Join(snapshotDir, snapshot.ID)
+	if err := os.RemoveAll(snapshotPath); err != nil {
+		return fmt.Errorf("failed to remove partial snapshot %s: %w", snapshotPath, err)
+	}
+
+	var size int64
+	err := b.withVolumeData(volume, func(dataPath string) error {
+		var err error
+		size, err = fsutil.CopyTree(dataPath, snapshotPath)
+		return err
+	})
+	if err != nil {
+		_ = os.RemoveAll(snapshotPath)
+		return fmt.Errorf("failed to copy %s: %w", sourcePath, err)
+	}
+
+	if snapshot.Parameters == nil {
+		snapshot.Parameters = make(map[string]string)
+	}
+	snapshot.Parameters["path"] = snapshotPath
+	snapshot.SizeBytes = size
+
+	b.logger.Info("created snapshot",
+		"volume_id", volume.ID,
+		"snapshot_id", snapshot.ID,
+		"snapshot_path", snapshotPath,
+		"size_bytes", size,
+	)
+
+	return nil
+}
+
+// NodeDeleteSnapshot removes the copied directory of a snapshot
+func (b *Backend) NodeDeleteSnapshot(ctx context.Context, snapshot *types.Snapshot) error {
+	snapshotPath := snapshot.Parameters["path"]
+	if snapshotPath == "" {
+		return nil
+	}
+	if !filepath.IsAbs(snapshotPath) {
+		return fmt.Errorf("snapshot %s has no valid path", snapshot.ID)
+	}
+
+	if err := os.RemoveAll(snapshotPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", snapshotPath, err)
+	}
+
+	b.logger.Info("deleted snapshot",
+		"snapshot_id", snapshot.ID,
+		"snapshot_path", snapshotPath,
+	)
+
+	return nil
+}
+
+// withVolumeData calls fn with the directory holding the data of volume
+func (b *Backend) withVolumeData(volume *types.Volume, fn func(dataPath string) error) error {
+	return b.withData(volume.Parameters["path"], b.imagePath(volume), fn)
+}
+
+// withData calls fn with the directory holding the data of a volume with the
+// given source path and capacity image. The image of a volume that is not
+// staged is not mounted, so it is mounted read-only next to the image for the
+// duration of fn.
+func (b *Backend) withData(sourcePath, imagePath string, fn func(dataPath string) error) error {
+	if imagePath == "" {
+		return fn(sourcePath)
+	}
+	if _, err := os.Stat(imagePath); err != nil {
+		return fn(sourcePath)
+	}
+
+	mounted, err := b.mounter.IsMountPoint(sourcePath)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return fn(sourcePath)
+	}
+
+	dataPath, err := os.MkdirTemp(filepath.Dir(imagePath), strings.TrimSuffix(filepath.Base(imagePath), ".img")+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create image mount point: %w", err)
+	}
+	defer os.Remove(dataPath)
+
+	if err := imagefs.Mount(b.mounter, imagePath, dataPath, imagefs.FSExt4, []string{"ro"}); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mount.UnmountIfMounted(b.mounter, dataPath); err != nil {
+			b.logger.Error("failed to unmount capacity image", "image_path", imagePath, "error", err)
+		}
+	}()
+
+	return fn(dataPath)
+}
+
+// CloneVolume makes a new volume a copy of another local volume. The data
+// lives on the node of the source, so the copy is made there when the new
+// volume is first staged; this only records where to copy from.
+func (b *Backend) CloneVolume(ctx context.Context, volume, source *types.Volume) error {
+	if err := b.recordPopulateSource(volume, source.Parameters["path"]); err != nil {
+		return err
+	}
+	volume.Parameters["populate_from_image"] = b.imagePath(source)
 	return nil
 }
 
+// RestoreSnapshot makes a new volume a copy of a snapshot. Like a clone, it is
+// copied when the volume is first staged on the node holding the snapshot.
+func (b *Backend) RestoreSnapshot(ctx context.Context, volume *types.Volume, snapshot *types.Snapshot) error {
+	return b.recordPopulateSource(volume, snapshot.Parameters["path"])
+}
+
+// recordPopulateSource records sourcePath as the directory the source
+// directory of volume is copied from when it is first staged
+func (b *Backend) recordPopulateSource(volume *types.Volume, sourcePath string) error {
+	targetPath := volume.Parameters["path"]
+
+	if sourcePath == "" || !filepath.IsAbs(sourcePath) {
+		return fmt.Errorf("invalid source path: %q", sourcePath)
+	}
+
+	// Copying a tree into itself would never finish
+	if rel, err := filepath.Rel(sourcePath, targetPath); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("path %s must not be inside the source %s", targetPath, sourcePath)
+	}
+
+	volume.Parameters["populate_from"] = sourcePath
+	return nil
+}
+
+// populate copies the directory recorded by CloneVolume or RestoreSnapshot
+// into the source directory of volume. A source directory that exists already
+// has been populated by an earlier stage and is left alone. The copy is made
+// next to it and renamed into place, so an interrupted copy is never used.
+func (b *Backend) populate(volume *types.Volume) error {
+	sourcePath := volume.Parameters["populate_from"]
+	targetPath := volume.Parameters["path"]
+
+	if _, err := os.Lstat(targetPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := b.recordPopulateSource(volume, sourcePath); err != nil {
+		return err
+	}
+
+	parent := filepath.Dir(targetPath)
+	if err := b.ensureDirectoryExists(parent); err != nil {
+		return fmt.Errorf("failed to ensure parent of path: %w", err)
+	}
+
+	tempPath, err := os.MkdirTemp(parent, "."+filepath.Base(targetPath)+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create copy directory: %w", err)
+	}
+
+	var size int64
+	err = b.withData(sourcePath, volume.Parameters["populate_from_image"], func(dataPath string) error {
+		var err error
+		size, err = fsutil.CopyTree(dataPath, tempPath)
+		return err
+	})
+	if err == nil {
+		err = os.Rename(tempPath, targetPath)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tempPath)
+		return fmt.Errorf("failed to copy %s: %w", sourcePath, err)
+	}
+
+	b.logger.Info("populated volume",
+		"volume_id", volume.ID,
+		"source_path", sourcePath,
+		"path", targetPath,
+		"size_bytes", size,
+	)
+
+	return nil
+}
+
+// Usage reports the usage of a volume whose capacity is enforced on this
+// host, where statfs returns the project quota or the size of the mounted
+// image. Otherwise it would report the filesystem of the host, so the usage
+// of the volume is unknown. It runs on the node holding the volume.
+func (b *Backend) Usage(ctx context.Context, volume *types.Volume) (*types.VolumeUsage, error) {
+	sourcePath := volume.Parameters["path"]
+
+	if volume.CapacityBytes > 0 {
+		if _, err := os.Stat(b.imagePath(volume)); err == nil {
+			mounted, err := b.mounter.IsMountPoint(sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			if mounted {
+				return fsutil.Usage(sourcePath)
+			}
+		} else if _, err := os.Stat(sourcePath); err == nil {
+			supported, err := quota.Supported(sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			if supported {
+				return fsutil.Usage(sourcePath)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: volume %s has no capacity limit enforced on this host", storage.ErrUsageUnknown, volume.ID)
+}
+
+// AvailableCapacity is unknown: new volumes are created on whichever node
+// they are placed on, not on the host of the manager
+func (b *Backend) AvailableCapacity(ctx context.Context, params map[string]string) (int64, error) {
+	return 0, fmt.Errorf("%w: local volumes are created on the node they are placed on", storage.ErrCapacityUnknown)
+}
+
+// limitCapacity enforces the volume capacity on its source directory. It uses
+// a project quota when the filesystem has them enabled, and otherwise moves
+// the volume into an ext4 image file of that size mounted at the source path.
+func (b *Backend) limitCapacity(volume *types.Volume, sourcePath string) error {
+	imagePath := b.imagePath(volume)
+
+	// Once a volume lives in an image it keeps using it. The volume may have
+	// been expanded while the image was not mounted, so grow it first.
+	if _, err := os.Stat(imagePath); err == nil {
+		if err := b.growUnmountedImage(imagePath, volume.CapacityBytes); err != nil {
+			return err
+		}
+		return imagefs.Mount(b.mounter, imagePath, sourcePath, imagefs.FSExt4, nil)
+	}
+
+	supported, err := quota.Supported(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to check project quota support: %w", err)
+	}
+	if supported {
+		b.logger.Info("limiting volume with project quota",
+			"volume_id", volume.ID,
+			"capacity_bytes", volume.CapacityBytes,
+		)
+		return quota.SetProjectQuota(sourcePath, quota.ProjectID(volume.ID), volume.CapacityBytes)
+	}
+
+	b.logger.Info("limiting volume with image file",
+		"volume_id", volume.ID,
+		"image_path", imagePath,
+		"capacity_bytes", volume.CapacityBytes,
+	)
+
+	if err := b.ensureDirectoryExists(filepath.Dir(imagePath)); err != nil {
+		return fmt.Errorf("failed to ensure image directory: %w", err)
+	}
+
+	// Existing data, e.g. from a clone, is copied into the new filesystem
+	entries, err := os.ReadDir(sourcePath)
+	if err != nil {
+		return err
+	}
+	populateFrom := ""
+	if len(entries) > 0 {
+		populateFrom = sourcePath
+	}
+
+	if err := imagefs.Create(imagePath, volume.CapacityBytes); err != nil {
+		return err
+	}
+	if err := imagefs.Format(imagePath, imagefs.FSExt4, populateFrom); err != nil {
+		_ = os.Remove(imagePath)
+		return err
+	}
+
+	// The data now lives in the image, so drop the copy it would hide
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(sourcePath, entry.Name())); err != nil {
+			return fmt.Errorf("failed to move %s into image: %w", entry.Name(), err)
+		}
+	}
+
+	return imagefs.Mount(b.mounter, imagePath, sourcePath, imagefs.FSExt4, nil)
+}
+
+// ExpandVolume accepts the new capacity of a volume. The quota or image lives
+// on the node of the volume, so NodeExpandVolume raises it there, or Stage
+// when the volume is staged next.
+func (b *Backend) ExpandVolume(ctx context.Context, volume *types.Volume, capacityBytes int64) error {
+	return nil
+}
+
+// NodeExpandVolume raises the project quota of a volume staged on this node
+// to its capacity, or grows its image file and filesystem online. Bind mounts
+// share the filesystem, so volumePath is not needed.
+func (b *Backend) NodeExpandVolume(ctx context.Context, volume *types.Volume, volumePath string) error {
+	sourcePath := volume.Parameters["path"]
+	imagePath := b.imagePath(volume)
+
+	if volume.CapacityBytes == 0 {
+		return nil
+	}
+	if _, err := os.Stat(sourcePath); err != nil {
+		return fmt.Errorf("source path of volume %s: %w", volume.ID, err)
+	}
+
+	if _, err := os.Stat(imagePath); err != nil {
+		supported, err := quota.Supported(sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to check project quota support: %w", err)
+		}
+		if !supported {
+			return fmt.Errorf("volume %s has neither a project quota nor a capacity image", volume.ID)
+		}
+		return quota.SetProjectQuota(sourcePath, quota.ProjectID(volume.ID), volume.CapacityBytes)
+	}
+
+	if _, err := imagefs.Resize(imagePath, volume.CapacityBytes); err != nil {
+		return err
+	}
+
+	mounted, err := b.mounter.IsMountPoint(sourcePath)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return fmt.Errorf("image of volume %s is not mounted at %s", volume.ID, sourcePath)
+	}
+
+	return b.growImageFS(imagePath, sourcePath)
+}
+
+// growUnmountedImage resizes an image that is not mounted to capacityBytes
+// and grows its filesystem offline
+func (b *Backend) growUnmountedImage(imagePath string, capacityBytes int64) error {
+	if _, err := imagefs.Resize(imagePath, capacityBytes); err != nil {
+		return err
+	}
+
+	grow, err := imagefs.NeedsGrow(imagePath, imagefs.FSExt4)
+	if err != nil || !grow {
+		return err
+	}
+
+	b.logger.Info("growing volume filesystem offline", "image_path", imagePath)
+	return imagefs.GrowOffline(imagePath, imagefs.FSExt4)
+}
+
+// growImageFS grows the image filesystem mounted at sourcePath to the size
+// of its image file, if it is not that large already
+func (b *Backend) growImageFS(imagePath, sourcePath string) error {
+	grow, err := imagefs.NeedsGrow(imagePath, imagefs.FSExt4)
+	if err != nil || !grow {
+		return err
+	}
+
+	b.logger.Info("growing volume filesystem online", "image_path", imagePath)
+	return imagefs.GrowMounted(sourcePath)
+}
+
+// imagePath returns where the capacity image of a volume is kept: next to
+// its source directory, like snapshots
+func (b *Backend) imagePath(volume *types.Volume) string {
+	return filepath.Join(filepath.Dir(volume.Parameters["path"]), ".images", volume.ID+".img")
+}
+
 // Stage prepares the volume on a node
 func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath string) error {
 	b.logger.Info("staging volume",
@@ -70,6 +509,13 @@ func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath s
 
 	sourcePath := volume.Parameters["path"]
 
+	// Clones are copied from their source on this node before first use
+	if volume.Parameters["populate_from"] != "" {
+		if err := b.populate(volume); err != nil {
+			return fmt.Errorf("failed to populate volume: %w", err)
+		}
+	}
+
 	// Ensure source path exists
 	if err := b.ensureDirectoryExists(sourcePath); err != nil {
 		return fmt.Errorf("failed to ensure source path: %w", err)
@@ -80,6 +526,12 @@ func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath s
 		return fmt.Errorf("failed to ensure staging path: %w", err)
 	}
 
+	if volume.CapacityBytes > 0 {
+		if err := b.limitCapacity(volume, sourcePath); err != nil {
+			return fmt.Errorf("failed to limit capacity: %w", err)
+		}
+	}
+
 	// For local backend, staging is just validation
 	// The actual bind mount happens in Publish
 	b.logger.Info("volume staged successfully",
@@ -90,6 +542,37 @@ func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath s
 	return nil
 }
 
+// CheckHealth reports a source path that has gone missing, or a capacity
+// image that is no longer mounted on it
+func (b *Backend) CheckHealth(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	sourcePath := volume.Parameters["path"]
+
+	info, err := os.Stat(sourcePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("source path missing: %s", sourcePath)
+	}
+	if err != nil {
+		return fmt.Errorf("source path inaccessible: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source path is not a directory: %s", sourcePath)
+	}
+
+	if volume.CapacityBytes > 0 {
+		if _, err := os.Stat(b.imagePath(volume)); err == nil {
+			mounted, err := b.mounter.IsMountPoint(sourcePath)
+			if err != nil {
+				return fmt.Errorf("failed to check capacity image mount: %w", err)
+			}
+			if !mounted {
+				return fmt.Errorf("capacity image not mounted at %s", sourcePath)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Unstage cleans up the staged volume
 func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath string) error {
 	b.logger.Info("unstaging volume",
@@ -97,8 +580,13 @@ func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath
 		"staging_path", stagingPath,
 	)
 
-	// For local backend, nothing to clean up during unstage
-	// The directory is managed by the host
+	// The directory is managed by the host and stays, but a capacity image
+	// mounted on it at stage is unmounted again
+	if _, err := os.Stat(b.imagePath(volume)); err == nil {
+		if err := mount.UnmountIfMounted(b.mounter, volume.Parameters["path"]); err != nil {
+			return fmt.Errorf("failed to unmount capacity image: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -119,9 +607,7 @@ func (b *Backend) Publish(ctx context.Context, volume *types.Volume, stagingPath
 		return fmt.Errorf("failed to ensure target path: %w", err)
 	}
 
-	// For local backend, we use bind mount
-	// Note: In a real implementation, this would use mount syscall
-	// For now, we'll create a symlink as a placeholder
+	// For local backend, we bind mount the source directory
 	if err := b.createBindMount(sourcePath, targetPath, readOnly); err != nil {
 		return fmt.Errorf("failed to bind mount: %w", err)
 	}
@@ -141,7 +627,7 @@ func (b *Backend) Unpublish(ctx context.Context, volume *types.Volume, targetPat
 		"target_path", targetPath,
 	)
 
-	// Remove the bind mount or symlink
+	// Remove the bind mount
 	if err := b.removeBindMount(targetPath); err != nil {
 		return fmt.Errorf("failed to remove bind mount: %w", err)
 	}
@@ -161,36 +647,30 @@ func (b *Backend) ensureDirectoryExists(path string) error {
 	return nil
 }
 
-// createBindMount creates a bind mount (placeholder: creates symlink for now)
+// createBindMount bind mounts source at target, read-only if requested.
+// Publishing an already mounted target is a no-op.
 func (b *Backend) createBindMount(source, target string, readOnly bool) error {
-	// TODO: Implement actual bind mount using unix.Mount
-	// For development, we'll create a marker file
-	markerFile := filepath.Join(target, ".volume-mount")
-	content := fmt.Sprintf("source=%s\nreadonly=%t\n", source, readOnly)
-
-	if err := os.WriteFile(markerFile, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to create mount marker: %w", err)
+	if err := mount.BindMount(b.mounter, source, target, readOnly); err != nil {
+		return err
 	}
 
-	b.logger.Debug("bind mount created (placeholder)",
+	b.logger.Debug("bind mount created",
 		"source", source,
 		"target", target,
+		"read_only", readOnly,
 	)
 
 	return nil
 }
 
-// removeBindMount removes a bind mount
+// removeBindMount removes a bind mount.
+// Unpublishing a target that is not mounted is a no-op.
 func (b *Backend) removeBindMount(target string) error {
-	// TODO: Implement actual unmount using unix.Unmount
-	// For development, we'll remove the marker file
-	markerFile := filepath.Join(target, ".volume-mount")
-
-	if err := os.Remove(markerFile); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove mount marker: %w", err)
+	if err := mount.UnmountIfMounted(b.mounter, target); err != nil {
+		return err
 	}
 
-	b.logger.Debug("bind mount removed (placeholder)",
+	b.logger.Debug("bind mount removed",
 		"target", target,
 	)
 
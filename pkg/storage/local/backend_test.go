@@ -0,0 +1,319 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount/mounttest"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/storagetest"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+func newTestBackend(m *mounttest.FakeMounter) *Backend {
+	return &Backend{
+		mounter: m,
+		logger:  storagetest.Logger(),
+	}
+}
+
+// newTestVolume returns a volume whose source directory is under dir
+func newTestVolume(dir string, params map[string]string) *types.Volume {
+	return storagetest.NewVolume("local", map[string]string{"path": filepath.Join(dir, "volumes", "data")}, params)
+}
+
+func TestValidate(t *testing.T) {
+	valid := map[string]string{"path": "/srv/data", "snapshot_path": "/srv/snapshots"}
+	storagetest.RunValidate(t, (&Backend{}).Validate, valid, map[string]map[string]string{
+		"no path":                {"path": ""},
+		"relative path":          {"path": "srv/data"},
+		"relative snapshot path": {"snapshot_path": "snapshots"},
+		"relative clone source":  {"populate_from": "srv/source"},
+		"relative clone image":   {"populate_from_image": "source.img"},
+	})
+}
+
+func TestStage(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	stagingPath := filepath.Join(dir, "staging")
+
+	if err := newTestBackend(m).Stage(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	for _, path := range []string{volume.Parameters["path"], stagingPath} {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			t.Errorf("Stat(%s) = %v, %v, want a directory", path, info, err)
+		}
+	}
+
+	// Without a capacity nothing is mounted until the volume is published
+	if calls := m.Calls(); len(calls) != 0 {
+		t.Errorf("calls = %q, want none", calls)
+	}
+}
+
+func TestStagePopulates(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source")
+	if err := os.MkdirAll(filepath.Join(sourcePath, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourcePath, "sub", "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := newTestBackend(&mounttest.FakeMounter{})
+	volume := newTestVolume(dir, nil)
+	if err := b.CloneVolume(context.Background(), volume, &types.Volume{ID: "vol-0", Parameters: map[string]string{"path": sourcePath}}); err != nil {
+		t.Fatalf("CloneVolume() error = %v", err)
+	}
+
+	if err := b.Stage(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	copied := filepath.Join(volume.Parameters["path"], "sub", "file")
+	if data, err := os.ReadFile(copied); err != nil || string(data) != "data" {
+		t.Fatalf("ReadFile(%s) = %q, %v, want the cloned data", copied, data, err)
+	}
+
+	// Changes made after the first stage are kept when staging again
+	if err := os.WriteFile(copied, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Stage(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Fatalf("Stage() again error = %v", err)
+	}
+	if data, err := os.ReadFile(copied); err != nil || string(data) != "changed" {
+		t.Errorf("ReadFile(%s) = %q, %v, want the data written after the clone", copied, data, err)
+	}
+}
+
+func TestCloneIntoSource(t *testing.T) {
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	source := &types.Volume{ID: "vol-0", Parameters: map[string]string{"path": filepath.Join(dir, "volumes")}}
+
+	if err := newTestBackend(&mounttest.FakeMounter{}).CloneVolume(context.Background(), volume, source); err == nil {
+		t.Error("CloneVolume() into its own source error = nil, want an error")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	tests := map[string]struct {
+		readOnly  bool
+		wantCalls int
+	}{
+		"read-write": {wantCalls: 1},
+		"read-only":  {readOnly: true, wantCalls: 2}, // The bind, then the read-only remount
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &mounttest.FakeMounter{}
+			b := newTestBackend(m)
+			dir := t.TempDir()
+			volume := newTestVolume(dir, nil)
+			stagingPath := filepath.Join(dir, "staging")
+			targetPath := filepath.Join(dir, "target")
+
+			if err := b.Stage(context.Background(), volume, stagingPath); err != nil {
+				t.Fatalf("Stage() error = %v", err)
+			}
+			if err := b.Publish(context.Background(), volume, stagingPath, targetPath, tt.readOnly); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+
+			got, ok := m.MountAt(targetPath)
+			if !ok {
+				t.Fatal("Publish() did not mount the target path")
+			}
+			if got.ReadOnly() != tt.readOnly {
+				t.Errorf("read-only = %v, want %v", got.ReadOnly(), tt.readOnly)
+			}
+			if !slices.Contains(got.Options, "bind") {
+				t.Errorf("options = %v, want a bind mount", got.Options)
+			}
+
+			// Publishing again keeps the existing mount
+			if err := b.Publish(context.Background(), volume, stagingPath, targetPath, tt.readOnly); err != nil {
+				t.Fatalf("Publish() again error = %v", err)
+			}
+			if calls := m.Calls(); len(calls) != tt.wantCalls {
+				t.Errorf("calls = %q, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPublishSource(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	targetPath := filepath.Join(dir, "target")
+
+	if err := newTestBackend(m).Publish(context.Background(), volume, filepath.Join(dir, "staging"), targetPath, false); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	// The source directory is bound, not the staging path
+	if got, _ := m.MountAt(targetPath); got.Source != volume.Parameters["path"] {
+		t.Errorf("source = %s, want %s", got.Source, volume.Parameters["path"])
+	}
+	if info, err := os.Stat(targetPath); err != nil || !info.IsDir() {
+		t.Errorf("Stat(%s) = %v, %v, want the target created", targetPath, info, err)
+	}
+}
+
+func TestPublishReadOnlyRemountFails(t *testing.T) {
+	m := &mounttest.FakeMounter{
+		MountError: func(source, target, fstype string, options []string) error {
+			if slices.Contains(options, "remount") {
+				return errors.New("remount failed")
+			}
+			return nil
+		},
+	}
+	dir := t.TempDir()
+	targetPath := filepath.Join(dir, "target")
+
+	if err := newTestBackend(m).Publish(context.Background(), newTestVolume(dir, nil), filepath.Join(dir, "staging"), targetPath, true); err == nil {
+		t.Fatal("Publish() error = nil, want the remount error")
+	}
+
+	// A writable bind must not be left behind
+	if _, ok := m.MountAt(targetPath); ok {
+		t.Error("target still mounted after a failed read-only publish")
+	}
+}
+
+func TestUnpublish(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	b := newTestBackend(m)
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	targetPath := filepath.Join(dir, "target")
+
+	if err := b.Publish(context.Background(), volume, filepath.Join(dir, "staging"), targetPath, false); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := b.Unpublish(context.Background(), volume, targetPath); err != nil {
+		t.Fatalf("Unpublish() error = %v", err)
+	}
+	if _, ok := m.MountAt(targetPath); ok {
+		t.Error("target still mounted after Unpublish()")
+	}
+
+	// Unpublishing again is a no-op
+	if err := b.Unpublish(context.Background(), volume, targetPath); err != nil {
+		t.Fatalf("Unpublish() again error = %v", err)
+	}
+	if calls := m.Calls(); len(calls) != 2 {
+		t.Errorf("calls = %q, want one mount and one unmount", calls)
+	}
+}
+
+func TestUnpublishFails(t *testing.T) {
+	m := &mounttest.FakeMounter{
+		UnmountError: func(target string) error { return errors.New("device busy") },
+	}
+	b := newTestBackend(m)
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	targetPath := filepath.Join(dir, "target")
+
+	if err := b.Publish(context.Background(), volume, filepath.Join(dir, "staging"), targetPath, false); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := b.Unpublish(context.Background(), volume, targetPath); err == nil {
+		t.Error("Unpublish() error = nil, want the unmount error")
+	}
+}
+
+func TestUnstageUnmountsImage(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	b := newTestBackend(m)
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	volume.CapacityBytes = 1 << 20
+	sourcePath := volume.Parameters["path"]
+
+	// A volume limited by an image has it mounted on its source directory
+	imagePath := b.imagePath(volume)
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(imagePath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sourcePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Mount(imagePath, sourcePath, "ext4", []string{"loop"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.CheckHealth(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Errorf("CheckHealth() error = %v", err)
+	}
+
+	if err := b.Unstage(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Fatalf("Unstage() error = %v", err)
+	}
+	if _, ok := m.MountAt(sourcePath); ok {
+		t.Error("image still mounted after Unstage()")
+	}
+
+	// Once it is unstaged the volume has lost its capacity limit
+	if err := b.CheckHealth(context.Background(), volume, filepath.Join(dir, "staging")); err == nil {
+		t.Error("CheckHealth() error = nil, want the unmounted image reported")
+	}
+}
+
+func TestUnstageLeavesSource(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	b := newTestBackend(m)
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+
+	if err := b.Stage(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+	if err := b.Unstage(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Fatalf("Unstage() error = %v", err)
+	}
+
+	if _, err := os.Stat(volume.Parameters["path"]); err != nil {
+		t.Errorf("Stat(source) error = %v, want the source directory kept", err)
+	}
+	if calls := m.Calls(); len(calls) != 0 {
+		t.Errorf("calls = %q, want none", calls)
+	}
+}
+
+func TestCheckHealthMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := newTestBackend(&mounttest.FakeMounter{}).CheckHealth(context.Background(), newTestVolume(dir, nil), filepath.Join(dir, "staging")); err == nil {
+		t.Error("CheckHealth() error = nil, want the missing source reported")
+	}
+}
+
+func TestUsageWithoutCapacity(t *testing.T) {
+	b := newTestBackend(&mounttest.FakeMounter{})
+	dir := t.TempDir()
+	volume := newTestVolume(dir, nil)
+	if err := b.Stage(context.Background(), volume, filepath.Join(dir, "staging")); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	if _, err := b.Usage(context.Background(), volume); !errors.Is(err, storage.ErrUsageUnknown) {
+		t.Errorf("Usage() error = %v, want ErrUsageUnknown", err)
+	}
+}
@@ -0,0 +1,78 @@
+package mount
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrNotSupported is returned when mounting is not supported on this platform
+var ErrNotSupported = errors.New("mount not supported on this platform")
+
+// Mounter abstracts the mount syscalls so mount logic can run against a fake
+type Mounter interface {
+	// Mount mounts source at target. Options use the mount(8) names
+	// (e.g. "bind", "remount", "ro"); unknown options are passed as data.
+	Mount(source, target, fstype string, options []string) error
+
+	// Unmount unmounts target
+	Unmount(target string) error
+
+	// IsMountPoint reports whether path is a mount point
+	IsMountPoint(path string) (bool, error)
+}
+
+// BindMount bind-mounts source at target, remounting read-only if requested.
+// It is a no-op if target is already a mount point.
+func BindMount(m Mounter, source, target string, readOnly bool) error {
+	mounted, err := m.IsMountPoint(target)
+	if err != nil {
+		return fmt.Errorf("failed to check mount point %s: %w", target, err)
+	}
+	if mounted {
+		return nil
+	}
+
+	if err := m.Mount(source, target, "", []string{"bind"}); err != nil {
+		return fmt.Errorf("failed to bind mount %s to %s: %w", source, target, err)
+	}
+
+	// MS_RDONLY is ignored on the initial bind, so read-only needs a remount
+	if readOnly {
+		if err := m.Mount("", target, "", []string{"bind", "remount", "ro"}); err != nil {
+			_ = m.Unmount(target)
+			return fmt.Errorf("failed to remount %s read-only: %w", target, err)
+		}
+	}
+
+	return nil
+}
+
+// UnmountIfMounted unmounts target if it is a mount point.
+// It is a no-op if target is not mounted or does not exist.
+func UnmountIfMounted(m Mounter, target string) error {
+	mounted, err := m.IsMountPoint(target)
+	if err != nil {
+		return fmt.Errorf("failed to check mount point %s: %w", target, err)
+	}
+	if !mounted {
+		return nil
+	}
+
+	if err := m.Unmount(target); err != nil {
+		return fmt.Errorf("failed to unmount %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// isMountPointIn reports whether path is one of the mount points in mounts
+func isMountPointIn(mounts []MountInfo, path string) bool {
+	path = filepath.Clean(path)
+	for _, mi := range mounts {
+		if mi.MountPoint == path {
+			return true
+		}
+	}
+	return false
+}
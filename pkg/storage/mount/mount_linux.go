@@ -0,0 +1,100 @@
+//go:build linux
+
+package mount
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// mountFlags maps mount(8) option names to mount(2) flags
+var mountFlags = map[string]uintptr{
+	"bind":       syscall.MS_BIND,
+	"rbind":      syscall.MS_BIND | syscall.MS_REC,
+	"remount":    syscall.MS_REMOUNT,
+	"ro":         syscall.MS_RDONLY,
+	"nosuid":     syscall.MS_NOSUID,
+	"nodev":      syscall.MS_NODEV,
+	"noexec":     syscall.MS_NOEXEC,
+	"noatime":    syscall.MS_NOATIME,
+	"relatime":   syscall.MS_RELATIME,
+	"private":    syscall.MS_PRIVATE,
+	"rprivate":   syscall.MS_PRIVATE | syscall.MS_REC,
+	"shared":     syscall.MS_SHARED,
+	"rshared":    syscall.MS_SHARED | syscall.MS_REC,
+	"slave":      syscall.MS_SLAVE,
+	"rslave":     syscall.MS_SLAVE | syscall.MS_REC,
+	"unbindable": syscall.MS_UNBINDABLE,
+	"rw":         0,
+	"defaults":   0,
+}
+
+// systemMounter performs mounts with the mount(2) and umount(2) syscalls
+type systemMounter struct {
+	mountInfoPath string
+}
+
+// New returns a Mounter backed by the host kernel
+func New() Mounter {
+	return &systemMounter{mountInfoPath: "/proc/self/mountinfo"}
+}
+
+// Mount mounts source at target
+func (m *systemMounter) Mount(source, target, fstype string, options []string) error {
+	flags, data := parseOptions(options)
+	if err := syscall.Mount(source, target, fstype, flags, data); err != nil {
+		return &os.PathError{Op: "mount", Path: target, Err: err}
+	}
+	return nil
+}
+
+// Unmount unmounts target
+func (m *systemMounter) Unmount(target string) error {
+	if err := syscall.Unmount(target, 0); err != nil {
+		return &os.PathError{Op: "umount", Path: target, Err: err}
+	}
+	return nil
+}
+
+// IsMountPoint reports whether path is a mount point according to mountinfo
+func (m *systemMounter) IsMountPoint(path string) (bool, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	f, err := os.Open(m.mountInfoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", m.mountInfoPath, err)
+	}
+	defer f.Close()
+
+	mounts, err := ParseMountInfo(f)
+	if err != nil {
+		return false, err
+	}
+
+	return isMountPointIn(mounts, resolved), nil
+}
+
+// parseOptions splits mount options into mount(2) flags and filesystem data
+func parseOptions(options []string) (uintptr, string) {
+	var flags uintptr
+	var data []string
+
+	for _, opt := range options {
+		if flag, ok := mountFlags[opt]; ok {
+			flags |= flag
+			continue
+		}
+		data = append(data, opt)
+	}
+
+	return flags, strings.Join(data, ",")
+}
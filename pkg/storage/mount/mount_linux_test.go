@@ -0,0 +1,71 @@
+//go:build linux
+
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	flags, data := parseOptions([]string{"bind", "remount", "ro", "vers=4.2", "nolock"})
+
+	want := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	if flags != want {
+		t.Errorf("flags = %#x, want %#x", flags, want)
+	}
+	if data != "vers=4.2,nolock" {
+		t.Errorf("data = %q, want %q", data, "vers=4.2,nolock")
+	}
+}
+
+func TestSystemMounterIsMountPoint(t *testing.T) {
+	dir := t.TempDir()
+	mounted := filepath.Join(dir, "mounted")
+	plain := filepath.Join(dir, "plain")
+	link := filepath.Join(dir, "link")
+	for _, path := range []string{mounted, plain} {
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(mounted, link); err != nil {
+		t.Fatal(err)
+	}
+
+	// The temp dir may itself sit behind a symlink
+	resolved, err := filepath.EvalSymlinks(mounted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mountInfo := filepath.Join(dir, "mountinfo")
+	content := "22 1 8:1 / / rw - ext4 /dev/sda1 rw\n" +
+		"40 22 0:40 / " + resolved + " rw - tmpfs tmpfs rw\n"
+	if err := os.WriteFile(mountInfo, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &systemMounter{mountInfoPath: mountInfo}
+
+	tests := map[string]bool{
+		mounted:                        true,
+		link:                           true, // symlinks are resolved first
+		plain:                          false,
+		filepath.Join(dir, "missing"):  false,
+		filepath.Join(mounted, "file"): false,
+	}
+
+	for path, want := range tests {
+		got, err := m.IsMountPoint(path)
+		if err != nil {
+			t.Errorf("IsMountPoint(%q) error = %v", path, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("IsMountPoint(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
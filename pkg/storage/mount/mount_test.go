@@ -0,0 +1,138 @@
+package mount_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount/mounttest"
+)
+
+func TestBindMount(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+
+	if err := mount.BindMount(m, "/data/src", "/target", false); err != nil {
+		t.Fatalf("BindMount() error = %v", err)
+	}
+
+	got, ok := m.MountAt("/target")
+	if !ok {
+		t.Fatal("BindMount() did not mount /target")
+	}
+	if got.Source != "/data/src" || !slices.Equal(got.Options, []string{"bind"}) {
+		t.Errorf("mount = %+v, want bind of /data/src", got)
+	}
+	if got.ReadOnly() {
+		t.Error("mount is read-only, want read-write")
+	}
+}
+
+func TestBindMountReadOnly(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+
+	if err := mount.BindMount(m, "/data/src", "/target", true); err != nil {
+		t.Fatalf("BindMount() error = %v", err)
+	}
+
+	got, ok := m.MountAt("/target")
+	if !ok || !got.ReadOnly() {
+		t.Fatalf("mount = %+v, want read-only bind", got)
+	}
+
+	// MS_RDONLY only takes effect on a remount after the bind
+	want := []string{
+		"mount /data/src /target  [bind]",
+		"mount  /target  [bind remount ro]",
+	}
+	if calls := m.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestBindMountAlreadyMounted(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+
+	for i := 0; i < 2; i++ {
+		if err := mount.BindMount(m, "/data/src", "/target", false); err != nil {
+			t.Fatalf("BindMount() #%d error = %v", i+1, err)
+		}
+	}
+
+	if calls := m.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %q, want a single mount", calls)
+	}
+}
+
+func TestBindMountRemountFailure(t *testing.T) {
+	errRemount := errors.New("remount failed")
+	m := &mounttest.FakeMounter{
+		MountError: func(source, target, fstype string, options []string) error {
+			if slices.Contains(options, "remount") {
+				return errRemount
+			}
+			return nil
+		},
+	}
+
+	err := mount.BindMount(m, "/data/src", "/target", true)
+	if !errors.Is(err, errRemount) {
+		t.Fatalf("BindMount() error = %v, want %v", err, errRemount)
+	}
+
+	// A bind that cannot be made read-only must not stay writable
+	if _, ok := m.MountAt("/target"); ok {
+		t.Error("target is still mounted after the failed remount")
+	}
+}
+
+func TestBindMountFailure(t *testing.T) {
+	errMount := errors.New("permission denied")
+	m := &mounttest.FakeMounter{
+		MountError: func(source, target, fstype string, options []string) error {
+			return errMount
+		},
+	}
+
+	if err := mount.BindMount(m, "/data/src", "/target", false); !errors.Is(err, errMount) {
+		t.Fatalf("BindMount() error = %v, want %v", err, errMount)
+	}
+}
+
+func TestUnmountIfMounted(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	if err := m.Mount("/data/src", "/target", "", []string{"bind"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mount.UnmountIfMounted(m, "/target"); err != nil {
+		t.Fatalf("UnmountIfMounted() error = %v", err)
+	}
+	if _, ok := m.MountAt("/target"); ok {
+		t.Error("target is still mounted")
+	}
+
+	// Unmounting again is a no-op
+	if err := mount.UnmountIfMounted(m, "/target"); err != nil {
+		t.Fatalf("UnmountIfMounted() on unmounted target error = %v", err)
+	}
+	if calls := m.Calls(); len(calls) != 2 {
+		t.Errorf("calls = %q, want one mount and one unmount", calls)
+	}
+}
+
+func TestUnmountIfMountedFailure(t *testing.T) {
+	errBusy := errors.New("device or resource busy")
+	m := &mounttest.FakeMounter{
+		UnmountError: func(target string) error {
+			return errBusy
+		},
+	}
+	if err := m.Mount("/data/src", "/target", "", []string{"bind"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mount.UnmountIfMounted(m, "/target"); !errors.Is(err, errBusy) {
+		t.Fatalf("UnmountIfMounted() error = %v, want %v", err, errBusy)
+	}
+}
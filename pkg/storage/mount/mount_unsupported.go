@@ -0,0 +1,26 @@
+//go:build !linux
+
+package mount
+
+// unsupportedMounter is returned on platforms without mount(2)
+type unsupportedMounter struct{}
+
+// New returns a Mounter that fails every mount operation
+func New() Mounter {
+	return unsupportedMounter{}
+}
+
+// Mount always returns ErrNotSupported
+func (unsupportedMounter) Mount(source, target, fstype string, options []string) error {
+	return ErrNotSupported
+}
+
+// Unmount always returns ErrNotSupported
+func (unsupportedMounter) Unmount(target string) error {
+	return ErrNotSupported
+}
+
+// IsMountPoint always returns ErrNotSupported
+func (unsupportedMounter) IsMountPoint(path string) (bool, error) {
+	return false, ErrNotSupported
+}
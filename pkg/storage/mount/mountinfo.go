@@ -0,0 +1,145 @@
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is a single entry of /proc/self/mountinfo
+type MountInfo struct {
+	ID           int
+	ParentID     int
+	Root         string
+	MountPoint   string
+	Options      string
+	FSType       string
+	Source       string
+	SuperOptions string
+}
+
+// ReadMountInfo returns the mounts visible to this process
+func ReadMountInfo() ([]MountInfo, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseMountInfo(f)
+}
+
+// ParseMountInfo parses the mountinfo format described in proc(5)
+func ParseMountInfo(r io.Reader) ([]MountInfo, error) {
+	var mounts []MountInfo
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(line)
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 6 || sep < 6 || len(fields) < sep+3 {
+			return nil, fmt.Errorf("malformed mountinfo line: %q", line)
+		}
+
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed mount ID in line %q: %w", line, err)
+		}
+		parentID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed parent ID in line %q: %w", line, err)
+		}
+
+		var superOptions string
+		if len(fields) > sep+3 {
+			superOptions = fields[sep+3]
+		}
+
+		mounts = append(mounts, MountInfo{
+			ID:           id,
+			ParentID:     parentID,
+			Root:         unescape(fields[3]),
+			MountPoint:   unescape(fields[4]),
+			Options:      fields[5],
+			FSType:       fields[sep+1],
+			Source:       unescape(fields[sep+2]),
+			SuperOptions: superOptions,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mountinfo: %w", err)
+	}
+
+	return mounts, nil
+}
+
+// FindMount returns the mount that contains path, i.e. the last mounted entry
+// with the longest mount point that is a prefix of path. path should have its
+// symlinks resolved.
+func FindMount(mounts []MountInfo, path string) (MountInfo, bool) {
+	path = filepath.Clean(path)
+
+	var found MountInfo
+	ok := false
+	for _, mi := range mounts {
+		rel, err := filepath.Rel(mi.MountPoint, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		// Later entries shadow earlier ones on the same mount point
+		if !ok || len(mi.MountPoint) >= len(found.MountPoint) {
+			found = mi
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// HasOption reports whether the mount or super options of mi include opt
+func (mi MountInfo) HasOption(opt string) bool {
+	for _, options := range []string{mi.Options, mi.SuperOptions} {
+		for _, o := range strings.Split(options, ",") {
+			if o == opt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unescape decodes the octal escapes (\040 for space etc.) used in mountinfo
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
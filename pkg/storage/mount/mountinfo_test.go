@@ -0,0 +1,159 @@
+package mount
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleMountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+25 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+36 22 8:1 /srv/data /mnt/my\040volume ro,relatime shared:1 master:2 - ext4 /dev/sda1 rw
+41 22 0:45 / /mnt/nfs rw,relatime - nfs4 server:/export rw,vers=4.2,prjquota
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(sampleMountInfo))
+	if err != nil {
+		t.Fatalf("ParseMountInfo() error = %v", err)
+	}
+	if len(mounts) != 4 {
+		t.Fatalf("got %d mounts, want 4", len(mounts))
+	}
+
+	// Escaped space, several optional fields
+	want := MountInfo{
+		ID:           36,
+		ParentID:     22,
+		Root:         "/srv/data",
+		MountPoint:   "/mnt/my volume",
+		Options:      "ro,relatime",
+		FSType:       "ext4",
+		Source:       "/dev/sda1",
+		SuperOptions: "rw",
+	}
+	if mounts[2] != want {
+		t.Errorf("mounts[2] = %+v, want %+v", mounts[2], want)
+	}
+
+	// No optional fields
+	if got := mounts[3]; got.MountPoint != "/mnt/nfs" || got.FSType != "nfs4" || got.Source != "server:/export" {
+		t.Errorf("mounts[3] = %+v", got)
+	}
+}
+
+func TestParseMountInfoMalformed(t *testing.T) {
+	tests := map[string]string{
+		"too few fields": "22 1 8:1 / /\n",
+		"no separator":   "22 1 8:1 / / rw,relatime shared:1 ext4 /dev/sda1 rw\n",
+		"bad mount ID":   "x 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n",
+		"bad parent ID":  "22 y 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n",
+		"short after -":  "22 1 8:1 / / rw,relatime - ext4\n",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseMountInfo(strings.NewReader(input)); err == nil {
+				t.Error("ParseMountInfo() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(sampleMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"/":                    "/",
+		"/proc/self":           "/proc",
+		"/mnt/nfs/a/b":         "/mnt/nfs",
+		"/mnt/nfsx":            "/", // a sibling sharing the prefix is not inside
+		"/mnt/my volume/file":  "/mnt/my volume",
+		"/mnt/nfs/../etc/host": "/",
+	}
+
+	for path, want := range tests {
+		got, ok := FindMount(mounts, path)
+		if !ok || got.MountPoint != want {
+			t.Errorf("FindMount(%q) = %q, %v, want %q", path, got.MountPoint, ok, want)
+		}
+	}
+}
+
+func TestFindMountShadowed(t *testing.T) {
+	input := `22 1 8:1 / / rw - ext4 /dev/sda1 rw
+30 22 0:40 / /mnt rw - tmpfs first rw
+31 30 0:41 / /mnt rw - tmpfs second rw
+`
+	mounts, err := ParseMountInfo(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The later mount on the same mount point hides the earlier one
+	got, ok := FindMount(mounts, "/mnt/file")
+	if !ok || got.Source != "second" {
+		t.Errorf("FindMount() = %+v, want the mount of second", got)
+	}
+}
+
+func TestHasOption(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(sampleMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nfs := mounts[3]
+
+	for _, opt := range []string{"rw", "relatime", "prjquota", "vers=4.2"} {
+		if !nfs.HasOption(opt) {
+			t.Errorf("HasOption(%q) = false, want true", opt)
+		}
+	}
+	for _, opt := range []string{"ro", "prj", "vers"} {
+		if nfs.HasOption(opt) {
+			t.Errorf("HasOption(%q) = true, want false", opt)
+		}
+	}
+}
+
+func TestIsMountPointIn(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(sampleMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"/mnt/nfs":       true,
+		"/mnt/nfs/":      true,
+		"/mnt/my volume": true,
+		"/mnt/nfs/sub":   false,
+		"/mnt":           false,
+	}
+
+	for path, want := range tests {
+		if got := isMountPointIn(mounts, path); got != want {
+			t.Errorf("isMountPointIn(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := map[string]string{
+		`/plain`:          "/plain",
+		`/a\040b`:         "/a b",
+		`/tab\011here`:    "/tab\there",
+		`/back\134slash`:  `/back\slash`,
+		`/trailing\04`:    `/trailing\04`, // too short to be an escape
+		`/not\999octal`:   `/not\999octal`,
+		`/two\040\040sp`:  "/two  sp",
+		`/newline\012end`: "/newline\nend",
+	}
+
+	for input, want := range tests {
+		if got := unescape(input); got != want {
+			t.Errorf("unescape(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
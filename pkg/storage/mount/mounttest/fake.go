@@ -0,0 +1,123 @@
+// Package mounttest provides a fake mount.Mounter for tests that cannot
+// mount, e.g. without CAP_SYS_ADMIN.
+package mounttest
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+)
+
+// Mount is a mount recorded by a FakeMounter
+type Mount struct {
+	Source  string
+	FSType  string
+	Options []string
+}
+
+// ReadOnly reports whether the mount was made or remounted read-only
+func (m Mount) ReadOnly() bool {
+	return slices.Contains(m.Options, "ro")
+}
+
+// FakeMounter records mounts in memory instead of calling the kernel. A
+// remount updates the options of an existing mount; mounting over a mount
+// point replaces it. The zero value is ready to use.
+type FakeMounter struct {
+	// MountError, if set, is called before every mount and fails it with
+	// the returned error
+	MountError func(source, target, fstype string, options []string) error
+
+	// UnmountError, if set, is called before every unmount and fails it
+	// with the returned error
+	UnmountError func(target string) error
+
+	mu     sync.Mutex
+	mounts map[string]Mount
+	calls  []string
+}
+
+var _ mount.Mounter = (*FakeMounter)(nil)
+
+// Mount records source as mounted at target
+func (f *FakeMounter) Mount(source, target, fstype string, options []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target = filepath.Clean(target)
+	f.calls = append(f.calls, fmt.Sprintf("mount %s %s %s %v", source, target, fstype, options))
+
+	if f.MountError != nil {
+		if err := f.MountError(source, target, fstype, options); err != nil {
+			return err
+		}
+	}
+
+	if f.mounts == nil {
+		f.mounts = make(map[string]Mount)
+	}
+
+	if slices.Contains(options, "remount") {
+		m, ok := f.mounts[target]
+		if !ok {
+			return fmt.Errorf("remount %s: not mounted", target)
+		}
+		m.Options = slices.Clone(options)
+		f.mounts[target] = m
+		return nil
+	}
+
+	f.mounts[target] = Mount{Source: source, FSType: fstype, Options: slices.Clone(options)}
+	return nil
+}
+
+// Unmount removes the mount at target, failing like umount(2) if there is none
+func (f *FakeMounter) Unmount(target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target = filepath.Clean(target)
+	f.calls = append(f.calls, "unmount "+target)
+
+	if f.UnmountError != nil {
+		if err := f.UnmountError(target); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := f.mounts[target]; !ok {
+		return fmt.Errorf("umount %s: not mounted", target)
+	}
+	delete(f.mounts, target)
+	return nil
+}
+
+// IsMountPoint reports whether something is mounted at path
+func (f *FakeMounter) IsMountPoint(path string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.mounts[filepath.Clean(path)]
+	return ok, nil
+}
+
+// MountAt returns the mount at target, if there is one
+func (f *FakeMounter) MountAt(target string) (Mount, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.mounts[filepath.Clean(target)]
+	return m, ok
+}
+
+// Calls returns the mounts and unmounts attempted so far, in order, e.g.
+// "mount /src /dst  [bind]" or "unmount /dst"
+func (f *FakeMounter) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.calls)
+}
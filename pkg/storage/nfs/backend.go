@@ -0,0 +1,315 @@
+package nfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// defaultVersion is the NFS version used unless mount_options sets one
+const defaultVersion = "vers=4.1"
+
+func init() {
+	// Register the nfs backend on import
+	if err := storage.RegisterBackend("nfs", NewBackend); err != nil {
+		panic(fmt.Sprintf("failed to register nfs backend: %v", err))
+	}
+}
+
+// Backend implements a storage backend that keeps every volume in its own
+// subdirectory of an NFS export, so the data is reachable from every node
+type Backend struct {
+	mounter mount.Mounter
+	logger  *slog.Logger
+}
+
+// NewBackend creates a new NFS backend
+func NewBackend() (storage.Backend, error) {
+	return &Backend{
+		mounter: mount.New(),
+		logger:  slog.Default().With("backend", "nfs"),
+	}, nil
+}
+
+// Name returns the backend name
+func (b *Backend) Name() string {
+	return "nfs"
+}
+
+// Capabilities returns the backend capabilities
+func (b *Backend) Capabilities() types.BackendCapability {
+	return types.BackendCapability{
+		SupportsReadOnly:  true,
+		SupportsReadWrite: true,
+		AccessModes: []types.AccessMode{
+			types.AccessModeSingleNodeWriter,
+			types.AccessModeSingleNodeMultiWriter,
+			types.AccessModeSingleNodeReaderOnly,
+			types.AccessModeMultiNodeReaderOnly,
+			types.AccessModeMultiNodeMultiWriter,
+		},
+	}
+}
+
+// Validate validates the volume parameters
+func (b *Backend) Validate(params map[string]string) error {
+	if params["server"] == "" {
+		return errors.New("parameter 'server' is required")
+	}
+
+	export := params["export"]
+	if export == "" {
+		return errors.New("parameter 'export' is required")
+	}
+	if !strings.HasPrefix(export, "/") {
+		return fmt.Errorf("export must be absolute: %s", export)
+	}
+
+	subdir := params["subdir"]
+	if subdir == "" {
+		return errors.New("parameter 'subdir' is required")
+	}
+	// The subdirectory is removed with the volume, so it must stay inside
+	// the export and must not be the export itself
+	if filepath.IsAbs(subdir) {
+		return fmt.Errorf("subdir must be relative to the export: %s", subdir)
+	}
+	if clean := filepath.Clean(subdir); clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("subdir must be inside the export: %s", subdir)
+	}
+
+	return nil
+}
+
+// Provision creates the subdirectory of the volume on the export. The export
+// is mounted on the volume manager host for the duration of the call.
+func (b *Backend) Provision(ctx context.Context, volume *types.Volume) error {
+	subdir := filepath.Clean(volume.Parameters["subdir"])
+
+	err := b.withExport(ctx, volume, func(root string) error {
+		return os.MkdirAll(filepath.Join(root, subdir), 0755)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create subdir %s: %w", subdir, err)
+	}
+
+	b.logger.Info("provisioned volume",
+		"volume_id", volume.ID,
+		"source", b.source(volume),
+		"subdir", subdir,
+	)
+
+	return nil
+}
+
+// Delete removes the subdirectory of the volume and everything in it
+func (b *Backend) Delete(ctx context.Context, volume *types.Volume) error {
+	subdir := filepath.Clean(volume.Parameters["subdir"])
+
+	err := b.withExport(ctx, volume, func(root string) error {
+		return os.RemoveAll(filepath.Join(root, subdir))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove subdir %s: %w", subdir, err)
+	}
+
+	b.logger.Info("deleted volume",
+		"volume_id", volume.ID,
+		"source", b.source(volume),
+		"subdir", subdir,
+	)
+
+	return nil
+}
+
+// withExport mounts the export of volume in a temporary directory and calls
+// fn with it
+func (b *Backend) withExport(ctx context.Context, volume *types.Volume, fn func(root string) error) error {
+	root, err := os.MkdirTemp("", "nfs-export-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(root)
+
+	if err := b.mountExport(ctx, volume, root); err != nil {
+		return err
+	}
+
+	fnErr := fn(root)
+
+	if err := b.mounter.Unmount(root); err != nil {
+		b.logger.Error("failed to unmount export", "error", err, "path", root)
+		if fnErr == nil {
+			fnErr = err
+		}
+	}
+
+	return fnErr
+}
+
+// mountExport mounts the export of volume at target. The kernel does not
+// resolve host names, so the server address is looked up here.
+func (b *Backend) mountExport(ctx context.Context, volume *types.Volume, target string) error {
+	server := volume.Parameters["server"]
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, server)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", server, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address found for %s", server)
+	}
+	addr := addrs[0].IP
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			addr = a.IP
+			break
+		}
+	}
+
+	options := []string{"addr=" + addr.String()}
+	hasVersion := false
+	for _, opt := range strings.Split(volume.Parameters["mount_options"], ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		if strings.HasPrefix(opt, "vers=") || strings.HasPrefix(opt, "nfsvers=") {
+			hasVersion = true
+		}
+		options = append(options, opt)
+	}
+	if !hasVersion {
+		options = append(options, defaultVersion)
+	}
+
+	if err := b.mounter.Mount(b.source(volume), target, "nfs", options); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", b.source(volume), err)
+	}
+
+	return nil
+}
+
+// source returns the server:/export spec of a volume
+func (b *Backend) source(volume *types.Volume) string {
+	server := volume.Parameters["server"]
+	if strings.Contains(server, ":") {
+		// IPv6 addresses are bracketed, like in mount.nfs
+		server = "[" + server + "]"
+	}
+	return server + ":" + volume.Parameters["export"]
+}
+
+// Stage mounts the export of the volume at the staging path
+func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("staging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	mounted, err := b.mounter.IsMountPoint(stagingPath)
+	if err != nil {
+		return fmt.Errorf("failed to check staging path: %w", err)
+	}
+
+	if !mounted {
+		if err := os.MkdirAll(stagingPath, 0755); err != nil {
+			return fmt.Errorf("failed to create staging path: %w", err)
+		}
+		if err := b.mountExport(ctx, volume, stagingPath); err != nil {
+			return err
+		}
+	}
+
+	// Publish bind-mounts the subdirectory, so it must exist on the export
+	subdirPath := filepath.Join(stagingPath, filepath.Clean(volume.Parameters["subdir"]))
+	if _, err := os.Stat(subdirPath); err != nil {
+		if !mounted {
+			_ = b.mounter.Unmount(stagingPath)
+		}
+		return fmt.Errorf("subdir of volume %s is missing on the export: %w", volume.ID, err)
+	}
+
+	b.logger.Info("volume staged successfully",
+		"volume_id", volume.ID,
+		"source", b.source(volume),
+	)
+
+	return nil
+}
+
+// Unstage unmounts the export from the staging path
+func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("unstaging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, stagingPath); err != nil {
+		return fmt.Errorf("failed to unmount export: %w", err)
+	}
+
+	return nil
+}
+
+// Publish bind-mounts the subdirectory of the volume from the staged export
+// at the target path
+func (b *Backend) Publish(ctx context.Context, volume *types.Volume, stagingPath, targetPath string, readOnly bool) error {
+	b.logger.Info("publishing volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+		"target_path", targetPath,
+		"read_only", readOnly,
+	)
+
+	mounted, err := b.mounter.IsMountPoint(stagingPath)
+	if err != nil {
+		return fmt.Errorf("failed to check staging path: %w", err)
+	}
+	if !mounted {
+		return fmt.Errorf("%w: export is not mounted at %s", storage.ErrVolumeNotStaged, stagingPath)
+	}
+
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create target path: %w", err)
+	}
+
+	subdirPath := filepath.Join(stagingPath, filepath.Clean(volume.Parameters["subdir"]))
+	if err := mount.BindMount(b.mounter, subdirPath, targetPath, readOnly); err != nil {
+		return fmt.Errorf("failed to bind mount: %w", err)
+	}
+
+	b.logger.Info("volume published successfully",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	return nil
+}
+
+// Unpublish removes the bind mount at the target path
+func (b *Backend) Unpublish(ctx context.Context, volume *types.Volume, targetPath string) error {
+	b.logger.Info("unpublishing volume",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, targetPath); err != nil {
+		return fmt.Errorf("failed to remove bind mount: %w", err)
+	}
+
+	b.logger.Info("volume unpublished successfully",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
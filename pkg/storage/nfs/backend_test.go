@@ -0,0 +1,224 @@
+package nfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount/mounttest"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/storagetest"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+func newTestBackend(m *mounttest.FakeMounter) *Backend {
+	return &Backend{
+		mounter: m,
+		logger:  storagetest.Logger(),
+	}
+}
+
+func newTestVolume(params map[string]string) *types.Volume {
+	return storagetest.NewVolume("nfs", map[string]string{
+		"server": "127.0.0.1",
+		"export": "/srv/nfs",
+		"subdir": "volumes/data",
+	}, params)
+}
+
+func TestValidate(t *testing.T) {
+	valid := map[string]string{"server": "nfs.example.com", "export": "/srv/nfs", "subdir": "volumes/data"}
+	storagetest.RunValidate(t, (&Backend{}).Validate, valid, map[string]map[string]string{
+		"no server":          {"server": ""},
+		"no export":          {"export": ""},
+		"relative export":    {"export": "srv/nfs"},
+		"no subdir":          {"subdir": ""},
+		"absolute subdir":    {"subdir": "/volumes/data"},
+		"export itself":      {"subdir": "."},
+		"export after clean": {"subdir": "volumes/.."},
+		"parent":             {"subdir": ".."},
+		"outside the export": {"subdir": "volumes/../../etc"},
+	})
+}
+
+func TestStage(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	b := newTestBackend(m)
+	stagingPath := filepath.Join(t.TempDir(), "staging")
+
+	// The fake mount leaves the staging path empty, so lay out the export
+	if err := os.MkdirAll(filepath.Join(stagingPath, "volumes", "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Stage(context.Background(), newTestVolume(nil), stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	got, ok := m.MountAt(stagingPath)
+	if !ok {
+		t.Fatal("Stage() did not mount the staging path")
+	}
+	want := mounttest.Mount{Source: "127.0.0.1:/srv/nfs", FSType: "nfs", Options: []string{"addr=127.0.0.1", defaultVersion}}
+	if got.Source != want.Source || got.FSType != want.FSType || !slices.Equal(got.Options, want.Options) {
+		t.Errorf("mount = %+v, want %+v", got, want)
+	}
+
+	// Staging again keeps the existing mount
+	if err := b.Stage(context.Background(), newTestVolume(nil), stagingPath); err != nil {
+		t.Fatalf("Stage() again error = %v", err)
+	}
+	if calls := m.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %q, want a single mount", calls)
+	}
+}
+
+func TestStageMountOptions(t *testing.T) {
+	tests := map[string]struct {
+		params map[string]string
+		source string
+		want   []string
+	}{
+		"version given": {
+			params: map[string]string{"mount_options": "nfsvers=3, nolock,"},
+			source: "127.0.0.1:/srv/nfs",
+			want:   []string{"addr=127.0.0.1", "nfsvers=3", "nolock"},
+		},
+		"other options": {
+			params: map[string]string{"mount_options": "hard,timeo=600"},
+			source: "127.0.0.1:/srv/nfs",
+			want:   []string{"addr=127.0.0.1", "hard", "timeo=600", defaultVersion},
+		},
+		"ipv6 server": {
+			params: map[string]string{"server": "::1"},
+			source: "[::1]:/srv/nfs",
+			want:   []string{"addr=::1", defaultVersion},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &mounttest.FakeMounter{}
+			stagingPath := filepath.Join(t.TempDir(), "staging")
+			if err := os.MkdirAll(filepath.Join(stagingPath, "volumes", "data"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := newTestBackend(m).Stage(context.Background(), newTestVolume(tt.params), stagingPath); err != nil {
+				t.Fatalf("Stage() error = %v", err)
+			}
+
+			got, _ := m.MountAt(stagingPath)
+			if got.Source != tt.source || !slices.Equal(got.Options, tt.want) {
+				t.Errorf("mount = %+v, want %s with %q", got, tt.source, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageMissingSubdir(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	stagingPath := filepath.Join(t.TempDir(), "staging")
+
+	err := newTestBackend(m).Stage(context.Background(), newTestVolume(nil), stagingPath)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Stage() error = %v, want a missing subdir", err)
+	}
+
+	// The export mounted for the check is unmounted again
+	if mounted, _ := m.IsMountPoint(stagingPath); mounted {
+		t.Error("staging path is still mounted")
+	}
+}
+
+func TestStageMountFailure(t *testing.T) {
+	errMount := errors.New("mount failed")
+	m := &mounttest.FakeMounter{
+		MountError: func(source, target, fstype string, options []string) error {
+			return errMount
+		},
+	}
+
+	err := newTestBackend(m).Stage(context.Background(), newTestVolume(nil), filepath.Join(t.TempDir(), "staging"))
+	if !errors.Is(err, errMount) {
+		t.Fatalf("Stage() error = %v, want %v", err, errMount)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	tests := map[string]bool{
+		"read-write": false,
+		"read-only":  true,
+	}
+
+	for name, readOnly := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &mounttest.FakeMounter{}
+			b := newTestBackend(m)
+			dir := t.TempDir()
+			stagingPath := filepath.Join(dir, "staging")
+			targetPath := filepath.Join(dir, "target")
+			if err := os.MkdirAll(filepath.Join(stagingPath, "volumes", "data"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			volume := newTestVolume(nil)
+
+			if err := b.Stage(context.Background(), volume, stagingPath); err != nil {
+				t.Fatalf("Stage() error = %v", err)
+			}
+			if err := b.Publish(context.Background(), volume, stagingPath, targetPath, readOnly); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+
+			got, ok := m.MountAt(targetPath)
+			if !ok {
+				t.Fatal("Publish() did not mount the target path")
+			}
+			if got.Source != filepath.Join(stagingPath, "volumes", "data") || !slices.Contains(got.Options, "bind") {
+				t.Errorf("mount = %+v, want bind of the subdir", got)
+			}
+			if got.ReadOnly() != readOnly {
+				t.Errorf("read-only = %v, want %v", got.ReadOnly(), readOnly)
+			}
+
+			if err := b.Unpublish(context.Background(), volume, targetPath); err != nil {
+				t.Fatalf("Unpublish() error = %v", err)
+			}
+			if err := b.Unstage(context.Background(), volume, stagingPath); err != nil {
+				t.Fatalf("Unstage() error = %v", err)
+			}
+			for _, path := range []string{targetPath, stagingPath} {
+				if mounted, _ := m.IsMountPoint(path); mounted {
+					t.Errorf("%s is still mounted", path)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishNotStaged(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+	dir := t.TempDir()
+
+	err := newTestBackend(m).Publish(context.Background(), newTestVolume(nil), filepath.Join(dir, "staging"), filepath.Join(dir, "target"), false)
+	if !errors.Is(err, storage.ErrVolumeNotStaged) {
+		t.Fatalf("Publish() error = %v, want %v", err, storage.ErrVolumeNotStaged)
+	}
+	if calls := m.Calls(); len(calls) != 0 {
+		t.Errorf("calls = %q, want none", calls)
+	}
+}
+
+func TestUnstageNotMounted(t *testing.T) {
+	m := &mounttest.FakeMounter{}
+
+	if err := newTestBackend(m).Unstage(context.Background(), newTestVolume(nil), filepath.Join(t.TempDir(), "staging")); err != nil {
+		t.Fatalf("Unstage() error = %v", err)
+	}
+	if calls := m.Calls(); len(calls) != 0 {
+		t.Errorf("calls = %q, want none", calls)
+	}
+}
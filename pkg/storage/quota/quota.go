@@ -0,0 +1,65 @@
+// Package quota limits the size of a directory tree with filesystem project
+// quotas (ext4 and XFS mounted with prjquota).
+package quota
+
+import (
+	"errors"
+	"hash/fnv"
+	"path/filepath"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+)
+
+// ErrNotSupported is returned when the filesystem has no project quotas enabled
+var ErrNotSupported = errors.New("project quotas not supported")
+
+// ProjectID derives a stable, non-zero project ID from key (e.g. a volume ID)
+func ProjectID(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	// Project 0 is the default project of every file, so never hand it out
+	if id := h.Sum32(); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// Supported reports whether the filesystem holding path enforces project
+// quotas. path must exist.
+func Supported(path string) (bool, error) {
+	mi, err := findMount(path)
+	if err != nil {
+		return false, err
+	}
+	return projectQuotaEnabled(mi), nil
+}
+
+// findMount returns the mount that holds path
+func findMount(path string) (mount.MountInfo, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return mount.MountInfo{}, err
+	}
+
+	mounts, err := mount.ReadMountInfo()
+	if err != nil {
+		return mount.MountInfo{}, err
+	}
+
+	mi, ok := mount.FindMount(mounts, resolved)
+	if !ok {
+		return mount.MountInfo{}, errors.New("no mount found for " + path)
+	}
+	return mi, nil
+}
+
+// projectQuotaEnabled reports whether a mount has project quota accounting
+// and enforcement turned on
+func projectQuotaEnabled(mi mount.MountInfo) bool {
+	switch mi.FSType {
+	case "ext4", "xfs":
+		return mi.HasOption("prjquota") || mi.HasOption("pquota")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package quota
+
+// SetProjectQuota always returns ErrNotSupported
+func SetProjectQuota(path string, projectID uint32, limitBytes int64) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,582 @@
+package s3
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+const (
+	// defaultRegion is used for signing unless the region parameter is set
+	defaultRegion = "us-east-1"
+
+	// defaultSecretsDir is where Docker mounts secrets
+	defaultSecretsDir = "/run/secrets"
+
+	// dataDir is the directory in the staging path that holds the objects
+	dataDir = "data"
+
+	// stateFile records what was downloaded, to find changes on unstage
+	stateFile = ".s3-state.json"
+)
+
+// Secret keys holding the credentials, in CSI secrets and secret files
+const (
+	secretAccessKeyID     = "access_key_id"
+	secretSecretAccessKey = "secret_access_key"
+	secretSessionToken    = "session_token"
+)
+
+func init() {
+	// Register the s3 backend on import
+	if err := storage.RegisterBackend("s3", NewBackend); err != nil {
+		panic(fmt.Sprintf("failed to register s3 backend: %v", err))
+	}
+}
+
+// Backend implements a storage backend that copies the objects under a
+// bucket prefix onto the node when a volume is staged
+type Backend struct {
+	mounter    mount.Mounter
+	secretsDir string
+	logger     *slog.Logger
+}
+
+// NewBackend creates a new S3 backend. Secrets named by the secret parameter
+// are read from SECRETS_DIR, /run/secrets by default.
+func NewBackend() (storage.Backend, error) {
+	secretsDir := os.Getenv("SECRETS_DIR")
+	if secretsDir == "" {
+		secretsDir = defaultSecretsDir
+	}
+
+	return &Backend{
+		mounter:    mount.New(),
+		secretsDir: secretsDir,
+		logger:     slog.Default().With("backend", "s3"),
+	}, nil
+}
+
+// Name returns the backend name
+func (b *Backend) Name() string {
+	return "s3"
+}
+
+// Capabilities returns the backend capabilities
+func (b *Backend) Capabilities() types.BackendCapability {
+	return types.BackendCapability{
+		SupportsReadOnly:  true,
+		SupportsReadWrite: true,
+		AccessModes: []types.AccessMode{
+			types.AccessModeSingleNodeWriter,
+			types.AccessModeSingleNodeMultiWriter,
+			types.AccessModeSingleNodeReaderOnly,
+			types.AccessModeMultiNodeReaderOnly,
+		},
+	}
+}
+
+// Validate validates the volume parameters
+func (b *Backend) Validate(params map[string]string) error {
+	if params["endpoint"] == "" {
+		return errors.New("parameter 'endpoint' is required")
+	}
+	if _, err := NewClient(params["endpoint"], "", "", true, Credentials{}); err != nil {
+		return err
+	}
+
+	if params["bucket"] == "" {
+		return errors.New("parameter 'bucket' is required")
+	}
+
+	// Parameters are stored and listed in plain text
+	for _, key := range []string{secretAccessKeyID, secretSecretAccessKey, secretSessionToken} {
+		if _, ok := params[key]; ok {
+			return fmt.Errorf("parameter '%s' is not allowed; reference a secret instead", key)
+		}
+	}
+
+	if secret := params["secret"]; secret != "" && (strings.ContainsAny(secret, `/\`) || secret == "." || secret == "..") {
+		return fmt.Errorf("secret must be a name, not a path: %s", secret)
+	}
+
+	for _, key := range []string{"path_style", "sync_back"} {
+		if value := params[key]; value != "" && value != "true" && value != "false" {
+			return fmt.Errorf("%s must be true or false: %s", key, value)
+		}
+	}
+
+	return nil
+}
+
+// Provision allocates the backing storage for a new volume
+func (b *Backend) Provision(ctx context.Context, volume *types.Volume) error {
+	// The bucket is managed outside the volume manager
+	b.logger.Info("provisioned volume",
+		"volume_id", volume.ID,
+		"bucket", volume.Parameters["bucket"],
+		"prefix", prefix(volume),
+	)
+
+	return nil
+}
+
+// Delete releases the backing storage of a volume. The objects in the
+// bucket are left in place.
+func (b *Backend) Delete(ctx context.Context, volume *types.Volume) error {
+	b.logger.Info("deleted volume",
+		"volume_id", volume.ID,
+		"bucket", volume.Parameters["bucket"],
+		"prefix", prefix(volume),
+	)
+
+	return nil
+}
+
+// prefix returns the bucket prefix of a volume. A prefix names a directory,
+// so it always ends with a slash unless it is empty.
+func prefix(volume *types.Volume) string {
+	p := strings.TrimPrefix(volume.Parameters["prefix"], "/")
+	if p != "" && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// syncBack reports whether changes are uploaded when the volume is unstaged
+func syncBack(volume *types.Volume) bool {
+	return volume.Parameters["sync_back"] == "true"
+}
+
+// state records the files downloaded by Stage
+type state struct {
+	Files map[string]fileState `json:"files"`
+
+	// Credentials are kept for the upload on unstage when they came with the
+	// stage request, which is the only request that carries them
+	Credentials *Credentials `json:"credentials,omitempty"`
+}
+
+// fileState is the size and mtime of a downloaded file
+type fileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// credentials returns the credentials of a volume: the CSI secrets of the
+// request, else the secret file named by the secret parameter. Without
+// either, requests are anonymous.
+func (b *Backend) credentials(ctx context.Context, volume *types.Volume) (Credentials, bool, error) {
+	if secrets := storage.SecretsFromContext(ctx); secrets[secretAccessKeyID] != "" {
+		return Credentials{
+			AccessKeyID:     secrets[secretAccessKeyID],
+			SecretAccessKey: secrets[secretSecretAccessKey],
+			SessionToken:    secrets[secretSessionToken],
+		}, true, nil
+	}
+
+	name := volume.Parameters["secret"]
+	if name == "" {
+		return Credentials{}, false, nil
+	}
+
+	values, err := readSecretFile(filepath.Join(b.secretsDir, name))
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("failed to read secret %s: %w", name, err)
+	}
+	if values[secretAccessKeyID] == "" || values[secretSecretAccessKey] == "" {
+		return Credentials{}, false, fmt.Errorf("secret %s must set %s and %s", name, secretAccessKeyID, secretSecretAccessKey)
+	}
+
+	return Credentials{
+		AccessKeyID:     values[secretAccessKeyID],
+		SecretAccessKey: values[secretSecretAccessKey],
+		SessionToken:    values[secretSessionToken],
+	}, false, nil
+}
+
+// readSecretFile parses a secret file of key=value lines. Blank lines and
+// lines starting with # are ignored.
+func readSecretFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.New("lines must have the form key=value")
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return values, scanner.Err()
+}
+
+// client creates an S3 client for the bucket of a volume
+func (b *Backend) client(volume *types.Volume, credentials Credentials) (*Client, error) {
+	region := volume.Parameters["region"]
+	if region == "" {
+		region = defaultRegion
+	}
+
+	return NewClient(
+		volume.Parameters["endpoint"],
+		volume.Parameters["bucket"],
+		region,
+		volume.Parameters["path_style"] != "false",
+		credentials,
+	)
+}
+
+// Stage downloads the objects under the prefix into the staging path
+func (b *Backend) Stage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("staging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	// A complete download leaves the state file behind
+	if _, err := os.Stat(filepath.Join(stagingPath, stateFile)); err == nil {
+		return nil
+	}
+
+	credentials, fromRequest, err := b.credentials(ctx, volume)
+	if err != nil {
+		return err
+	}
+	client, err := b.client(volume, credentials)
+	if err != nil {
+		return err
+	}
+
+	objects, err := client.ListObjects(ctx, prefix(volume))
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	root := filepath.Join(stagingPath, dataDir)
+	// Start over if an earlier attempt was interrupted
+	if err := os.RemoveAll(root); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	st := state{Files: make(map[string]fileState)}
+	if fromRequest && syncBack(volume) {
+		st.Credentials = &credentials
+	}
+
+	var size int64
+	for _, object := range objects {
+		rel, ok := relativeKey(prefix(volume), object.Key)
+		if !ok {
+			b.logger.Warn("skipping object outside the volume", "key", object.Key)
+			continue
+		}
+
+		// Keys ending with a slash are directory markers
+		if strings.HasSuffix(object.Key, "/") {
+			if rel != "" {
+				if err := os.MkdirAll(filepath.Join(root, rel), 0755); err != nil {
+					_ = os.RemoveAll(root)
+					return err
+				}
+			}
+			continue
+		}
+
+		if rel == "" {
+			continue
+		}
+
+		info, err := b.download(ctx, client, object, filepath.Join(root, rel))
+		if err != nil {
+			_ = os.RemoveAll(root)
+			return fmt.Errorf("failed to download %s: %w", object.Key, err)
+		}
+		st.Files[filepath.ToSlash(rel)] = fileState{Size: info.Size(), ModTime: info.ModTime()}
+		size += info.Size()
+	}
+
+	if err := writeState(filepath.Join(stagingPath, stateFile), &st); err != nil {
+		_ = os.RemoveAll(root)
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	b.logger.Info("volume staged successfully",
+		"volume_id", volume.ID,
+		"objects", len(st.Files),
+		"size_bytes", size,
+	)
+
+	return nil
+}
+
+// relativeKey returns the path of key below prefix. Keys that would resolve
+// outside the volume, e.g. with .. segments, are rejected.
+func relativeKey(prefix, key string) (string, bool) {
+	rel := strings.TrimPrefix(key, prefix)
+	for _, segment := range strings.Split(strings.Trim(rel, "/"), "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+
+	rel = filepath.Clean(filepath.FromSlash("/" + rel))[1:]
+	return rel, true
+}
+
+// download writes the content of object to path, with the object's last
+// modification time
+func (b *Backend) download(ctx context.Context, client *Client, object Object, path string) (os.FileInfo, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	body, err := client.GetObject(ctx, object.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Write to a temporary file so a partial download is never mistaken
+	// for the object
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	if !object.LastModified.IsZero() {
+		if err := os.Chtimes(path, time.Time{}, object.LastModified); err != nil {
+			return nil, err
+		}
+	}
+
+	return os.Stat(path)
+}
+
+// Unstage uploads changed files if sync_back is set and removes the
+// downloaded data from the node
+func (b *Backend) Unstage(ctx context.Context, volume *types.Volume, stagingPath string) error {
+	b.logger.Info("unstaging volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+	)
+
+	statePath := filepath.Join(stagingPath, stateFile)
+	root := filepath.Join(stagingPath, dataDir)
+
+	st, err := readState(statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+
+	if st != nil && syncBack(volume) {
+		// Keep the data if the upload fails, so unstaging can be retried
+		if err := b.upload(ctx, volume, root, st); err != nil {
+			return fmt.Errorf("failed to upload changes: %w", err)
+		}
+	}
+
+	if err := os.RemoveAll(root); err != nil {
+		return fmt.Errorf("failed to remove data: %w", err)
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state: %w", err)
+	}
+
+	return nil
+}
+
+// upload puts every file under root that is new or changed since it was
+// downloaded and deletes the objects of files that were removed
+func (b *Backend) upload(ctx context.Context, volume *types.Volume, root string, st *state) error {
+	var credentials Credentials
+	if st.Credentials != nil {
+		credentials = *st.Credentials
+	} else {
+		var err error
+		if credentials, _, err = b.credentials(ctx, volume); err != nil {
+			return err
+		}
+	}
+
+	client, err := b.client(volume, credentials)
+	if err != nil {
+		return err
+	}
+
+	keyPrefix := prefix(volume)
+	seen := make(map[string]bool)
+	var uploaded, deleted int
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if old, ok := st.Files[rel]; ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := client.PutObject(ctx, keyPrefix+rel, f, info.Size()); err != nil {
+			return err
+		}
+		uploaded++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for rel := range st.Files {
+		if seen[rel] {
+			continue
+		}
+		if err := client.DeleteObject(ctx, keyPrefix+rel); err != nil {
+			return err
+		}
+		deleted++
+	}
+
+	b.logger.Info("uploaded changes",
+		"volume_id", volume.ID,
+		"uploaded", uploaded,
+		"deleted", deleted,
+	)
+
+	return nil
+}
+
+// readState loads a state file
+func readState(path string) (*state, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// writeState stores a state file. It may hold credentials, so only root
+// can read it.
+func writeState(path string, st *state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Publish bind-mounts the downloaded data at the target path
+func (b *Backend) Publish(ctx context.Context, volume *types.Volume, stagingPath, targetPath string, readOnly bool) error {
+	b.logger.Info("publishing volume",
+		"volume_id", volume.ID,
+		"staging_path", stagingPath,
+		"target_path", targetPath,
+		"read_only", readOnly,
+	)
+
+	if _, err := os.Stat(filepath.Join(stagingPath, stateFile)); err != nil {
+		return fmt.Errorf("%w: no objects were downloaded to %s", storage.ErrVolumeNotStaged, stagingPath)
+	}
+
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create target path: %w", err)
+	}
+
+	if err := mount.BindMount(b.mounter, filepath.Join(stagingPath, dataDir), targetPath, readOnly); err != nil {
+		return fmt.Errorf("failed to bind mount: %w", err)
+	}
+
+	b.logger.Info("volume published successfully",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	return nil
+}
+
+// Unpublish removes the bind mount at the target path
+func (b *Backend) Unpublish(ctx context.Context, volume *types.Volume, targetPath string) error {
+	b.logger.Info("unpublishing volume",
+		"volume_id", volume.ID,
+		"target_path", targetPath,
+	)
+
+	if err := mount.UnmountIfMounted(b.mounter, targetPath); err != nil {
+		return fmt.Errorf("failed to remove bind mount: %w", err)
+	}
+
+	b.logger.Info("volume unpublished successfully",
+		"volume_id", volume.ID,
+	)
+
+	return nil
+}
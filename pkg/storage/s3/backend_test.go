@@ -0,0 +1,235 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/storage"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/mount/mounttest"
+	"github.com/sistemica/docker-volume-manager/pkg/storage/storagetest"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+func newTestBackend(t *testing.T) *Backend {
+	return &Backend{
+		mounter:    &mounttest.FakeMounter{},
+		secretsDir: t.TempDir(),
+		logger:     storagetest.Logger(),
+	}
+}
+
+func newTestVolume(endpoint string, params map[string]string) *types.Volume {
+	return storagetest.NewVolume("s3", map[string]string{
+		"endpoint": endpoint,
+		"bucket":   "bucket",
+		"prefix":   "vol",
+	}, params)
+}
+
+// writeSecret stores a secret file in the secrets directory of b
+func writeSecret(t *testing.T, b *Backend, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(b.secretsDir, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := newTestBackend(t)
+	valid := map[string]string{"endpoint": "https://s3.example.com", "bucket": "bucket", "secret": "s3-credentials"}
+	storagetest.RunValidate(t, b.Validate, valid, map[string]map[string]string{
+		"no endpoint":           {"endpoint": ""},
+		"endpoint not http":     {"endpoint": "ftp://s3.example.com"},
+		"no bucket":             {"bucket": ""},
+		"inline access key":     {"access_key_id": "AKID"},
+		"inline secret key":     {"secret_access_key": "secret"},
+		"inline session token":  {"session_token": "token"},
+		"secret in a directory": {"secret": "dir/s3-credentials"},
+		"secret outside":        {"secret": "../etc/shadow"},
+		"secret backslash":      {"secret": `..\shadow`},
+		"secret dot":            {"secret": "."},
+		"secret dot dot":        {"secret": ".."},
+		"path_style not a bool": {"path_style": "yes"},
+		"sync_back not a bool":  {"sync_back": "1"},
+	})
+}
+
+func TestCredentials(t *testing.T) {
+	b := newTestBackend(t)
+	writeSecret(t, b, "s3-credentials", "# written by the operator\n\naccess_key_id = AKID\nsecret_access_key=secret\nsession_token=token\n")
+	writeSecret(t, b, "incomplete", "access_key_id=AKID\n")
+	writeSecret(t, b, "malformed", "access_key_id AKID\n")
+
+	t.Run("anonymous", func(t *testing.T) {
+		got, fromRequest, err := b.credentials(context.Background(), newTestVolume("", nil))
+		if err != nil || fromRequest || got != (Credentials{}) {
+			t.Errorf("credentials() = %+v, %v, %v, want none", got, fromRequest, err)
+		}
+	})
+
+	t.Run("secret file", func(t *testing.T) {
+		got, fromRequest, err := b.credentials(context.Background(), newTestVolume("", map[string]string{"secret": "s3-credentials"}))
+		want := Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}
+		if err != nil || fromRequest || got != want {
+			t.Errorf("credentials() = %+v, %v, %v, want %+v from the file", got, fromRequest, err, want)
+		}
+	})
+
+	t.Run("request secrets win", func(t *testing.T) {
+		ctx := storage.WithSecrets(context.Background(), map[string]string{
+			secretAccessKeyID:     "CSIKEY",
+			secretSecretAccessKey: "csi-secret",
+		})
+		got, fromRequest, err := b.credentials(ctx, newTestVolume("", map[string]string{"secret": "s3-credentials"}))
+		want := Credentials{AccessKeyID: "CSIKEY", SecretAccessKey: "csi-secret"}
+		if err != nil || !fromRequest || got != want {
+			t.Errorf("credentials() = %+v, %v, %v, want %+v from the request", got, fromRequest, err, want)
+		}
+	})
+
+	for _, name := range []string{"missing", "incomplete", "malformed"} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := b.credentials(context.Background(), newTestVolume("", map[string]string{"secret": name}))
+			if err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("credentials() error = %v, want an error naming secret %s", err, name)
+			}
+		})
+	}
+}
+
+func TestRelativeKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"vol/a.txt", "a.txt", true},
+		{"vol/dir/b.txt", filepath.Join("dir", "b.txt"), true},
+		{"vol/dir//c.txt", filepath.Join("dir", "c.txt"), true},
+		{"vol/./d.txt", "d.txt", true},
+		{"vol/", "", true},
+		{"vol/../escape.txt", "", false},
+		{"vol/dir/../../escape.txt", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := relativeKey("vol/", tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("relativeKey(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStageAndSyncBack(t *testing.T) {
+	fake, server := newFakeS3(t, "bucket", map[string]string{
+		"vol/a.txt":         "a",
+		"vol/dir/":          "",
+		"vol/dir/b.txt":     "b",
+		"vol/../escape.txt": "x",
+		"other/c.txt":       "c",
+	})
+
+	b := newTestBackend(t)
+	writeSecret(t, b, "s3-credentials", "access_key_id=AKID\nsecret_access_key=secret\n")
+	volume := newTestVolume(server.URL, map[string]string{"secret": "s3-credentials", "sync_back": "true"})
+	stagingPath := t.TempDir()
+	root := filepath.Join(stagingPath, dataDir)
+
+	if err := b.Stage(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	for rel, want := range map[string]string{"a.txt": "a", "dir/b.txt": "b"} {
+		got, err := os.ReadFile(filepath.Join(root, rel))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", rel, got, err, want)
+		}
+	}
+	for _, path := range []string{filepath.Join(stagingPath, "escape.txt"), filepath.Join(root, "c.txt")} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s was downloaded, want it skipped", path)
+		}
+	}
+
+	// Every request is signed with the credentials of the secret file
+	for _, request := range fake.Requests() {
+		if !strings.HasPrefix(request.Authorization, "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("%s %s Authorization = %q, want signed with AKID", request.Method, request.Path, request.Authorization)
+		}
+	}
+
+	// Staging again finds the completed download
+	requests := len(fake.Requests())
+	if err := b.Stage(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Stage() again error = %v", err)
+	}
+	if got := len(fake.Requests()); got != requests {
+		t.Errorf("Stage() again sent %d requests, want none", got-requests)
+	}
+
+	// Change, add and remove files, then upload the changes on unstage
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Unstage(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Unstage() error = %v", err)
+	}
+
+	for key, want := range map[string]string{"vol/a.txt": "changed", "vol/new.txt": "new"} {
+		if got, ok := fake.Object(key); !ok || got != want {
+			t.Errorf("object %s = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := fake.Object("vol/dir/b.txt"); ok {
+		t.Error("object vol/dir/b.txt still exists, want it deleted")
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Errorf("data directory still exists: %v", err)
+	}
+}
+
+func TestUnstageKeepsDataWhenUploadFails(t *testing.T) {
+	_, server := newFakeS3(t, "bucket", map[string]string{"vol/a.txt": "a"})
+
+	b := newTestBackend(t)
+	volume := newTestVolume(server.URL, map[string]string{"sync_back": "true"})
+	stagingPath := t.TempDir()
+
+	if err := b.Stage(context.Background(), volume, stagingPath); err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stagingPath, dataDir, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket is gone, so the upload fails
+	volume.Parameters["bucket"] = "missing"
+	if err := b.Unstage(context.Background(), volume, stagingPath); err == nil {
+		t.Fatal("Unstage() error = nil, want the failed upload")
+	}
+
+	if _, err := os.Stat(filepath.Join(stagingPath, dataDir, "a.txt")); err != nil {
+		t.Errorf("data was removed after a failed upload: %v", err)
+	}
+}
+
+func TestPublishNotStaged(t *testing.T) {
+	b := newTestBackend(t)
+	dir := t.TempDir()
+
+	err := b.Publish(context.Background(), newTestVolume("", nil), filepath.Join(dir, "staging"), filepath.Join(dir, "target"), false)
+	if !errors.Is(err, storage.ErrVolumeNotStaged) {
+		t.Fatalf("Publish() error = %v, want %v", err, storage.ErrVolumeNotStaged)
+	}
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "context"
+
+// secretsKey is the context key for the secrets of a volume operation
+type secretsKey struct{}
+
+// WithSecrets returns a context that carries the secrets the container
+// orchestrator passed along with a volume operation, e.g. the CSI secrets
+// of NodeStageVolume. Backends read them with SecretsFromContext.
+func WithSecrets(ctx context.Context, secrets map[string]string) context.Context {
+	if len(secrets) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, secretsKey{}, secrets)
+}
+
+// SecretsFromContext returns the secrets stored in ctx by WithSecrets, or nil
+func SecretsFromContext(ctx context.Context) map[string]string {
+	secrets, _ := ctx.Value(secretsKey{}).(map[string]string)
+	return secrets
+}
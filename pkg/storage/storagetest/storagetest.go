@@ -0,0 +1,52 @@
+// Package storagetest provides the fixtures shared by the tests of storage
+// backends.
+package storagetest
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// Logger returns a logger that discards everything, for backends under test
+func Logger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// WithParams returns a copy of base with override applied
+func WithParams(base, override map[string]string) map[string]string {
+	params := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		params[key] = value
+	}
+	for key, value := range override {
+		params[key] = value
+	}
+	return params
+}
+
+// NewVolume returns volume vol-1 of backend with the parameters of base,
+// overridden by params
+func NewVolume(backend string, base, params map[string]string) *types.Volume {
+	return &types.Volume{ID: "vol-1", Name: "data", Backend: backend, Parameters: WithParams(base, params)}
+}
+
+// RunValidate checks that validate accepts valid, and rejects it with each of
+// the overrides in invalid applied in a subtest named by its key
+func RunValidate(t *testing.T, validate func(params map[string]string) error, valid map[string]string, invalid map[string]map[string]string) {
+	t.Helper()
+	if err := validate(valid); err != nil {
+		t.Fatalf("Validate(%v) error = %v", valid, err)
+	}
+
+	for name, override := range invalid {
+		t.Run(name, func(t *testing.T) {
+			params := WithParams(valid, override)
+			if err := validate(params); err == nil {
+				t.Errorf("Validate(%v) error = nil, want an error", params)
+			}
+		})
+	}
+}
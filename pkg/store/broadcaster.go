@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+const (
+	// watchHistorySize is the number of past events kept for resuming watches
+	watchHistorySize = 1000
+
+	// watchBufferSize is the number of undelivered events a watcher may fall
+	// behind before it is dropped and has to resume from its last revision
+	watchBufferSize = 100
+)
+
+// broadcaster fans volume events out to in-process watchers and retains a
+// bounded history so watches can resume from a past revision
+type broadcaster struct {
+	mu          sync.Mutex
+	history     []types.VolumeEvent
+	compacted   int64 // revision of the newest event dropped from history
+	subscribers map[chan types.VolumeEvent]struct{}
+}
+
+// newBroadcaster creates an empty broadcaster
+func newBroadcaster() *broadcaster {
+	return &broadcaster{
+		subscribers: make(map[chan types.VolumeEvent]struct{}),
+	}
+}
+
+// publish delivers an event to all watchers. Callers must publish events in
+// revision order, typically while holding their store's write lock.
+func (b *broadcaster) publish(event types.VolumeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.history) == watchHistorySize {
+		b.compacted = b.history[0].Revision
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, event)
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Too slow; drop the watcher rather than block writers
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe starts a watch. initial holds the events to send first, used for
+// the current state when fromRevision is 0. Callers must hold their store's
+// write lock so no event is published between building initial and subscribing.
+func (b *broadcaster) subscribe(ctx context.Context, fromRevision int64, initial []types.VolumeEvent) (<-chan types.VolumeEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backlog := initial
+	if fromRevision > 0 {
+		if fromRevision < b.compacted {
+			return nil, ErrCompacted
+		}
+		for _, event := range b.history {
+			if event.Revision > fromRevision {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	ch := make(chan types.VolumeEvent, len(backlog)+watchBufferSize)
+	for _, event := range backlog {
+		ch <- event
+	}
+	b.subscribers[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+// publishN publishes events for revisions from+1 to from+n
+func publishN(b *broadcaster, from, n int64) {
+	for revision := from + 1; revision <= from+n; revision++ {
+		b.publish(types.VolumeEvent{Type: types.EventModified, Revision: revision, Volume: &types.Volume{ID: "vol-1"}})
+	}
+}
+
+func TestBroadcasterCompaction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := newBroadcaster()
+	publishN(b, 0, watchHistorySize+10)
+
+	// Revisions 1 to 10 were dropped from history
+	if _, err := b.subscribe(ctx, 9, nil); !errors.Is(err, ErrCompacted) {
+		t.Errorf("subscribe(9) error = %v, want ErrCompacted", err)
+	}
+
+	events, err := b.subscribe(ctx, 10, nil)
+	if err != nil {
+		t.Fatalf("subscribe(10) error = %v", err)
+	}
+	if len(events) != watchHistorySize {
+		t.Fatalf("backlog = %d events, want %d", len(events), watchHistorySize)
+	}
+	if event := <-events; event.Revision != 11 {
+		t.Errorf("first event revision = %d, want 11", event.Revision)
+	}
+}
+
+func TestBroadcasterDropsSlowWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := newBroadcaster()
+	slow, err := b.subscribe(ctx, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := b.subscribe(ctx, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fast watcher keeps up while the slow one never reads
+	for revision := int64(1); revision <= watchBufferSize+1; revision++ {
+		publishN(b, revision-1, 1)
+		if event := <-fast; event.Revision != revision {
+			t.Fatalf("fast watcher got revision %d, want %d", event.Revision, revision)
+		}
+	}
+
+	// The slow watcher gets what fit in its buffer, then sees the close
+	// and resumes from the last revision it received
+	var last int64
+	for event := range slow {
+		last = event.Revision
+	}
+	if last != watchBufferSize {
+		t.Errorf("slow watcher's last revision = %d, want %d", last, watchBufferSize)
+	}
+
+	resumed, err := b.subscribe(ctx, last, nil)
+	if err != nil {
+		t.Fatalf("subscribe(%d) error = %v", last, err)
+	}
+	if event := <-resumed; event.Revision != watchBufferSize+1 {
+		t.Errorf("resumed event revision = %d, want %d", event.Revision, watchBufferSize+1)
+	}
+}
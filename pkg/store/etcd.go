@@ -3,76 +3,180 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
-	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/namespace"
 	"go.etcd.io/etcd/server/v3/embed"
 
 	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
 
 const (
-	volumePrefix = "/volumes/"
-	namePrefix   = "/volume-names/"
+	volumePrefix       = "/volumes/"
+	namePrefix         = "/volume-names/"
+	snapshotPrefix     = "/snapshots/"
+	snapshotNamePrefix = "/snapshot-names/"
+	nodePrefix         = "/nodes/"
 )
 
-// EtcdStore implements a store backed by embedded etcd
+// EtcdStore implements a store backed by embedded or external etcd
 type EtcdStore struct {
-	etcd   *embed.Etcd
+	etcd   *embed.Etcd // nil when connected to an external cluster
 	client *clientv3.Client
 	logger *slog.Logger
+	cancel context.CancelFunc
 }
 
-// EtcdConfig holds configuration for embedded etcd
+// EtcdConfig holds configuration for embedded or external etcd
 type EtcdConfig struct {
-	DataDir      string
-	Name         string
-	ClusterSize  int
-	ServiceName  string
-	TaskSlot     int
-	ClientPort   int
-	PeerPort     int
+	DataDir     string
+	Name        string
+	ClusterSize int
+	ServiceName string
+	TaskSlot    int
+	ClientPort  int
+	PeerPort    int
+
+	// External cluster; when Endpoints is set no embedded server is started
+	Endpoints []string
+	CertFile  string
+	KeyFile   string
+	CAFile    string
+	Username  string
+	Password  string
+
+	// KeyPrefix is prepended to every key, e.g. "/volume-manager" stores
+	// volumes under "/volume-manager/volumes/". Empty keeps the default layout.
+	KeyPrefix string
+
+	// TLS for the embedded server's client and peer listeners (see EtcdTLS* modes)
+	TLSMode      string
+	TLSCertFile  string
+	TLSKeyFile   string
+	TLSCAFile    string
+	TLSCAKeyFile string // ca mode only, with TLSCAFile for a CA shared by all tasks
 }
 
-// NewEtcdStore creates a new etcd-backed store with embedded server
+// External reports whether the config points at an existing etcd cluster
+func (c EtcdConfig) External() bool {
+	return len(c.Endpoints) > 0
+}
+
+// NewEtcdStore creates a new etcd-backed store, starting an embedded server
+// unless external endpoints are configured
 func NewEtcdStore(cfg EtcdConfig, logger *slog.Logger) (*EtcdStore, error) {
+	var e *embed.Etcd
+	endpoints := cfg.Endpoints
+
+	if !cfg.External() {
+		var err error
+		cfg, err = prepareEtcdTLS(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		e, err = startEmbeddedEtcd(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = []string{fmt.Sprintf("localhost:%d", cfg.ClientPort)}
+	}
+
+	client, err := newEtcdClient(cfg, endpoints)
+	if err != nil {
+		if e != nil {
+			e.Close()
+		}
+		return nil, err
+	}
+
+	if cfg.External() {
+		// clientv3.New does not dial eagerly, so check reachability and
+		// permissions on the volume prefix before serving requests
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err := client.Get(ctx, volumePrefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+		cancel()
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to reach etcd at %v: %w", endpoints, err)
+		}
+		logger.Info("connected to external etcd",
+			"endpoints", endpoints,
+			"key_prefix", cfg.KeyPrefix,
+		)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &EtcdStore{
+		etcd:   e,
+		client: client,
+		logger: logger.With("store", "etcd"),
+		cancel: cancel,
+	}
+
+	if e != nil {
+		go s.pruneMembers(ctx, cfg)
+	}
+
+	return s, nil
+}
+
+// startEmbeddedEtcd starts the in-process etcd server and waits until it is ready
+func startEmbeddedEtcd(cfg EtcdConfig, logger *slog.Logger) (*embed.Etcd, error) {
 	// Create etcd configuration
 	etcdCfg := embed.NewConfig()
 	etcdCfg.Dir = filepath.Join(cfg.DataDir, "etcd")
-	etcdCfg.Name = fmt.Sprintf("%s-%d", cfg.ServiceName, cfg.TaskSlot)
+	etcdCfg.Name = cfg.memberName()
+	scheme := cfg.scheme()
 
 	// Listen URLs
-	etcdCfg.ListenClientUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("0.0.0.0:%d", cfg.ClientPort)}}
-	etcdCfg.ListenPeerUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("0.0.0.0:%d", cfg.PeerPort)}}
+	etcdCfg.ListenClientUrls = []url.URL{{Scheme: scheme, Host: fmt.Sprintf("0.0.0.0:%d", cfg.ClientPort)}}
+	etcdCfg.ListenPeerUrls = []url.URL{{Scheme: scheme, Host: fmt.Sprintf("0.0.0.0:%d", cfg.PeerPort)}}
+	applyServerTLS(etcdCfg, cfg)
 
 	// Advertise URLs (use hostname or localhost for single node)
 	if cfg.ClusterSize > 1 {
+		// The leader prunes members above ClusterSize, so such a task could never stay
+		if cfg.TaskSlot > cfg.ClusterSize {
+			return nil, fmt.Errorf("task slot %d exceeds cluster size %d; raise CLUSTER_SIZE before scaling up", cfg.TaskSlot, cfg.ClusterSize)
+		}
+
 		// Multi-node cluster - use Docker Swarm DNS
 		hostname := fmt.Sprintf("%s.%d", cfg.ServiceName, cfg.TaskSlot)
-		etcdCfg.AdvertiseClientUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("%s:%d", hostname, cfg.ClientPort)}}
-		etcdCfg.AdvertisePeerUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("%s:%d", hostname, cfg.PeerPort)}}
-
-		// Build initial cluster string
-		initialCluster := make([]string, cfg.ClusterSize)
-		for i := 1; i <= cfg.ClusterSize; i++ {
-			memberName := fmt.Sprintf("%s-%d", cfg.ServiceName, i)
-			peerURL := fmt.Sprintf("http://%s.%d:%d", cfg.ServiceName, i, cfg.PeerPort)
-			initialCluster[i-1] = fmt.Sprintf("%s=%s", memberName, peerURL)
-		}
+		etcdCfg.AdvertiseClientUrls = []url.URL{{Scheme: scheme, Host: fmt.Sprintf("%s:%d", hostname, cfg.ClientPort)}}
+		etcdCfg.AdvertisePeerUrls = []url.URL{{Scheme: scheme, Host: fmt.Sprintf("%s:%d", hostname, cfg.PeerPort)}}
 
-		etcdCfg.InitialCluster = initialClusterFromSlice(initialCluster)
+		etcdCfg.InitialCluster = staticInitialCluster(cfg)
 		etcdCfg.ClusterState = embed.ClusterStateFlagNew
+
+		// A task without data joins a running cluster instead of bootstrapping,
+		// which covers scaling up and replacing a task that lost its volume
+		if !hasMemberData(etcdCfg.Dir) {
+			joined, err := joinExistingCluster(cfg, etcdCfg.Name, etcdCfg.AdvertisePeerUrls[0].String(), logger)
+			if err != nil {
+				return nil, err
+			}
+			if joined != "" {
+				etcdCfg.InitialCluster = joined
+				etcdCfg.ClusterState = embed.ClusterStateFlagExisting
+			}
+		}
 	} else {
 		// Single node - use default configuration
-		etcdCfg.AdvertiseClientUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("localhost:%d", cfg.ClientPort)}}
-		etcdCfg.AdvertisePeerUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("localhost:%d", cfg.PeerPort)}}
+		etcdCfg.AdvertiseClientUrls = []url.URL{{Scheme: scheme, Host: fmt.Sprintf("localhost:%d", cfg.ClientPort)}}
+		etcdCfg.AdvertisePeerUrls = []url.URL{{Scheme: scheme, Host: fmt.Sprintf("localhost:%d", cfg.PeerPort)}}
 		// For single node, set initial cluster to itself
-		etcdCfg.InitialCluster = fmt.Sprintf("%s=http://localhost:%d", etcdCfg.Name, cfg.PeerPort)
+		etcdCfg.InitialCluster = staticInitialCluster(cfg)
 	}
 
 	// Logging
@@ -82,6 +186,8 @@ func NewEtcdStore(cfg EtcdConfig, logger *slog.Logger) (*EtcdStore, error) {
 		"name", etcdCfg.Name,
 		"data_dir", etcdCfg.Dir,
 		"cluster_size", cfg.ClusterSize,
+		"cluster_state", etcdCfg.ClusterState,
+		"tls_mode", cfg.TLSMode,
 	)
 
 	// Start embedded etcd
@@ -99,21 +205,39 @@ func NewEtcdStore(cfg EtcdConfig, logger *slog.Logger) (*EtcdStore, error) {
 		return nil, fmt.Errorf("etcd took too long to start")
 	}
 
-	// Create etcd client
-	client, err := clientv3.New(clientv3.Config{
-		Endpoints:   []string{fmt.Sprintf("localhost:%d", cfg.ClientPort)},
+	return e, nil
+}
+
+// newEtcdClient creates a client for the given endpoints, applying TLS,
+// authentication and the key prefix from cfg
+func newEtcdClient(cfg EtcdConfig, endpoints []string) (*clientv3.Client, error) {
+	clientCfg := clientv3.Config{
+		Endpoints:   endpoints,
 		DialTimeout: 5 * time.Second,
-	})
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+	}
+
+	tlsConfig, err := cfg.clientTLS()
+	if err != nil {
+		return nil, err
+	}
+	clientCfg.TLS = tlsConfig
+
+	client, err := clientv3.New(clientCfg)
 	if err != nil {
-		e.Close()
 		return nil, fmt.Errorf("failed to create etcd client: %w", err)
 	}
 
-	return &EtcdStore{
-		etcd:   e,
-		client: client,
-		logger: logger.With("store", "etcd"),
-	}, nil
+	// Namespacing keeps the /volumes/ and /volume-names/ layout under the prefix,
+	// so embedded and external deployments stay interchangeable
+	if prefix := strings.TrimSuffix(cfg.KeyPrefix, "/"); prefix != "" {
+		client.KV = namespace.NewKV(client.KV, prefix)
+		client.Watcher = namespace.NewWatcher(client.Watcher, prefix)
+		client.Lease = namespace.NewLease(client.Lease, prefix)
+	}
+
+	return client, nil
 }
 
 // CreateVolume creates a new volume
@@ -153,6 +277,8 @@ func (s *EtcdStore) CreateVolume(ctx context.Context, volume *types.Volume) erro
 		return ErrAlreadyExists
 	}
 
+	volume.ResourceVersion = resp.Header.Revision
+
 	s.logger.Debug("volume created in etcd", "volume_id", volume.ID, "name", volume.Name)
 	return nil
 }
@@ -173,6 +299,7 @@ func (s *EtcdStore) GetVolume(ctx context.Context, id string) (*types.Volume, er
 	if err := json.Unmarshal(resp.Kvs[0].Value, &volume); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal volume: %w", err)
 	}
+	volume.ResourceVersion = resp.Kvs[0].ModRevision
 
 	return &volume, nil
 }
@@ -194,38 +321,60 @@ func (s *EtcdStore) GetVolumeByName(ctx context.Context, name string) (*types.Vo
 	return s.GetVolume(ctx, volumeID)
 }
 
-// ListVolumes lists all volumes
-func (s *EtcdStore) ListVolumes(ctx context.Context) ([]*types.Volume, error) {
-	resp, err := s.client.Get(ctx, volumePrefix, clientv3.WithPrefix())
+// ListVolumes lists a page of the volumes matching opts. Volumes are read in
+// key (ID) order with range requests limited to one more than the page, the
+// extra volume telling whether there is a next page; filtered out volumes
+// are skipped by reading on.
+func (s *EtcdStore) ListVolumes(ctx context.Context, opts ListOptions) ([]*types.Volume, string, error) {
+	lastID, err := decodeContinue(opts.Continue)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list volumes: %w", err)
+		return nil, "", err
 	}
 
-	volumes := make([]*types.Volume, 0, resp.Count)
-	for _, kv := range resp.Kvs {
-		var volume types.Volume
-		if err := json.Unmarshal(kv.Value, &volume); err != nil {
-			s.logger.Warn("failed to unmarshal volume", "error", err)
-			continue
-		}
-		volumes = append(volumes, &volume)
+	from := volumePrefix
+	if lastID != "" {
+		from = volumePrefix + lastID + "\x00"
+	}
+	getOpts := []clientv3.OpOption{clientv3.WithRange(clientv3.GetPrefixRangeEnd(volumePrefix))}
+	if opts.Limit > 0 {
+		getOpts = append(getOpts, clientv3.WithLimit(int64(opts.Limit)+1))
 	}
 
-	return volumes, nil
+	page := make([]*types.Volume, 0)
+	for {
+		resp, err := s.client.Get(ctx, from, getOpts...)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list volumes: %w", err)
+		}
+
+		for _, kv := range resp.Kvs {
+			var volume types.Volume
+			if err := json.Unmarshal(kv.Value, &volume); err != nil {
+				s.logger.Warn("failed to unmarshal volume", "error", err)
+				continue
+			}
+			volume.ResourceVersion = kv.ModRevision
+
+			if !opts.Filter.Matches(&volume) {
+				continue
+			}
+			if opts.Limit > 0 && len(page) == opts.Limit {
+				// Another volume matches, so there is a next page
+				return page, encodeContinue(page[len(page)-1].ID), nil
+			}
+			page = append(page, &volume)
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			return page, "", nil
+		}
+		from = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
 }
 
-// UpdateVolume updates an existing volume
+// UpdateVolume updates an existing volume if it has not been modified since it was read
 func (s *EtcdStore) UpdateVolume(ctx context.Context, volume *types.Volume) error {
-	// Check if volume exists
 	key := volumePrefix + volume.ID
-	getResp, err := s.client.Get(ctx, key)
-	if err != nil {
-		return fmt.Errorf("failed to check volume: %w", err)
-	}
-
-	if getResp.Count == 0 {
-		return ErrNotFound
-	}
 
 	// Serialize volume
 	data, err := json.Marshal(volume)
@@ -233,43 +382,367 @@ func (s *EtcdStore) UpdateVolume(ctx context.Context, volume *types.Volume) erro
 		return fmt.Errorf("failed to marshal volume: %w", err)
 	}
 
-	// Update volume
-	if _, err := s.client.Put(ctx, key, string(data)); err != nil {
+	// Compare-and-swap on the mod revision the volume was read at
+	txn := s.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.CreateRevision(key), ">", 0),
+			clientv3.Compare(clientv3.ModRevision(key), "=", volume.ResourceVersion),
+		).
+		Then(clientv3.OpPut(key, string(data))).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly()))
+
+	resp, err := txn.Commit()
+	if err != nil {
 		return fmt.Errorf("failed to update volume: %w", err)
 	}
 
-	s.logger.Debug("volume updated in etcd", "volume_id", volume.ID)
+	if !resp.Succeeded {
+		if resp.Responses[0].GetResponseRange().Count == 0 {
+			return ErrNotFound
+		}
+		return ErrConflict
+	}
+
+	volume.ResourceVersion = resp.Header.Revision
+
+	s.logger.Debug("volume updated in etcd", "volume_id", volume.ID, "revision", volume.ResourceVersion)
 	return nil
 }
 
-// DeleteVolume deletes a volume by ID
-func (s *EtcdStore) DeleteVolume(ctx context.Context, id string) error {
+// DeleteVolume deletes a volume if it has not been modified since it was read
+func (s *EtcdStore) DeleteVolume(ctx context.Context, id string, resourceVersion int64) error {
 	// Get volume to find name
 	volume, err := s.GetVolume(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	// Delete both volume and name mapping
+	// Delete both volume and name mapping, as long as the volume is still
+	// at the mod revision it was read at
 	volumeKey := volumePrefix + id
 	nameKey := namePrefix + volume.Name
 
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(volumeKey), "=", resourceVersion)).
+		Then(
+			clientv3.OpDelete(volumeKey),
+			clientv3.OpDelete(nameKey),
+		).
+		Else(clientv3.OpGet(volumeKey, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("failed to delete volume: %w", err)
+	}
+
+	if !resp.Succeeded {
+		if resp.Responses[0].GetResponseRange().Count == 0 {
+			return ErrNotFound
+		}
+		return ErrConflict
+	}
+
+	s.logger.Debug("volume deleted from etcd", "volume_id", id)
+	return nil
+}
+
+// CreateSnapshot records a new snapshot
+func (s *EtcdStore) CreateSnapshot(ctx context.Context, snapshot *types.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	snapshotKey := snapshotPrefix + snapshot.ID
+	nameKey := snapshotNamePrefix + snapshot.Name
+
+	resp, err := s.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.Version(nameKey), "=", 0),
+			clientv3.Compare(clientv3.Version(snapshotKey), "=", 0),
+		).
+		Then(
+			clientv3.OpPut(snapshotKey, string(data)),
+			clientv3.OpPut(nameKey, snapshot.ID),
+		).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	if !resp.Succeeded {
+		return ErrSnapshotExists
+	}
+
+	s.logger.Debug("snapshot created in etcd", "snapshot_id", snapshot.ID, "name", snapshot.Name)
+	return nil
+}
+
+// GetSnapshot retrieves a snapshot by ID
+func (s *EtcdStore) GetSnapshot(ctx context.Context, id string) (*types.Snapshot, error) {
+	resp, err := s.client.Get(ctx, snapshotPrefix+id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+
+	if resp.Count == 0 {
+		return nil, ErrSnapshotNotFound
+	}
+
+	var snapshot types.Snapshot
+	if err := json.Unmarshal(resp.Kvs[0].Value, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// GetSnapshotByName retrieves a snapshot by name
+func (s *EtcdStore) GetSnapshotByName(ctx context.Context, name string) (*types.Snapshot, error) {
+	resp, err := s.client.Get(ctx, snapshotNamePrefix+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot name: %w", err)
+	}
+
+	if resp.Count == 0 {
+		return nil, ErrSnapshotNotFound
+	}
+
+	return s.GetSnapshot(ctx, string(resp.Kvs[0].Value))
+}
+
+// ListSnapshots lists the snapshots of a volume, or all snapshots
+func (s *EtcdStore) ListSnapshots(ctx context.Context, sourceVolumeID string) ([]*types.Snapshot, error) {
+	resp, err := s.client.Get(ctx, snapshotPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	snapshots := make([]*types.Snapshot, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		var snapshot types.Snapshot
+		if err := json.Unmarshal(kv.Value, &snapshot); err != nil {
+			s.logger.Warn("failed to unmarshal snapshot", "error", err)
+			continue
+		}
+		if sourceVolumeID == "" || snapshot.SourceVolumeID == sourceVolumeID {
+			snapshots = append(snapshots, &snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// UpdateSnapshot applies mutate to a snapshot, compare-and-swapping on the
+// revision it was read at and starting over when another write got there first
+func (s *EtcdStore) UpdateSnapshot(ctx context.Context, id string, mutate func(snapshot *types.Snapshot) error) (*types.Snapshot, error) {
+	key := snapshotPrefix + id
+
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get snapshot: %w", err)
+		}
+		if resp.Count == 0 {
+			return nil, ErrSnapshotNotFound
+		}
+
+		var snapshot types.Snapshot
+		if err := json.Unmarshal(resp.Kvs[0].Value, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		}
+
+		if err := mutate(&snapshot); err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(&snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
+
+		txn, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+			Then(clientv3.OpPut(key, string(data))).
+			Commit()
+		if err != nil {
+			return nil, fmt.Errorf("failed to update snapshot: %w", err)
+		}
+		if txn.Succeeded {
+			s.logger.Debug("snapshot updated in etcd", "snapshot_id", id)
+			return &snapshot, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: gave up after %d attempts", ErrConflict, maxUpdateRetries)
+}
+
+// DeleteSnapshot deletes a snapshot by ID
+func (s *EtcdStore) DeleteSnapshot(ctx context.Context, id string) error {
+	snapshot, err := s.GetSnapshot(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	ops := []clientv3.Op{
-		clientv3.OpDelete(volumeKey),
-		clientv3.OpDelete(nameKey),
+		clientv3.OpDelete(snapshotPrefix + id),
+		clientv3.OpDelete(snapshotNamePrefix + snapshot.Name),
 	}
 
 	if _, err := s.client.Txn(ctx).Then(ops...).Commit(); err != nil {
-		return fmt.Errorf("failed to delete volume: %w", err)
+		return fmt.Errorf("failed to delete snapshot: %w", err)
 	}
 
-	s.logger.Debug("volume deleted from etcd", "volume_id", id)
+	s.logger.Debug("snapshot deleted from etcd", "snapshot_id", id)
+	return nil
+}
+
+// Watch streams volume changes until ctx is cancelled
+func (s *EtcdStore) Watch(ctx context.Context, fromRevision int64) (<-chan types.VolumeEvent, error) {
+	var initial []*mvccpb.KeyValue
+
+	if fromRevision == 0 {
+		// Send the current state, then watch from the revision it was read at
+		resp, err := s.client.Get(ctx, volumePrefix, clientv3.WithPrefix())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list volumes: %w", err)
+		}
+		initial = resp.Kvs
+		fromRevision = resp.Header.Revision
+
+		// Send in revision order like the other stores, so a client resuming
+		// from the last event it saw gets every volume it missed replayed
+		sort.Slice(initial, func(i, j int) bool { return initial[i].ModRevision < initial[j].ModRevision })
+	} else {
+		// Fail up front instead of mid-stream if the revision is gone
+		_, err := s.client.Get(ctx, volumePrefix, clientv3.WithPrefix(), clientv3.WithCountOnly(), clientv3.WithRev(fromRevision+1))
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			return nil, ErrCompacted
+		}
+		if err != nil && !errors.Is(err, rpctypes.ErrFutureRev) {
+			return nil, fmt.Errorf("failed to check watch revision: %w", err)
+		}
+	}
+
+	watchCh := s.client.Watch(clientv3.WithRequireLeader(ctx), volumePrefix,
+		clientv3.WithPrefix(),
+		clientv3.WithPrevKV(),
+		clientv3.WithRev(fromRevision+1),
+	)
+
+	events := make(chan types.VolumeEvent)
+
+	go func() {
+		defer close(events)
+
+		send := func(event types.VolumeEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, kv := range initial {
+			event, err := s.toEvent(types.EventAdded, kv)
+			if err != nil {
+				s.logger.Warn("failed to decode volume", "key", string(kv.Key), "error", err)
+				continue
+			}
+			if !send(event) {
+				return
+			}
+		}
+
+		for resp := range watchCh {
+			if err := resp.Err(); err != nil {
+				s.logger.Warn("volume watch ended", "error", err)
+				return
+			}
+
+			for _, ev := range resp.Events {
+				var event types.VolumeEvent
+				var err error
+				switch {
+				case ev.Type == clientv3.EventTypeDelete:
+					event, err = s.toEvent(types.EventDeleted, ev.PrevKv)
+					event.Revision = ev.Kv.ModRevision
+				case ev.IsCreate():
+					event, err = s.toEvent(types.EventAdded, ev.Kv)
+				default:
+					event, err = s.toEvent(types.EventModified, ev.Kv)
+				}
+				if err != nil {
+					s.logger.Warn("failed to decode volume event", "key", string(ev.Kv.Key), "error", err)
+					continue
+				}
+				if !send(event) {
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// toEvent decodes a volume key-value into a watch event
+func (s *EtcdStore) toEvent(eventType types.EventType, kv *mvccpb.KeyValue) (types.VolumeEvent, error) {
+	if kv == nil {
+		return types.VolumeEvent{}, fmt.Errorf("missing key-value for %s event", eventType)
+	}
+
+	var volume types.Volume
+	if err := json.Unmarshal(kv.Value, &volume); err != nil {
+		return types.VolumeEvent{}, fmt.Errorf("failed to unmarshal volume: %w", err)
+	}
+	volume.ResourceVersion = kv.ModRevision
+
+	return types.VolumeEvent{
+		Type:     eventType,
+		Revision: kv.ModRevision,
+		Volume:   &volume,
+	}, nil
+}
+
+// PutNode records a node
+func (s *EtcdStore) PutNode(ctx context.Context, node *types.Node) error {
+	data, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node: %w", err)
+	}
+
+	if _, err := s.client.Put(ctx, nodePrefix+node.ID, string(data)); err != nil {
+		return fmt.Errorf("failed to put node: %w", err)
+	}
 	return nil
 }
 
-// Close closes the store and stops etcd
+// ListNodes lists the registered nodes in ID order
+func (s *EtcdStore) ListNodes(ctx context.Context) ([]*types.Node, error) {
+	resp, err := s.client.Get(ctx, nodePrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	// Keys are returned in byte order, which is ID order
+	nodes := make([]*types.Node, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		var node types.Node
+		if err := json.Unmarshal(kv.Value, &node); err != nil {
+			s.logger.Warn("failed to unmarshal node", "error", err)
+			continue
+		}
+		nodes = append(nodes, &node)
+	}
+
+	return nodes, nil
+}
+
+// Close closes the store and stops the embedded etcd, if any
 func (s *EtcdStore) Close() error {
 	s.logger.Info("closing etcd store")
+	s.cancel()
 
 	if s.client != nil {
 		if err := s.client.Close(); err != nil {
@@ -286,6 +759,30 @@ func (s *EtcdStore) Close() error {
 	return nil
 }
 
+// staticInitialCluster returns the initial cluster for bootstrapping from the
+// configured slots, or just this member on a single node
+func staticInitialCluster(cfg EtcdConfig) string {
+	if cfg.ClusterSize <= 1 {
+		return fmt.Sprintf("%s=%s", cfg.memberName(), cfg.peerURL())
+	}
+
+	initialCluster := make([]string, 0, cfg.ClusterSize)
+	for i := 1; i <= cfg.ClusterSize; i++ {
+		memberName := fmt.Sprintf("%s-%d", cfg.ServiceName, i)
+		peerURL := fmt.Sprintf("%s://%s.%d:%d", cfg.scheme(), cfg.ServiceName, i, cfg.PeerPort)
+		initialCluster = append(initialCluster, fmt.Sprintf("%s=%s", memberName, peerURL))
+	}
+	return initialClusterFromSlice(initialCluster)
+}
+
+// peerURL returns the peer URL this member advertises
+func (c EtcdConfig) peerURL() string {
+	if c.ClusterSize <= 1 {
+		return fmt.Sprintf("%s://localhost:%d", c.scheme(), c.PeerPort)
+	}
+	return fmt.Sprintf("%s://%s.%d:%d", c.scheme(), c.ServiceName, c.TaskSlot, c.PeerPort)
+}
+
 // initialClusterFromSlice converts a slice of member=url strings to comma-separated string
 func initialClusterFromSlice(members []string) string {
 	result := ""
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+const (
+	// memberPruneInterval is how often the leader checks for members of removed slots
+	memberPruneInterval = 30 * time.Second
+
+	// memberStatusTimeout bounds the health probe of a single member
+	memberStatusTimeout = 2 * time.Second
+
+	// memberDiscoveryTimeout bounds the search for a running cluster
+	memberDiscoveryTimeout = 5 * time.Second
+
+	// memberJoinTimeout bounds joining a found cluster, including waiting for
+	// one that is briefly unhealthy during a rolling update
+	memberJoinTimeout = 60 * time.Second
+)
+
+// hasMemberData reports whether an etcd data directory already holds a member,
+// in which case etcd restarts from its WAL and ignores the bootstrap settings
+func hasMemberData(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "member", "wal"))
+	return err == nil
+}
+
+// discoverPeers returns client URLs of other tasks of the service. Swarm's
+// tasks.<service> DNS name resolves to every running task; the per-slot
+// hostnames cover setups where only those are resolvable.
+func discoverPeers(cfg EtcdConfig) []string {
+	seen := make(map[string]bool)
+	var endpoints []string
+	add := func(host string) {
+		endpoint := fmt.Sprintf("%s://%s", cfg.scheme(), net.JoinHostPort(host, strconv.Itoa(cfg.ClientPort)))
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if addrs, err := net.DefaultResolver.LookupHost(ctx, "tasks."+cfg.ServiceName); err == nil {
+		for _, addr := range addrs {
+			add(addr)
+		}
+	}
+
+	for i := 1; i <= cfg.ClusterSize; i++ {
+		if i != cfg.TaskSlot {
+			add(fmt.Sprintf("%s.%d", cfg.ServiceName, i))
+		}
+	}
+
+	return endpoints
+}
+
+// joinExistingCluster adds this task to a running cluster and returns the
+// initial cluster string to start with. It returns "" when no cluster with
+// quorum is reachable, so the caller bootstraps a new one.
+func joinExistingCluster(cfg EtcdConfig, name, peerURL string, logger *slog.Logger) (string, error) {
+	endpoints := discoverPeers(cfg)
+	if len(endpoints) == 0 {
+		return "", nil
+	}
+
+	client, err := newEtcdClient(cfg, endpoints)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	// Probe briefly: when the whole service starts at once nobody is reachable yet
+	listCtx, listCancel := context.WithTimeout(context.Background(), memberDiscoveryTimeout)
+	listResp, err := client.MemberList(listCtx)
+	listCancel()
+	if err != nil {
+		logger.Info("no existing etcd cluster found, bootstrapping", "error", err)
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), memberJoinTimeout)
+	defer cancel()
+
+	var newID uint64
+	members := listResp.Members
+	for _, m := range members {
+		switch {
+		case m.Name == "" && containsString(m.PeerURLs, peerURL):
+			// Already added (e.g. a previous attempt crashed before starting)
+			newID = m.ID
+		case m.Name == name:
+			// A previous task in this slot lost its data; replace its member entry
+			logger.Warn("removing stale etcd member for this slot", "member_id", fmt.Sprintf("%x", m.ID))
+			if _, err := client.MemberRemove(ctx, m.ID); err != nil {
+				return "", fmt.Errorf("failed to remove stale member %x: %w", m.ID, err)
+			}
+		}
+	}
+
+	if newID == 0 {
+		addResp, err := addMember(ctx, client, peerURL, logger)
+		if err != nil {
+			return "", err
+		}
+		newID = addResp.Member.ID
+		members = addResp.Members
+		logger.Info("added etcd member to existing cluster", "member_id", fmt.Sprintf("%x", newID))
+	} else {
+		// Refresh the list so it no longer contains members removed above
+		listResp, err = client.MemberList(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list etcd members: %w", err)
+		}
+		members = listResp.Members
+	}
+
+	var initialCluster []string
+	for _, m := range members {
+		memberName := m.Name
+		if m.ID == newID {
+			memberName = name
+		}
+		for _, u := range m.PeerURLs {
+			initialCluster = append(initialCluster, fmt.Sprintf("%s=%s", memberName, u))
+		}
+	}
+
+	return initialClusterFromSlice(initialCluster), nil
+}
+
+// addMember calls MemberAdd, retrying while etcd refuses reconfiguration
+// because some members only just (re)connected
+func addMember(ctx context.Context, client *clientv3.Client, peerURL string, logger *slog.Logger) (*clientv3.MemberAddResponse, error) {
+	for {
+		resp, err := client.MemberAdd(ctx, []string{peerURL})
+		if err == nil {
+			return resp, nil
+		}
+		if !errors.Is(err, rpctypes.ErrUnhealthy) {
+			return nil, fmt.Errorf("failed to add etcd member: %w", err)
+		}
+
+		logger.Info("etcd cluster not ready for new members, retrying", "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to add etcd member: %w", err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// pruneMembers removes members whose task slot is above ClusterSize after the
+// service was scaled down. Only the leader acts, so members never race.
+func (s *EtcdStore) pruneMembers(ctx context.Context, cfg EtcdConfig) {
+	ticker := time.NewTicker(memberPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if s.etcd.Server.Leader() != s.etcd.Server.MemberID() {
+			continue
+		}
+
+		listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		resp, err := s.client.MemberList(listCtx)
+		if err != nil {
+			cancel()
+			s.logger.Warn("failed to list etcd members", "error", err)
+			continue
+		}
+
+		for _, m := range resp.Members {
+			slot, ok := memberSlot(cfg.ServiceName, m.Name)
+			if !ok || slot <= cfg.ClusterSize || m.ID == uint64(s.etcd.Server.MemberID()) {
+				continue
+			}
+			if _, err := s.client.MemberRemove(listCtx, m.ID); err != nil {
+				s.logger.Warn("failed to remove etcd member", "name", m.Name, "error", err)
+				continue
+			}
+			s.logger.Info("removed etcd member of scaled-down slot", "name", m.Name, "slot", slot)
+		}
+		cancel()
+	}
+}
+
+// Members lists the etcd cluster members and probes each one's health
+func (s *EtcdStore) Members(ctx context.Context) ([]types.ClusterMember, error) {
+	resp, err := s.client.MemberList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd members: %w", err)
+	}
+
+	members := make([]types.ClusterMember, len(resp.Members))
+	leaders := make([]uint64, len(resp.Members))
+
+	var wg sync.WaitGroup
+	for i, m := range resp.Members {
+		members[i] = types.ClusterMember{
+			ID:         fmt.Sprintf("%x", m.ID),
+			Name:       m.Name,
+			PeerURLs:   m.PeerURLs,
+			ClientURLs: m.ClientURLs,
+			IsLearner:  m.IsLearner,
+		}
+
+		if len(m.ClientURLs) == 0 {
+			members[i].Error = "member has not started"
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			statusCtx, cancel := context.WithTimeout(ctx, memberStatusTimeout)
+			defer cancel()
+
+			status, err := s.client.Status(statusCtx, endpoint)
+			if err != nil {
+				members[i].Error = err.Error()
+				return
+			}
+			members[i].Healthy = len(status.Errors) == 0
+			members[i].DBSize = status.DbSize
+			members[i].RaftIndex = status.RaftIndex
+			if len(status.Errors) > 0 {
+				members[i].Error = strings.Join(status.Errors, "; ")
+			}
+			leaders[i] = status.Leader
+		}(i, m.ClientURLs[0])
+	}
+	wg.Wait()
+
+	// Any healthy member's view of the leader will do
+	var leader uint64
+	for _, id := range leaders {
+		if id != 0 {
+			leader = id
+			break
+		}
+	}
+	for i, m := range resp.Members {
+		members[i].IsLeader = m.ID == leader
+	}
+
+	return members, nil
+}
+
+// memberSlot extracts the task slot from a member name of the form <service>-<slot>
+func memberSlot(serviceName, memberName string) (int, bool) {
+	suffix, ok := strings.CutPrefix(memberName, serviceName+"-")
+	if !ok {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0, false
+	}
+	return slot, true
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	"go.etcd.io/etcd/server/v3/embed"
+
+	"github.com/sistemica/docker-volume-manager/pkg/pki"
+)
+
+// TLS modes for the embedded etcd's client and peer listeners
+const (
+	EtcdTLSNone  = "none"  // plain HTTP
+	EtcdTLSFiles = "files" // operator-supplied certificate, key and CA
+	EtcdTLSAuto  = "auto"  // self-signed per member; encrypts but does not authenticate
+	EtcdTLSCA    = "ca"    // certificates issued at startup from a CA in DATA_DIR/pki or a shared one
+)
+
+// scheme returns the URL scheme of the embedded etcd listeners
+func (c EtcdConfig) scheme() string {
+	if c.TLSMode == "" || c.TLSMode == EtcdTLSNone {
+		return "http"
+	}
+	return "https"
+}
+
+// memberName returns the etcd member name of this task
+func (c EtcdConfig) memberName() string {
+	return fmt.Sprintf("%s-%d", c.ServiceName, c.TaskSlot)
+}
+
+// prepareEtcdTLS issues this member's certificate in ca mode and points the
+// TLS file fields at it. Other modes are returned unchanged.
+func prepareEtcdTLS(cfg EtcdConfig, logger *slog.Logger) (EtcdConfig, error) {
+	if cfg.TLSMode != EtcdTLSCA {
+		return cfg, nil
+	}
+
+	pkiDir := filepath.Join(cfg.DataDir, "pki")
+
+	var ca *pki.CA
+	var err error
+	if cfg.TLSCAFile != "" {
+		// Shared CA, typically mounted from Swarm secrets on every task
+		ca, err = pki.LoadCA(cfg.TLSCAFile, cfg.TLSCAKeyFile)
+	} else {
+		// Every task has its own DATA_DIR, so a generated CA is only trusted by this task
+		if cfg.ClusterSize > 1 {
+			return cfg, fmt.Errorf("TLS mode %q with cluster size %d needs a shared CA: set ETCD_TLS_CA_FILE and ETCD_TLS_CA_KEY_FILE", EtcdTLSCA, cfg.ClusterSize)
+		}
+		cfg.TLSCAFile = filepath.Join(pkiDir, "ca.crt")
+		ca, err = pki.LoadOrCreateCA(cfg.TLSCAFile, filepath.Join(pkiDir, "ca.key"), cfg.ServiceName+" etcd CA")
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	// Peers are addressed by slot hostname, clients by localhost, and
+	// discovery through tasks.<service> yields container IPs
+	name := cfg.memberName()
+	dnsNames := []string{
+		"localhost",
+		fmt.Sprintf("%s.%d", cfg.ServiceName, cfg.TaskSlot),
+		cfg.ServiceName,
+		"tasks." + cfg.ServiceName,
+	}
+
+	cfg.TLSCertFile = filepath.Join(pkiDir, name+".crt")
+	cfg.TLSKeyFile = filepath.Join(pkiDir, name+".key")
+	if err := ca.Issue(cfg.TLSCertFile, cfg.TLSKeyFile, name, dnsNames, pki.LocalIPs()); err != nil {
+		return cfg, err
+	}
+
+	logger.Info("issued etcd member certificate", "name", name, "cert_file", cfg.TLSCertFile, "ca_file", cfg.TLSCAFile)
+	return cfg, nil
+}
+
+// applyServerTLS configures TLS on the embedded server's client and peer listeners
+func applyServerTLS(etcdCfg *embed.Config, cfg EtcdConfig) {
+	switch cfg.TLSMode {
+	case EtcdTLSAuto:
+		etcdCfg.ClientAutoTLS = true
+		etcdCfg.PeerAutoTLS = true
+	case EtcdTLSFiles, EtcdTLSCA:
+		info := transport.TLSInfo{
+			CertFile:       cfg.TLSCertFile,
+			KeyFile:        cfg.TLSKeyFile,
+			TrustedCAFile:  cfg.TLSCAFile,
+			ClientCertAuth: true,
+		}
+		etcdCfg.ClientTLSInfo = info
+		etcdCfg.PeerTLSInfo = info
+	}
+}
+
+// clientTLS returns the TLS configuration for clients of this store, or nil for plain HTTP
+func (c EtcdConfig) clientTLS() (*tls.Config, error) {
+	var info transport.TLSInfo
+
+	switch {
+	case c.External():
+		if c.CertFile == "" && c.CAFile == "" {
+			return nil, nil
+		}
+		info = transport.TLSInfo{CertFile: c.CertFile, KeyFile: c.KeyFile, TrustedCAFile: c.CAFile}
+	case c.TLSMode == EtcdTLSAuto:
+		// Auto TLS certificates are self-signed per member and cannot be verified
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case c.TLSMode == EtcdTLSFiles || c.TLSMode == EtcdTLSCA:
+		// The member certificate is also valid for client authentication
+		info = transport.TLSInfo{CertFile: c.TLSCertFile, KeyFile: c.TLSKeyFile, TrustedCAFile: c.TLSCAFile}
+	default:
+		return nil, nil
+	}
+
+	tlsConfig, err := info.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load etcd client TLS config: %w", err)
+	}
+	return tlsConfig, nil
+}
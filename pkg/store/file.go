@@ -0,0 +1,557 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+var (
+	volumesBucket       = []byte("volumes")
+	namesBucket         = []byte("volume-names")
+	snapshotsBucket     = []byte("snapshots")
+	snapshotNamesBucket = []byte("snapshot-names")
+	nodesBucket         = []byte("nodes")
+	metaBucket          = []byte("meta")
+	revisionKey         = []byte("revision")
+)
+
+// FileStore implements a single-node store persisted in a bbolt file.
+// It needs no etcd and keeps volume metadata across restarts.
+type FileStore struct {
+	db     *bolt.DB
+	mu     sync.Mutex // serializes writes with event publishing so events stay in revision order
+	events *broadcaster
+	logger *slog.Logger
+}
+
+// NewFileStore opens or creates the store file at path
+func NewFileStore(path string, logger *slog.Logger) (*FileStore, error) {
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store file %s: %w", path, err)
+	}
+
+	var revision int64
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{volumesBucket, namesBucket, snapshotsBucket, snapshotNamesBucket, nodesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+		revision = currentRevision(tx)
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Events from before this process started are not retained for watches
+	events := newBroadcaster()
+	events.compacted = revision
+
+	logger.Info("opened file store", "path", path, "revision", revision)
+
+	return &FileStore{
+		db:     db,
+		events: events,
+		logger: logger.With("store", "file"),
+	}, nil
+}
+
+// CreateVolume creates a new volume
+func (s *FileStore) CreateVolume(ctx context.Context, volume *types.Volume) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		volumes := tx.Bucket(volumesBucket)
+		names := tx.Bucket(namesBucket)
+
+		// Name and ID uniqueness are checked in the same transaction as the write
+		if volumes.Get([]byte(volume.ID)) != nil || names.Get([]byte(volume.Name)) != nil {
+			return ErrAlreadyExists
+		}
+
+		revision, err := nextRevision(tx)
+		if err != nil {
+			return err
+		}
+		volume.ResourceVersion = revision
+
+		if err := putVolume(volumes, volume); err != nil {
+			return err
+		}
+		return names.Put([]byte(volume.Name), []byte(volume.ID))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(types.EventAdded, volume)
+	s.logger.Debug("volume created in file store", "volume_id", volume.ID, "name", volume.Name)
+	return nil
+}
+
+// GetVolume retrieves a volume by ID
+func (s *FileStore) GetVolume(ctx context.Context, id string) (*types.Volume, error) {
+	var volume *types.Volume
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = getVolume(tx.Bucket(volumesBucket), id)
+		return err
+	})
+	return volume, err
+}
+
+// GetVolumeByName retrieves a volume by name
+func (s *FileStore) GetVolumeByName(ctx context.Context, name string) (*types.Volume, error) {
+	var volume *types.Volume
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(namesBucket).Get([]byte(name))
+		if id == nil {
+			return ErrNotFound
+		}
+		var err error
+		volume, err = getVolume(tx.Bucket(volumesBucket), string(id))
+		return err
+	})
+	return volume, err
+}
+
+// ListVolumes lists a page of the volumes matching opts. The volumes bucket
+// is keyed by ID, so it is already in listing order.
+func (s *FileStore) ListVolumes(ctx context.Context, opts ListOptions) ([]*types.Volume, string, error) {
+	var volumes []*types.Volume
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volumes, err = s.listVolumes(tx)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return pageVolumes(volumes, opts)
+}
+
+// UpdateVolume updates an existing volume if it has not been modified since it was read
+func (s *FileStore) UpdateVolume(ctx context.Context, volume *types.Volume) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		volumes := tx.Bucket(volumesBucket)
+
+		stored, err := getVolume(volumes, volume.ID)
+		if err != nil {
+			return err
+		}
+		if stored.ResourceVersion != volume.ResourceVersion {
+			return ErrConflict
+		}
+
+		revision, err := nextRevision(tx)
+		if err != nil {
+			return err
+		}
+		volume.ResourceVersion = revision
+
+		return putVolume(volumes, volume)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(types.EventModified, volume)
+	s.logger.Debug("volume updated in file store", "volume_id", volume.ID, "revision", volume.ResourceVersion)
+	return nil
+}
+
+// DeleteVolume deletes a volume if it has not been modified since it was read
+func (s *FileStore) DeleteVolume(ctx context.Context, id string, resourceVersion int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var volume *types.Volume
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		volumes := tx.Bucket(volumesBucket)
+
+		var err error
+		volume, err = getVolume(volumes, id)
+		if err != nil {
+			return err
+		}
+		if volume.ResourceVersion != resourceVersion {
+			return ErrConflict
+		}
+
+		revision, err := nextRevision(tx)
+		if err != nil {
+			return err
+		}
+		volume.ResourceVersion = revision
+
+		if err := volumes.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(namesBucket).Delete([]byte(volume.Name))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(types.EventDeleted, volume)
+	s.logger.Debug("volume deleted from file store", "volume_id", id)
+	return nil
+}
+
+// Watch streams volume changes until ctx is cancelled
+func (s *FileStore) Watch(ctx context.Context, fromRevision int64) (<-chan types.VolumeEvent, error) {
+	// Hold the write lock so no change slips in between the snapshot and the subscription
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var initial []types.VolumeEvent
+	if fromRevision == 0 {
+		err := s.db.View(func(tx *bolt.Tx) error {
+			volumes, err := s.listVolumes(tx)
+			if err != nil {
+				return err
+			}
+			for _, volume := range volumes {
+				initial = append(initial, types.VolumeEvent{
+					Type:     types.EventAdded,
+					Revision: volume.ResourceVersion,
+					Volume:   volume,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(initial, func(i, j int) bool { return initial[i].Revision < initial[j].Revision })
+	}
+
+	return s.events.subscribe(ctx, fromRevision, initial)
+}
+
+// CreateSnapshot records a new snapshot
+func (s *FileStore) CreateSnapshot(ctx context.Context, snapshot *types.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		snapshots := tx.Bucket(snapshotsBucket)
+		names := tx.Bucket(snapshotNamesBucket)
+
+		if snapshots.Get([]byte(snapshot.ID)) != nil || names.Get([]byte(snapshot.Name)) != nil {
+			return ErrSnapshotExists
+		}
+
+		if err := snapshots.Put([]byte(snapshot.ID), data); err != nil {
+			return err
+		}
+		return names.Put([]byte(snapshot.Name), []byte(snapshot.ID))
+	})
+}
+
+// GetSnapshot retrieves a snapshot by ID
+func (s *FileStore) GetSnapshot(ctx context.Context, id string) (*types.Snapshot, error) {
+	var snapshot *types.Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		snapshot, err = getSnapshot(tx.Bucket(snapshotsBucket), id)
+		return err
+	})
+	return snapshot, err
+}
+
+// GetSnapshotByName retrieves a snapshot by name
+func (s *FileStore) GetSnapshotByName(ctx context.Context, name string) (*types.Snapshot, error) {
+	var snapshot *types.Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(snapshotNamesBucket).Get([]byte(name))
+		if id == nil {
+			return ErrSnapshotNotFound
+		}
+		var err error
+		snapshot, err = getSnapshot(tx.Bucket(snapshotsBucket), string(id))
+		return err
+	})
+	return snapshot, err
+}
+
+// ListSnapshots lists the snapshots of a volume, or all snapshots
+func (s *FileStore) ListSnapshots(ctx context.Context, sourceVolumeID string) ([]*types.Snapshot, error) {
+	snapshots := make([]*types.Snapshot, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).ForEach(func(k, v []byte) error {
+			var snapshot types.Snapshot
+			if err := json.Unmarshal(v, &snapshot); err != nil {
+				s.logger.Warn("failed to unmarshal snapshot", "snapshot_id", string(k), "error", err)
+				return nil
+			}
+			if sourceVolumeID == "" || snapshot.SourceVolumeID == sourceVolumeID {
+				snapshots = append(snapshots, &snapshot)
+			}
+			return nil
+		})
+	})
+	return snapshots, err
+}
+
+// UpdateSnapshot applies mutate to a snapshot within a single transaction
+func (s *FileStore) UpdateSnapshot(ctx context.Context, id string, mutate func(snapshot *types.Snapshot) error) (*types.Snapshot, error) {
+	var snapshot *types.Snapshot
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		snapshot, err = getSnapshot(tx.Bucket(snapshotsBucket), id)
+		if err != nil {
+			return err
+		}
+
+		if err := mutate(snapshot); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
+		return tx.Bucket(snapshotsBucket).Put([]byte(id), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// DeleteSnapshot deletes a snapshot by ID
+func (s *FileStore) DeleteSnapshot(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		snapshot, err := getSnapshot(tx.Bucket(snapshotsBucket), id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(snapshotsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(snapshotNamesBucket).Delete([]byte(snapshot.Name))
+	})
+}
+
+// PutNode records a node
+func (s *FileStore) PutNode(ctx context.Context, node *types.Node) error {
+	data, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).Put([]byte(node.ID), data)
+	})
+}
+
+// ListNodes lists the registered nodes in ID order
+func (s *FileStore) ListNodes(ctx context.Context) ([]*types.Node, error) {
+	nodes := make([]*types.Node, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).ForEach(func(k, v []byte) error {
+			var node types.Node
+			if err := json.Unmarshal(v, &node); err != nil {
+				s.logger.Warn("failed to unmarshal node", "node_id", string(k), "error", err)
+				return nil
+			}
+			nodes = append(nodes, &node)
+			return nil
+		})
+	})
+	return nodes, err
+}
+
+// Close closes the store file
+func (s *FileStore) Close() error {
+	s.logger.Info("closing file store")
+	return s.db.Close()
+}
+
+// publish sends a committed change to watchers; callers hold s.mu
+func (s *FileStore) publish(eventType types.EventType, volume *types.Volume) {
+	s.events.publish(types.VolumeEvent{
+		Type:     eventType,
+		Revision: volume.ResourceVersion,
+		Volume:   volume.DeepCopy(),
+	})
+}
+
+// listVolumes decodes every volume in the volumes bucket
+func (s *FileStore) listVolumes(tx *bolt.Tx) ([]*types.Volume, error) {
+	volumes := make([]*types.Volume, 0, tx.Bucket(volumesBucket).Stats().KeyN)
+	err := tx.Bucket(volumesBucket).ForEach(func(k, v []byte) error {
+		var volume types.Volume
+		if err := json.Unmarshal(v, &volume); err != nil {
+			s.logger.Warn("failed to unmarshal volume", "volume_id", string(k), "error", err)
+			return nil
+		}
+		volumes = append(volumes, &volume)
+		return nil
+	})
+	return volumes, err
+}
+
+// getVolume decodes a single volume from the volumes bucket
+func getVolume(bucket *bolt.Bucket, id string) (*types.Volume, error) {
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	var volume types.Volume
+	if err := json.Unmarshal(data, &volume); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal volume: %w", err)
+	}
+	return &volume, nil
+}
+
+// getSnapshot decodes a single snapshot from the snapshots bucket
+func getSnapshot(bucket *bolt.Bucket, id string) (*types.Snapshot, error) {
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return nil, ErrSnapshotNotFound
+	}
+
+	var snapshot types.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// putVolume encodes a volume into the volumes bucket
+func putVolume(bucket *bolt.Bucket, volume *types.Volume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return fmt.Errorf("failed to marshal volume: %w", err)
+	}
+	return bucket.Put([]byte(volume.ID), data)
+}
+
+// currentRevision returns the revision of the last write
+func currentRevision(tx *bolt.Tx) int64 {
+	data := tx.Bucket(metaBucket).Get(revisionKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(data))
+}
+
+// nextRevision increments and returns the store revision
+func nextRevision(tx *bolt.Tx) (int64, error) {
+	revision := currentRevision(tx) + 1
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(revision))
+	if err := tx.Bucket(metaBucket).Put(revisionKey, data); err != nil {
+		return 0, fmt.Errorf("failed to store revision: %w", err)
+	}
+	return revision, nil
+}
+
+// Snapshot writes a consistent copy of the store file to w
+func (s *FileStore) Snapshot(ctx context.Context, w io.Writer) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// RestoreFileSnapshot replaces the store file at path with a snapshot taken
+// by FileStore.Snapshot. The manager must not be running.
+func RestoreFileSnapshot(snapshotPath, path string) error {
+	// Check the snapshot really is a file store before touching anything
+	db, err := bolt.Open(snapshotPath, 0600, &bolt.Options{ReadOnly: true, Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot %s: %w", snapshotPath, err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(volumesBucket) == nil || tx.Bucket(metaBucket) == nil {
+			return fmt.Errorf("%s is not a file store snapshot", snapshotPath)
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	src, err := os.Open(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer src.Close()
+
+	// Copy next to the target and rename, so a failed copy leaves no partial store
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot copy: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := bumpRevision(tmp.Name(), restoreRevisionBump); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// bumpRevision advances the stored revision of a closed store file
+func bumpRevision(path string, amount int64) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open restored store: %w", err)
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(currentRevision(tx)+amount))
+		return tx.Bucket(metaBucket).Put(revisionKey, data)
+	})
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+func TestFileStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "volume-manager.db")
+
+	s, err := NewFileStore(path, testLogger)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	kept := createVolume(t, s, "vol-1")
+	deleted := createVolume(t, s, "vol-2")
+	if err := s.DeleteVolume(ctx, "vol-2", deleted.ResourceVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateSnapshot(ctx, &types.Snapshot{ID: "snap-1", Name: "snap-1", SourceVolumeID: "vol-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutNode(ctx, &types.Node{ID: "n1", Topology: map[string]string{"zone": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	s = newTestFileStore(t, path)
+
+	got, err := s.GetVolume(ctx, "vol-1")
+	if err != nil {
+		t.Fatalf("GetVolume() after reopen error = %v", err)
+	}
+	if got.ResourceVersion != kept.ResourceVersion {
+		t.Errorf("resource version = %d, want %d", got.ResourceVersion, kept.ResourceVersion)
+	}
+	if _, err := s.GetVolumeByName(ctx, "vol-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetVolumeByName(deleted) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetSnapshotByName(ctx, "snap-1"); err != nil {
+		t.Errorf("GetSnapshotByName() after reopen error = %v", err)
+	}
+	if nodes, err := s.ListNodes(ctx); err != nil || len(nodes) != 1 || nodes[0].Topology["zone"] != "a" {
+		t.Errorf("ListNodes() = %v, %v, want node n1 in zone a", nodes, err)
+	}
+
+	// The revision carries on past the delete, so a resource version is
+	// never handed out twice and stale writers still conflict
+	stale := kept.DeepCopy()
+	if err := s.UpdateVolume(ctx, got); err != nil {
+		t.Fatalf("UpdateVolume() error = %v", err)
+	}
+	if got.ResourceVersion <= deleted.ResourceVersion {
+		t.Errorf("resource version after reopen = %d, want more than %d", got.ResourceVersion, deleted.ResourceVersion)
+	}
+	if err := s.UpdateVolume(ctx, stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("UpdateVolume(stale) error = %v, want ErrConflict", err)
+	}
+}
+
+func TestFileStoreWatchAfterReopen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "volume-manager.db")
+
+	s, err := NewFileStore(path, testLogger)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	first := createVolume(t, s, "vol-1")
+	second := createVolume(t, s, "vol-2")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	s = newTestFileStore(t, path)
+
+	// Events from before the restart are gone, so a watcher that missed
+	// some of them has to list again
+	if _, err := s.Watch(ctx, first.ResourceVersion); !errors.Is(err, ErrCompacted) {
+		t.Errorf("Watch(%d) error = %v, want ErrCompacted", first.ResourceVersion, err)
+	}
+
+	// A watcher that saw everything resumes without missing a change
+	events, err := s.Watch(ctx, second.ResourceVersion)
+	if err != nil {
+		t.Fatalf("Watch(%d) error = %v", second.ResourceVersion, err)
+	}
+	third := createVolume(t, s, "vol-3")
+	if event := nextEvent(t, events); event.Volume.ID != "vol-3" || event.Revision != third.ResourceVersion {
+		t.Errorf("event = %s at %d, want vol-3 at %d", event.Volume.ID, event.Revision, third.ResourceVersion)
+	}
+}
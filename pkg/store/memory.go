@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sistemica/docker-volume-manager/pkg/types"
@@ -9,16 +10,25 @@ import (
 
 // MemoryStore implements an in-memory store for development
 type MemoryStore struct {
-	mu      sync.RWMutex
-	volumes map[string]*types.Volume // indexed by ID
-	names   map[string]string        // name -> ID mapping
+	mu            sync.RWMutex
+	volumes       map[string]*types.Volume   // indexed by ID
+	names         map[string]string          // name -> ID mapping
+	snapshots     map[string]*types.Snapshot // indexed by ID
+	snapshotNames map[string]string          // name -> ID mapping
+	nodes         map[string]*types.Node     // indexed by ID
+	revision      int64                      // incremented on every write, like an etcd revision
+	events        *broadcaster
 }
 
 // NewMemoryStore creates a new in-memory store
 func NewMemoryStore() Store {
 	return &MemoryStore{
-		volumes: make(map[string]*types.Volume),
-		names:   make(map[string]string),
+		volumes:       make(map[string]*types.Volume),
+		names:         make(map[string]string),
+		snapshots:     make(map[string]*types.Snapshot),
+		snapshotNames: make(map[string]string),
+		nodes:         make(map[string]*types.Node),
+		events:        newBroadcaster(),
 	}
 }
 
@@ -37,9 +47,12 @@ func (s *MemoryStore) CreateVolume(ctx context.Context, volume *types.Volume) er
 		return ErrAlreadyExists
 	}
 
-	// Store volume
-	s.volumes[volume.ID] = volume
+	// Store a copy so callers cannot modify the stored volume
+	s.revision++
+	volume.ResourceVersion = s.revision
+	s.volumes[volume.ID] = volume.DeepCopy()
 	s.names[volume.Name] = volume.ID
+	s.publish(types.EventAdded, volume)
 
 	return nil
 }
@@ -54,7 +67,7 @@ func (s *MemoryStore) GetVolume(ctx context.Context, id string) (*types.Volume,
 		return nil, ErrNotFound
 	}
 
-	return volume, nil
+	return volume.DeepCopy(), nil
 }
 
 // GetVolumeByName retrieves a volume by name
@@ -72,11 +85,11 @@ func (s *MemoryStore) GetVolumeByName(ctx context.Context, name string) (*types.
 		return nil, ErrNotFound
 	}
 
-	return volume, nil
+	return volume.DeepCopy(), nil
 }
 
-// ListVolumes lists all volumes
-func (s *MemoryStore) ListVolumes(ctx context.Context) ([]*types.Volume, error) {
+// ListVolumes lists a page of the volumes matching opts
+func (s *MemoryStore) ListVolumes(ctx context.Context, opts ListOptions) ([]*types.Volume, string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -84,8 +97,19 @@ func (s *MemoryStore) ListVolumes(ctx context.Context) ([]*types.Volume, error)
 	for _, volume := range s.volumes {
 		volumes = append(volumes, volume)
 	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].ID < volumes[j].ID
+	})
 
-	return volumes, nil
+	page, next, err := pageVolumes(volumes, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, volume := range page {
+		page[i] = volume.DeepCopy()
+	}
+
+	return page, next, nil
 }
 
 // UpdateVolume updates an existing volume
@@ -93,16 +117,25 @@ func (s *MemoryStore) UpdateVolume(ctx context.Context, volume *types.Volume) er
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.volumes[volume.ID]; !exists {
+	stored, exists := s.volumes[volume.ID]
+	if !exists {
 		return ErrNotFound
 	}
 
-	s.volumes[volume.ID] = volume
+	if stored.ResourceVersion != volume.ResourceVersion {
+		return ErrConflict
+	}
+
+	s.revision++
+	volume.ResourceVersion = s.revision
+	s.volumes[volume.ID] = volume.DeepCopy()
+	s.publish(types.EventModified, volume)
+
 	return nil
 }
 
-// DeleteVolume deletes a volume by ID
-func (s *MemoryStore) DeleteVolume(ctx context.Context, id string) error {
+// DeleteVolume deletes a volume if it has not been modified since it was read
+func (s *MemoryStore) DeleteVolume(ctx context.Context, id string, resourceVersion int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -111,12 +144,166 @@ func (s *MemoryStore) DeleteVolume(ctx context.Context, id string) error {
 		return ErrNotFound
 	}
 
+	if volume.ResourceVersion != resourceVersion {
+		return ErrConflict
+	}
+
+	s.revision++
 	delete(s.volumes, id)
 	delete(s.names, volume.Name)
+	volume.ResourceVersion = s.revision
+	s.publish(types.EventDeleted, volume)
+
+	return nil
+}
+
+// Watch streams volume changes until ctx is cancelled
+func (s *MemoryStore) Watch(ctx context.Context, fromRevision int64) (<-chan types.VolumeEvent, error) {
+	// Hold the write lock so no change slips in between the snapshot and the subscription
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var initial []types.VolumeEvent
+	if fromRevision == 0 {
+		for _, volume := range s.volumes {
+			initial = append(initial, types.VolumeEvent{
+				Type:     types.EventAdded,
+				Revision: volume.ResourceVersion,
+				Volume:   volume.DeepCopy(),
+			})
+		}
+		sort.Slice(initial, func(i, j int) bool { return initial[i].Revision < initial[j].Revision })
+	}
+
+	return s.events.subscribe(ctx, fromRevision, initial)
+}
+
+// publish sends a change at the current revision to watchers; callers hold s.mu
+func (s *MemoryStore) publish(eventType types.EventType, volume *types.Volume) {
+	s.events.publish(types.VolumeEvent{
+		Type:     eventType,
+		Revision: s.revision,
+		Volume:   volume.DeepCopy(),
+	})
+}
+
+// CreateSnapshot records a new snapshot
+func (s *MemoryStore) CreateSnapshot(ctx context.Context, snapshot *types.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.snapshots[snapshot.ID]; exists {
+		return ErrSnapshotExists
+	}
+	if _, exists := s.snapshotNames[snapshot.Name]; exists {
+		return ErrSnapshotExists
+	}
+
+	s.snapshots[snapshot.ID] = snapshot.DeepCopy()
+	s.snapshotNames[snapshot.Name] = snapshot.ID
+
+	return nil
+}
+
+// GetSnapshot retrieves a snapshot by ID
+func (s *MemoryStore) GetSnapshot(ctx context.Context, id string) (*types.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, exists := s.snapshots[id]
+	if !exists {
+		return nil, ErrSnapshotNotFound
+	}
+
+	return snapshot.DeepCopy(), nil
+}
+
+// GetSnapshotByName retrieves a snapshot by name
+func (s *MemoryStore) GetSnapshotByName(ctx context.Context, name string) (*types.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.snapshotNames[name]
+	if !exists {
+		return nil, ErrSnapshotNotFound
+	}
+
+	return s.snapshots[id].DeepCopy(), nil
+}
+
+// ListSnapshots lists the snapshots of a volume, or all snapshots
+func (s *MemoryStore) ListSnapshots(ctx context.Context, sourceVolumeID string) ([]*types.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshots := make([]*types.Snapshot, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		if sourceVolumeID == "" || snapshot.SourceVolumeID == sourceVolumeID {
+			snapshots = append(snapshots, snapshot.DeepCopy())
+		}
+	}
+
+	return snapshots, nil
+}
+
+// UpdateSnapshot applies mutate to a snapshot under the store lock
+func (s *MemoryStore) UpdateSnapshot(ctx context.Context, id string, mutate func(snapshot *types.Snapshot) error) (*types.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.snapshots[id]
+	if !exists {
+		return nil, ErrSnapshotNotFound
+	}
+
+	snapshot := stored.DeepCopy()
+	if err := mutate(snapshot); err != nil {
+		return nil, err
+	}
+
+	s.snapshots[id] = snapshot.DeepCopy()
+	return snapshot, nil
+}
+
+// DeleteSnapshot deletes a snapshot by ID
+func (s *MemoryStore) DeleteSnapshot(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, exists := s.snapshots[id]
+	if !exists {
+		return ErrSnapshotNotFound
+	}
+
+	delete(s.snapshots, id)
+	delete(s.snapshotNames, snapshot.Name)
 
 	return nil
 }
 
+// PutNode records a node
+func (s *MemoryStore) PutNode(ctx context.Context, node *types.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nodes[node.ID] = node.DeepCopy()
+	return nil
+}
+
+// ListNodes lists the registered nodes in ID order
+func (s *MemoryStore) ListNodes(ctx context.Context) ([]*types.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodes := make([]*types.Node, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node.DeepCopy())
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	return nodes, nil
+}
+
 // Close closes the store
 func (s *MemoryStore) Close() error {
 	// Nothing to close for memory store
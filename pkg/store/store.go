@@ -2,7 +2,12 @@ package store
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
 
 	"github.com/sistemica/docker-volume-manager/pkg/types"
 )
@@ -13,6 +18,34 @@ var (
 
 	// ErrAlreadyExists is returned when a volume already exists
 	ErrAlreadyExists = errors.New("volume already exists")
+
+	// ErrConflict is returned when a volume was modified since it was read
+	ErrConflict = errors.New("volume was modified concurrently")
+
+	// ErrSnapshotNotFound is returned when a snapshot is not found
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+
+	// ErrSnapshotExists is returned when a snapshot with the same name already exists
+	ErrSnapshotExists = errors.New("snapshot already exists")
+
+	// ErrCompacted is returned when a watch starts from a revision that is no longer retained
+	ErrCompacted = errors.New("revision has been compacted")
+
+	// ErrInvalidContinue is returned when a listing continues from a token the store did not issue
+	ErrInvalidContinue = errors.New("invalid continue token")
+)
+
+const (
+	// restoreRevisionBump moves the revision of a restored store well past
+	// anything clients saw before, so resumed watches get ErrCompacted instead
+	// of silently replaying different history under reused revisions
+	restoreRevisionBump = 1_000_000
+
+	// maxUpdateRetries bounds how often UpdateVolumeWithRetry re-reads a volume after a conflict
+	maxUpdateRetries = 10
+
+	// retryBackoff is the base delay between conflicting update attempts
+	retryBackoff = 10 * time.Millisecond
 )
 
 // Store defines the interface for metadata storage
@@ -26,15 +59,187 @@ type Store interface {
 	// GetVolumeByName retrieves a volume by name
 	GetVolumeByName(ctx context.Context, name string) (*types.Volume, error)
 
-	// ListVolumes lists all volumes
-	ListVolumes(ctx context.Context) ([]*types.Volume, error)
+	// ListVolumes lists the volumes matching opts.Filter in ID order. With a
+	// positive opts.Limit it returns at most that many, and a continue token
+	// for the next page if more volumes match; the token is empty on the
+	// last page. Volumes created or deleted between pages may or may not be
+	// listed.
+	ListVolumes(ctx context.Context, opts ListOptions) ([]*types.Volume, string, error)
 
-	// UpdateVolume updates an existing volume
+	// UpdateVolume updates an existing volume if its ResourceVersion still
+	// matches the stored one, returning ErrConflict otherwise. On success the
+	// volume's ResourceVersion is set to the new revision.
 	UpdateVolume(ctx context.Context, volume *types.Volume) error
 
-	// DeleteVolume deletes a volume by ID
-	DeleteVolume(ctx context.Context, id string) error
+	// DeleteVolume deletes a volume if its ResourceVersion still matches
+	// resourceVersion, returning ErrConflict otherwise
+	DeleteVolume(ctx context.Context, id string, resourceVersion int64) error
+
+	// Watch streams volume changes until ctx is cancelled. With fromRevision
+	// 0 it first sends every existing volume as an ADDED event, in revision
+	// order so the stream can be resumed from any of them; otherwise it
+	// sends the changes made after fromRevision, or returns ErrCompacted if
+	// they are no longer retained. The channel is closed when the watch ends.
+	Watch(ctx context.Context, fromRevision int64) (<-chan types.VolumeEvent, error)
+
+	// CreateSnapshot records a new snapshot; names are unique across all volumes
+	CreateSnapshot(ctx context.Context, snapshot *types.Snapshot) error
+
+	// GetSnapshot retrieves a snapshot by ID
+	GetSnapshot(ctx context.Context, id string) (*types.Snapshot, error)
+
+	// GetSnapshotByName retrieves a snapshot by name
+	GetSnapshotByName(ctx context.Context, name string) (*types.Snapshot, error)
+
+	// ListSnapshots lists the snapshots of a volume, or all snapshots if
+	// sourceVolumeID is empty
+	ListSnapshots(ctx context.Context, sourceVolumeID string) ([]*types.Snapshot, error)
+
+	// UpdateSnapshot reads a snapshot, applies mutate and writes it back
+	// atomically, returning the updated snapshot. An error returned by mutate
+	// aborts the update and is returned as is. The name must not change.
+	UpdateSnapshot(ctx context.Context, id string, mutate func(snapshot *types.Snapshot) error) (*types.Snapshot, error)
+
+	// DeleteSnapshot deletes a snapshot by ID
+	DeleteSnapshot(ctx context.Context, id string) error
+
+	// PutNode records a node, replacing an earlier record with the same ID
+	PutNode(ctx context.Context, node *types.Node) error
+
+	// ListNodes lists the registered nodes in ID order
+	ListNodes(ctx context.Context) ([]*types.Node, error)
 
 	// Close closes the store
 	Close() error
 }
+
+// ListOptions selects a page of a volume listing
+type ListOptions struct {
+	Filter   types.VolumeFilter
+	Limit    int    // Maximum number of volumes; 0 lists all
+	Continue string // Token of the previous page; empty starts at the first volume
+}
+
+// encodeContinue returns the continue token of a page that ended with the
+// volume with the given ID
+func encodeContinue(lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastID))
+}
+
+// decodeContinue returns the ID of the volume a continue token points at, or
+// an empty ID for an empty token
+func decodeContinue(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	lastID, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(lastID) == 0 {
+		return "", ErrInvalidContinue
+	}
+	return string(lastID), nil
+}
+
+// pageVolumes returns the page of volumes, sorted by ID, that matches opts
+// and the continue token for the page after it
+func pageVolumes(volumes []*types.Volume, opts ListOptions) ([]*types.Volume, string, error) {
+	lastID, err := decodeContinue(opts.Continue)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page := make([]*types.Volume, 0)
+	for _, volume := range volumes {
+		if volume.ID <= lastID || !opts.Filter.Matches(volume) {
+			continue
+		}
+		if opts.Limit > 0 && len(page) == opts.Limit {
+			// Another volume matches, so there is a next page
+			return page, encodeContinue(page[len(page)-1].ID), nil
+		}
+		page = append(page, volume)
+	}
+
+	return page, "", nil
+}
+
+// ClusterStore is implemented by stores that replicate across several members
+type ClusterStore interface {
+	// Members lists the cluster members with their current health
+	Members(ctx context.Context) ([]types.ClusterMember, error)
+}
+
+// SnapshotStore is implemented by stores that can write a consistent
+// point-in-time copy of their data
+type SnapshotStore interface {
+	// Snapshot writes a snapshot to w in the store's native format, which
+	// the matching Restore*Snapshot function turns back into a data directory
+	Snapshot(ctx context.Context, w io.Writer) error
+}
+
+// DeleteVolumeWithRetry reads a volume and deletes it if check accepts it,
+// starting over from a fresh read when the volume was modified concurrently,
+// so check always sees the state that is deleted. An error returned by check
+// aborts the delete and is returned as is. It returns the deleted volume.
+func DeleteVolumeWithRetry(ctx context.Context, s Store, id string, check func(volume *types.Volume) error) (*types.Volume, error) {
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		volume, err := s.GetVolume(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := check(volume); err != nil {
+			return nil, err
+		}
+
+		err = s.DeleteVolume(ctx, id, volume.ResourceVersion)
+		if err == nil {
+			return volume, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+
+		backoff := time.Duration(rand.Int63n(int64(retryBackoff) * int64(attempt+1)))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+
+	return nil, fmt.Errorf("%w: gave up after %d attempts", ErrConflict, maxUpdateRetries)
+}
+
+// UpdateVolumeWithRetry reads a volume, applies mutate and writes it back,
+// starting over from a fresh read when the write conflicts with a concurrent
+// update. An error returned by mutate aborts the update and is returned as is.
+func UpdateVolumeWithRetry(ctx context.Context, s Store, id string, mutate func(volume *types.Volume) error) (*types.Volume, error) {
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		volume, err := s.GetVolume(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mutate(volume); err != nil {
+			return nil, err
+		}
+
+		err = s.UpdateVolume(ctx, volume)
+		if err == nil {
+			return volume, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+
+		// Back off with jitter so concurrent writers spread out
+		backoff := time.Duration(rand.Int63n(int64(retryBackoff) * int64(attempt+1)))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+
+	return nil, fmt.Errorf("%w: gave up after %d attempts", ErrConflict, maxUpdateRetries)
+}
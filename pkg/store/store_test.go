@@ -0,0 +1,530 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sistemica/docker-volume-manager/pkg/state"
+	"github.com/sistemica/docker-volume-manager/pkg/types"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// freePort returns a TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// newTestFileStore opens a file store at path, closed when the test ends
+func newTestFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(path, testLogger)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newTestEtcdStore starts a single-member embedded etcd, stopped when the
+// test ends
+func newTestEtcdStore(t *testing.T) *EtcdStore {
+	t.Helper()
+	s, err := NewEtcdStore(EtcdConfig{
+		DataDir:     t.TempDir(),
+		Name:        "test",
+		ClusterSize: 1,
+		ServiceName: "test",
+		TaskSlot:    1,
+		ClientPort:  freePort(t),
+		PeerPort:    freePort(t),
+	}, testLogger)
+	if err != nil {
+		t.Fatalf("NewEtcdStore() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testStores returns an empty store of every kind. The embedded etcd takes a
+// moment to start, so it is left out of short runs.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   newTestFileStore(t, filepath.Join(t.TempDir(), "volume-manager.db")),
+	}
+	if !testing.Short() {
+		stores["etcd"] = newTestEtcdStore(t)
+	}
+	return stores
+}
+
+// createVolume creates a volume named like its ID
+func createVolume(t *testing.T, s Store, id string) *types.Volume {
+	t.Helper()
+	volume := &types.Volume{ID: id, Name: id, Backend: "local", Status: types.VolumeStatusCreated}
+	if err := s.CreateVolume(context.Background(), volume); err != nil {
+		t.Fatalf("CreateVolume(%s) error = %v", id, err)
+	}
+	return volume
+}
+
+func TestUpdateVolumeConflict(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			volume := createVolume(t, s, "vol-1")
+			stale := volume.DeepCopy()
+
+			volume.Generation = 1
+			if err := s.UpdateVolume(ctx, volume); err != nil {
+				t.Fatalf("UpdateVolume() error = %v", err)
+			}
+			if volume.ResourceVersion <= stale.ResourceVersion {
+				t.Errorf("resource version = %d, want more than %d", volume.ResourceVersion, stale.ResourceVersion)
+			}
+
+			stale.Generation = 2
+			if err := s.UpdateVolume(ctx, stale); !errors.Is(err, ErrConflict) {
+				t.Errorf("UpdateVolume(stale) error = %v, want ErrConflict", err)
+			}
+
+			got, err := s.GetVolume(ctx, "vol-1")
+			if err != nil {
+				t.Fatalf("GetVolume() error = %v", err)
+			}
+			if got.Generation != 1 || got.ResourceVersion != volume.ResourceVersion {
+				t.Errorf("volume = generation %d at %d, want generation 1 at %d", got.Generation, got.ResourceVersion, volume.ResourceVersion)
+			}
+
+			missing := &types.Volume{ID: "missing", Name: "missing"}
+			if err := s.UpdateVolume(ctx, missing); !errors.Is(err, ErrNotFound) {
+				t.Errorf("UpdateVolume(missing) error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDeleteVolumeConflict(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			volume := createVolume(t, s, "vol-1")
+			stale := volume.ResourceVersion
+
+			if err := s.UpdateVolume(ctx, volume); err != nil {
+				t.Fatalf("UpdateVolume() error = %v", err)
+			}
+
+			if err := s.DeleteVolume(ctx, "vol-1", stale); !errors.Is(err, ErrConflict) {
+				t.Fatalf("DeleteVolume(stale) error = %v, want ErrConflict", err)
+			}
+			if _, err := s.GetVolume(ctx, "vol-1"); err != nil {
+				t.Fatalf("GetVolume() after a conflicting delete error = %v", err)
+			}
+
+			if err := s.DeleteVolume(ctx, "vol-1", volume.ResourceVersion); err != nil {
+				t.Fatalf("DeleteVolume() error = %v", err)
+			}
+			if _, err := s.GetVolume(ctx, "vol-1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetVolume() after delete error = %v, want ErrNotFound", err)
+			}
+			if _, err := s.GetVolumeByName(ctx, "vol-1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetVolumeByName() after delete error = %v, want ErrNotFound", err)
+			}
+			if err := s.DeleteVolume(ctx, "vol-1", volume.ResourceVersion); !errors.Is(err, ErrNotFound) {
+				t.Errorf("DeleteVolume() again error = %v, want ErrNotFound", err)
+			}
+
+			// The name is free for a new volume
+			createVolume(t, s, "vol-1")
+		})
+	}
+}
+
+func TestUpdateVolumeWithRetry(t *testing.T) {
+	ctx := context.Background()
+	const writers = 8
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			createVolume(t, s, "vol-1")
+
+			// Every node stages the volume at once; none of the writes may be lost
+			var wg sync.WaitGroup
+			errs := make(chan error, writers)
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(nodeID string) {
+					defer wg.Done()
+					_, err := UpdateVolumeWithRetry(ctx, s, "vol-1", func(volume *types.Volume) error {
+						return state.Stage(volume, nodeID, "/staging", 0, volume.UpdatedAt)
+					})
+					errs <- err
+				}(string(rune('a' + i)))
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					t.Errorf("UpdateVolumeWithRetry() error = %v", err)
+				}
+			}
+
+			got, err := s.GetVolume(ctx, "vol-1")
+			if err != nil {
+				t.Fatalf("GetVolume() error = %v", err)
+			}
+			if len(got.Attachments) != writers {
+				t.Errorf("attachments = %d, want %d", len(got.Attachments), writers)
+			}
+		})
+	}
+}
+
+func TestUpdateVolumeWithRetryMutateError(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	volume := createVolume(t, s, "vol-1")
+
+	errAbort := errors.New("abort")
+	if _, err := UpdateVolumeWithRetry(ctx, s, "vol-1", func(*types.Volume) error { return errAbort }); !errors.Is(err, errAbort) {
+		t.Errorf("UpdateVolumeWithRetry() error = %v, want the mutate error", err)
+	}
+
+	got, err := s.GetVolume(ctx, "vol-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ResourceVersion != volume.ResourceVersion {
+		t.Errorf("resource version = %d, want %d: an aborted update must not write", got.ResourceVersion, volume.ResourceVersion)
+	}
+
+	if _, err := UpdateVolumeWithRetry(ctx, s, "missing", func(*types.Volume) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateVolumeWithRetry(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteVolumeWithRetryChecksAgain(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			createVolume(t, s, "vol-1")
+			if _, err := UpdateVolumeWithRetry(ctx, s, "vol-1", func(volume *types.Volume) error {
+				return state.Stage(volume, "n1", "/staging", 0, volume.UpdatedAt)
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			// A node publishes the volume after the first check passed, so
+			// the delete conflicts and the second check sees the publish
+			checks := 0
+			_, err := DeleteVolumeWithRetry(ctx, s, "vol-1", func(volume *types.Volume) error {
+				checks++
+				if err := state.CheckDeletable(volume); err != nil {
+					return err
+				}
+				_, err := UpdateVolumeWithRetry(ctx, s, "vol-1", func(volume *types.Volume) error {
+					return state.Publish(volume, "n1", "/target", false, volume.UpdatedAt)
+				})
+				return err
+			})
+
+			var transitionErr *state.TransitionError
+			if !errors.As(err, &transitionErr) || transitionErr.Code != state.CodeVolumeInUse {
+				t.Fatalf("DeleteVolumeWithRetry() error = %v, want %s", err, state.CodeVolumeInUse)
+			}
+			if checks != 2 {
+				t.Errorf("checks = %d, want 2", checks)
+			}
+			if _, err := s.GetVolume(ctx, "vol-1"); err != nil {
+				t.Errorf("GetVolume() error = %v, want the published volume kept", err)
+			}
+		})
+	}
+}
+
+// nextEvent receives one event, failing the test if none arrives in time
+func nextEvent(t *testing.T, events <-chan types.VolumeEvent) types.VolumeEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a watch event")
+	}
+	return types.VolumeEvent{}
+}
+
+func TestWatch(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			first := createVolume(t, s, "vol-1")
+			second := createVolume(t, s, "vol-2")
+
+			// Watching from 0 sends the current state in revision order
+			events, err := s.Watch(ctx, 0)
+			if err != nil {
+				t.Fatalf("Watch(0) error = %v", err)
+			}
+			for _, want := range []*types.Volume{first, second} {
+				event := nextEvent(t, events)
+				if event.Type != types.EventAdded || event.Volume.ID != want.ID || event.Revision != want.ResourceVersion {
+					t.Errorf("event = %s %s at %d, want ADDED %s at %d", event.Type, event.Volume.ID, event.Revision, want.ID, want.ResourceVersion)
+				}
+			}
+
+			if err := s.UpdateVolume(ctx, first); err != nil {
+				t.Fatalf("UpdateVolume() error = %v", err)
+			}
+			if err := s.DeleteVolume(ctx, "vol-2", second.ResourceVersion); err != nil {
+				t.Fatalf("DeleteVolume() error = %v", err)
+			}
+
+			modified := nextEvent(t, events)
+			if modified.Type != types.EventModified || modified.Volume.ID != "vol-1" || modified.Revision != first.ResourceVersion {
+				t.Errorf("event = %s %s at %d, want MODIFIED vol-1 at %d", modified.Type, modified.Volume.ID, modified.Revision, first.ResourceVersion)
+			}
+			deleted := nextEvent(t, events)
+			if deleted.Type != types.EventDeleted || deleted.Volume.ID != "vol-2" || deleted.Revision <= modified.Revision {
+				t.Errorf("event = %s %s at %d, want DELETED vol-2 after %d", deleted.Type, deleted.Volume.ID, deleted.Revision, modified.Revision)
+			}
+
+			// Resuming from the last event seen before the update replays
+			// only what came after it
+			resumed, err := s.Watch(ctx, second.ResourceVersion)
+			if err != nil {
+				t.Fatalf("Watch(%d) error = %v", second.ResourceVersion, err)
+			}
+			for _, want := range []types.VolumeEvent{modified, deleted} {
+				event := nextEvent(t, resumed)
+				if event.Type != want.Type || event.Volume.ID != want.Volume.ID || event.Revision != want.Revision {
+					t.Errorf("resumed event = %s %s at %d, want %s %s at %d", event.Type, event.Volume.ID, event.Revision, want.Type, want.Volume.ID, want.Revision)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchStopsOnCancel(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			events, err := s.Watch(ctx, 0)
+			if err != nil {
+				t.Fatalf("Watch() error = %v", err)
+			}
+			cancel()
+
+			select {
+			case _, ok := <-events:
+				if ok {
+					t.Error("received an event, want the watch closed")
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("watch not closed after cancel")
+			}
+		})
+	}
+}
+
+func TestEtcdWatchCompacted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts an embedded etcd")
+	}
+	ctx := context.Background()
+	s := newTestEtcdStore(t)
+
+	volume := createVolume(t, s, "vol-1")
+	from := volume.ResourceVersion
+	if err := s.UpdateVolume(ctx, volume); err != nil {
+		t.Fatal(err)
+	}
+	last := volume.ResourceVersion
+	if err := s.UpdateVolume(ctx, volume); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.client.Compact(ctx, volume.ResourceVersion); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	// The update after from is gone
+	if _, err := s.Watch(ctx, from); !errors.Is(err, ErrCompacted) {
+		t.Errorf("Watch(%d) error = %v, want ErrCompacted", from, err)
+	}
+
+	// The update after last is what compaction kept
+	events, err := s.Watch(ctx, last)
+	if err != nil {
+		t.Fatalf("Watch(%d) error = %v", last, err)
+	}
+	if event := nextEvent(t, events); event.Revision != volume.ResourceVersion {
+		t.Errorf("event revision = %d, want %d", event.Revision, volume.ResourceVersion)
+	}
+}
+
+// listAll follows continue tokens to the end of a listing, returning the IDs
+// of every page
+func listAll(t *testing.T, s Store, opts ListOptions) [][]string {
+	t.Helper()
+	var pages [][]string
+	for {
+		volumes, next, err := s.ListVolumes(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("ListVolumes(%+v) error = %v", opts, err)
+		}
+		ids := make([]string, 0, len(volumes))
+		for _, volume := range volumes {
+			ids = append(ids, volume.ID)
+		}
+		pages = append(pages, ids)
+		if next == "" {
+			return pages
+		}
+		if len(pages) > 20 {
+			t.Fatal("listing does not end")
+		}
+		opts.Continue = next
+	}
+}
+
+func TestListVolumesPages(t *testing.T) {
+	tests := map[string]struct {
+		opts      ListOptions
+		wantPages [][]string
+	}{
+		"all": {
+			wantPages: [][]string{{"vol-0", "vol-1", "vol-2", "vol-3", "vol-4", "vol-5", "vol-6"}},
+		},
+		"limit": {
+			opts:      ListOptions{Limit: 3},
+			wantPages: [][]string{{"vol-0", "vol-1", "vol-2"}, {"vol-3", "vol-4", "vol-5"}, {"vol-6"}},
+		},
+		"limit that divides the listing": {
+			opts:      ListOptions{Limit: 7},
+			wantPages: [][]string{{"vol-0", "vol-1", "vol-2", "vol-3", "vol-4", "vol-5", "vol-6"}},
+		},
+		"backend": {
+			opts:      ListOptions{Filter: types.VolumeFilter{Backend: "nfs"}, Limit: 2},
+			wantPages: [][]string{{"vol-1", "vol-4"}},
+		},
+		"label value with gaps between matches": {
+			opts:      ListOptions{Filter: types.VolumeFilter{Labels: map[string]string{"tier": "gold"}}, Limit: 1},
+			wantPages: [][]string{{"vol-0"}, {"vol-3"}, {"vol-6"}},
+		},
+		"label presence": {
+			opts:      ListOptions{Filter: types.VolumeFilter{Labels: map[string]string{"tier": ""}}, Limit: 4},
+			wantPages: [][]string{{"vol-0", "vol-1", "vol-3", "vol-4"}, {"vol-6"}},
+		},
+		"backend and label": {
+			opts:      ListOptions{Filter: types.VolumeFilter{Backend: "local", Labels: map[string]string{"tier": "gold"}}, Limit: 1},
+			wantPages: [][]string{{"vol-0"}, {"vol-3"}, {"vol-6"}},
+		},
+		"no match": {
+			opts:      ListOptions{Filter: types.VolumeFilter{Backend: "s3"}, Limit: 2},
+			wantPages: [][]string{{}},
+		},
+	}
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Created out of ID order; listings are by ID regardless
+			for _, i := range []int{3, 0, 6, 1, 5, 2, 4} {
+				volume := &types.Volume{
+					ID:      fmt.Sprintf("vol-%d", i),
+					Name:    fmt.Sprintf("name-%d", i),
+					Backend: "local",
+					Status:  types.VolumeStatusCreated,
+				}
+				if i%3 == 1 {
+					volume.Backend = "nfs"
+				}
+				switch i % 3 {
+				case 0:
+					volume.Labels = map[string]string{"tier": "gold"}
+				case 1:
+					volume.Labels = map[string]string{"tier": "silver"}
+				}
+				if err := s.CreateVolume(context.Background(), volume); err != nil {
+					t.Fatalf("CreateVolume() error = %v", err)
+				}
+			}
+
+			for name, tt := range tests {
+				t.Run(name, func(t *testing.T) {
+					if got := listAll(t, s, tt.opts); !reflect.DeepEqual(got, tt.wantPages) {
+						t.Errorf("pages = %v, want %v", got, tt.wantPages)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestListVolumesContinueAfterDelete(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"vol-0", "vol-1", "vol-2", "vol-3"} {
+				createVolume(t, s, id)
+			}
+
+			_, next, err := s.ListVolumes(ctx, ListOptions{Limit: 2})
+			if err != nil {
+				t.Fatalf("ListVolumes() error = %v", err)
+			}
+
+			// The volume the token points at is gone; the listing carries on after it
+			volume, err := s.GetVolume(ctx, "vol-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.DeleteVolume(ctx, "vol-1", volume.ResourceVersion); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := listAll(t, s, ListOptions{Limit: 2, Continue: next}); !reflect.DeepEqual(got, [][]string{{"vol-2", "vol-3"}}) {
+				t.Errorf("pages = %v, want [[vol-2 vol-3]]", got)
+			}
+		})
+	}
+}
+
+func TestListVolumesInvalidContinue(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, token := range []string{"not a token!", "="} {
+				if _, _, err := s.ListVolumes(context.Background(), ListOptions{Continue: token}); !errors.Is(err, ErrInvalidContinue) {
+					t.Errorf("ListVolumes(continue %q) error = %v, want ErrInvalidContinue", token, err)
+				}
+			}
+		})
+	}
+}
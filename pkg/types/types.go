@@ -1,6 +1,10 @@
 package types
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 // VolumeStatus represents the current state of a volume
 type VolumeStatus string
@@ -13,24 +17,324 @@ const (
 	VolumeStatusFailed    VolumeStatus = "failed"
 )
 
+// AttachmentState represents the state of a volume on a single node
+type AttachmentState string
+
+const (
+	AttachmentStateStaged    AttachmentState = "staged"
+	AttachmentStatePublished AttachmentState = "published"
+	AttachmentStateFailed    AttachmentState = "failed"
+)
+
+// AccessMode describes how many nodes may publish a volume and whether they
+// may write to it
+type AccessMode string
+
+const (
+	AccessModeSingleNodeWriter      AccessMode = "single-node-writer"       // Published on one node at a time
+	AccessModeSingleNodeMultiWriter AccessMode = "single-node-multi-writer" // Published on one node at a time, by any number of workloads
+	AccessModeSingleNodeReaderOnly  AccessMode = "single-node-reader-only"  // Published read-only on one node at a time
+	AccessModeMultiNodeReaderOnly   AccessMode = "multi-node-reader-only"   // Published read-only on any number of nodes
+	AccessModeMultiNodeMultiWriter  AccessMode = "multi-node-multi-writer"  // Published read-write on any number of nodes
+)
+
+// SingleNode reports whether the volume may only be published on one node
+// at a time
+func (m AccessMode) SingleNode() bool {
+	return m == AccessModeSingleNodeWriter || m == AccessModeSingleNodeMultiWriter || m == AccessModeSingleNodeReaderOnly
+}
+
+// ReadOnly reports whether the volume may only be published read-only
+func (m AccessMode) ReadOnly() bool {
+	return m == AccessModeSingleNodeReaderOnly || m == AccessModeMultiNodeReaderOnly
+}
+
+// VolumeCondition is the health of a volume as seen by a node
+type VolumeCondition struct {
+	Abnormal bool   `json:"abnormal"`
+	Message  string `json:"message,omitempty"`
+}
+
+// NodeAttachment tracks the state of a volume on a single node
+type NodeAttachment struct {
+	State       AttachmentState  `json:"state"`
+	StagingPath string           `json:"staging_path,omitempty"`
+	TargetPaths []string         `json:"target_paths,omitempty"`
+	LastError   string           `json:"last_error,omitempty"`
+	Generation  int64            `json:"generation,omitempty"` // Volume generation of the content staged on the node
+	Condition   *VolumeCondition `json:"condition,omitempty"`  // Last abnormal health check on the node; nil when healthy
+	Usage       *VolumeUsage     `json:"usage,omitempty"`      // Last usage of a node-local volume measured on the node; nil when unknown
+	StagedAt    *time.Time       `json:"staged_at,omitempty"`
+	PublishedAt *time.Time       `json:"published_at,omitempty"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
 // Volume represents a storage volume
 type Volume struct {
-	ID         string                 `json:"id"`
-	Name       string                 `json:"name"`
-	Backend    string                 `json:"backend"`
-	Parameters map[string]string      `json:"parameters"`
-	Status     VolumeStatus           `json:"status"`
-	StagedOn   []string               `json:"staged_on,omitempty"`   // Node IDs where volume is staged
-	PublishedOn []string              `json:"published_on,omitempty"` // Node IDs where volume is published
-	CreatedAt  time.Time              `json:"created_at"`
-	UpdatedAt  time.Time              `json:"updated_at"`
+	ID              string                     `json:"id"`
+	ResourceVersion int64                      `json:"resource_version"` // Store revision of the last write, used for compare-and-swap updates
+	Name            string                     `json:"name"`
+	Backend         string                     `json:"backend"`
+	Parameters      map[string]string          `json:"parameters"`
+	CapacityBytes   int64                      `json:"capacity_bytes,omitempty"` // Size limit, 0 means unlimited
+	Status          VolumeStatus               `json:"status"`
+	Attachments     map[string]*NodeAttachment `json:"attachments,omitempty"` // Node ID -> attachment
+	Source          *VolumeSource              `json:"source,omitempty"`      // What the volume was populated from
+	Generation      int64                      `json:"generation,omitempty"`  // Bumped by a refresh; nodes bring their staged content up to it
+	Node            string                     `json:"node,omitempty"`        // Node the data of a node-local volume lives on
+	AccessMode      AccessMode                 `json:"access_mode,omitempty"` // Set on create, to the backend's default if omitted
+	Labels          map[string]string          `json:"labels,omitempty"`      // Free-form metadata to select volumes by
+	CreatedAt       time.Time                  `json:"created_at"`
+	UpdatedAt       time.Time                  `json:"updated_at"`
+}
+
+// VolumeSource names the volume or snapshot a new volume is populated from.
+// Exactly one of the fields is set.
+type VolumeSource struct {
+	VolumeID   string `json:"volume_id,omitempty"`
+	SnapshotID string `json:"snapshot_id,omitempty"`
+}
+
+// DeepCopy returns a copy of the volume that shares no mutable state with it
+func (v *Volume) DeepCopy() *Volume {
+	out := *v
+
+	if v.Parameters != nil {
+		out.Parameters = make(map[string]string, len(v.Parameters))
+		for k, val := range v.Parameters {
+			out.Parameters[k] = val
+		}
+	}
+
+	if v.Labels != nil {
+		out.Labels = make(map[string]string, len(v.Labels))
+		for k, val := range v.Labels {
+			out.Labels[k] = val
+		}
+	}
+
+	if v.Source != nil {
+		source := *v.Source
+		out.Source = &source
+	}
+
+	if v.Attachments != nil {
+		out.Attachments = make(map[string]*NodeAttachment, len(v.Attachments))
+		for nodeID, attachment := range v.Attachments {
+			a := *attachment
+			a.TargetPaths = append([]string(nil), attachment.TargetPaths...)
+			if attachment.Condition != nil {
+				condition := *attachment.Condition
+				a.Condition = &condition
+			}
+			out.Attachments[nodeID] = &a
+		}
+	}
+
+	return &out
+}
+
+// NodesInState returns the IDs of the nodes where the volume is in the given state
+func (v *Volume) NodesInState(state AttachmentState) []string {
+	var nodes []string
+	for nodeID, attachment := range v.Attachments {
+		if attachment.State == state {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Condition summarizes the health of the volume on all nodes. It is abnormal
+// if a node reported an error for the volume or an abnormal health check.
+func (v *Volume) Condition() VolumeCondition {
+	nodeIDs := make([]string, 0, len(v.Attachments))
+	for nodeID := range v.Attachments {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	var problems []string
+	for _, nodeID := range nodeIDs {
+		attachment := v.Attachments[nodeID]
+		if attachment.LastError != "" {
+			problems = append(problems, nodeID+": "+attachment.LastError)
+		}
+		if attachment.Condition != nil && attachment.Condition.Abnormal {
+			problems = append(problems, nodeID+": "+attachment.Condition.Message)
+		}
+	}
+
+	if len(problems) == 0 {
+		return VolumeCondition{Message: "volume is healthy"}
+	}
+	return VolumeCondition{Abnormal: true, Message: strings.Join(problems, "; ")}
+}
+
+// VolumeFilter selects volumes in listings. Empty fields match every volume.
+type VolumeFilter struct {
+	Name    string
+	Backend string
+	Status  VolumeStatus
+	Node    string            // Volumes attached to the node or pinned to it
+	Labels  map[string]string // Volumes with all of these labels; an empty value matches any value
+}
+
+// Matches reports whether the volume passes the filter
+func (f VolumeFilter) Matches(v *Volume) bool {
+	if f.Name != "" && v.Name != f.Name {
+		return false
+	}
+	if f.Backend != "" && v.Backend != f.Backend {
+		return false
+	}
+	if f.Status != "" && v.Status != f.Status {
+		return false
+	}
+	if f.Node != "" && v.Node != f.Node && v.Attachments[f.Node] == nil {
+		return false
+	}
+	for key, value := range f.Labels {
+		got, ok := v.Labels[key]
+		if !ok || (value != "" && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// SnapshotStatus represents the current state of a snapshot
+type SnapshotStatus string
+
+const (
+	SnapshotStatusCreating SnapshotStatus = "creating" // Waiting for the node holding the volume data to copy it
+	SnapshotStatusReady    SnapshotStatus = "ready"
+	SnapshotStatusFailed   SnapshotStatus = "failed"
+	SnapshotStatusDeleting SnapshotStatus = "deleting" // Waiting for the node holding the snapshot data to remove it
+)
+
+// Snapshot is a point-in-time copy of a volume's data
+type Snapshot struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	SourceVolumeID string            `json:"source_volume_id"`
+	Backend        string            `json:"backend"`
+	Parameters     map[string]string `json:"parameters,omitempty"` // Backend-specific location of the snapshot data
+	SizeBytes      int64             `json:"size_bytes"`
+	Status         SnapshotStatus    `json:"status,omitempty"`
+	ReadyToUse     bool              `json:"ready_to_use"`
+	Node           string            `json:"node,omitempty"`  // Node that takes and holds the data of a node-local volume's snapshot
+	Error          string            `json:"error,omitempty"` // Why the node failed to take or remove the snapshot
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// DeepCopy returns a copy of the snapshot that shares no mutable state with it
+func (s *Snapshot) DeepCopy() *Snapshot {
+	out := *s
+
+	if s.Parameters != nil {
+		out.Parameters = make(map[string]string, len(s.Parameters))
+		for k, val := range s.Parameters {
+			out.Parameters[k] = val
+		}
+	}
+
+	return &out
+}
+
+// VolumeUsage reports the space and inodes used by a volume
+type VolumeUsage struct {
+	TotalBytes      int64 `json:"total_bytes"`
+	UsedBytes       int64 `json:"used_bytes"`
+	AvailableBytes  int64 `json:"available_bytes"`
+	TotalInodes     int64 `json:"total_inodes"`
+	UsedInodes      int64 `json:"used_inodes"`
+	AvailableInodes int64 `json:"available_inodes"`
+}
+
+// EventType is the kind of change carried by a volume watch event
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+)
+
+// VolumeEvent is a change to a volume delivered by a watch
+type VolumeEvent struct {
+	Type     EventType `json:"type"`
+	Revision int64     `json:"revision"`
+	Volume   *Volume   `json:"volume"`
+}
+
+// ClusterMember describes one member of the replicated metadata store
+type ClusterMember struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peer_urls"`
+	ClientURLs []string `json:"client_urls"`
+	IsLeader   bool     `json:"is_leader"`
+	IsLearner  bool     `json:"is_learner"`
+	Healthy    bool     `json:"healthy"`
+	DBSize     int64    `json:"db_size,omitempty"`
+	RaftIndex  uint64   `json:"raft_index,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Node is a node running the CSI node plugin, with the topology segments it
+// reported when it last registered
+type Node struct {
+	ID        string            `json:"id"`
+	Topology  map[string]string `json:"topology,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"` // Last registration; nodes re-register periodically
+}
+
+// DeepCopy returns a copy of the node that shares no mutable state with it
+func (n *Node) DeepCopy() *Node {
+	out := *n
+
+	if n.Topology != nil {
+		out.Topology = make(map[string]string, len(n.Topology))
+		for k, val := range n.Topology {
+			out.Topology[k] = val
+		}
+	}
+
+	return &out
 }
 
 // CreateVolumeRequest is the request to create a new volume
 type CreateVolumeRequest struct {
-	Name       string            `json:"name" validate:"required"`
-	Backend    string            `json:"backend" validate:"required"`
-	Parameters map[string]string `json:"parameters"`
+	Name          string            `json:"name" validate:"required"`
+	Backend       string            `json:"backend" validate:"required"`
+	Parameters    map[string]string `json:"parameters"`
+	CapacityBytes int64             `json:"capacity_bytes,omitempty"`
+	Source        *VolumeSource     `json:"source,omitempty"`
+	Node          string            `json:"node,omitempty"`        // Pins a node-local volume to a node; otherwise the first node to stage it
+	AccessMode    AccessMode        `json:"access_mode,omitempty"` // Must be one of the backend's access modes; defaults to its first
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// UpdateVolumeRequest is the request to change a volume. Only growing the
+// capacity is supported.
+type UpdateVolumeRequest struct {
+	CapacityBytes int64 `json:"capacity_bytes"`
+}
+
+// CreateSnapshotRequest is the request to snapshot a volume
+type CreateSnapshotRequest struct {
+	Name string `json:"name"`
+}
+
+// SnapshotResultRequest reports the outcome of creating or deleting the data
+// of a snapshot on the node holding it
+type SnapshotResultRequest struct {
+	NodeID     string            `json:"node_id" validate:"required"`
+	Parameters map[string]string `json:"parameters,omitempty"` // Where the data was stored, on creation
+	SizeBytes  int64             `json:"size_bytes,omitempty"`
+	Error      string            `json:"error,omitempty"` // Set when the node failed
 }
 
 // StageVolumeRequest is the request to stage a volume on a node
@@ -38,14 +342,58 @@ type StageVolumeRequest struct {
 	VolumeID    string `json:"volume_id" validate:"required"`
 	NodeID      string `json:"node_id" validate:"required"`
 	StagingPath string `json:"staging_path" validate:"required"`
+	Generation  int64  `json:"generation,omitempty"` // Volume generation of the staged content
+	Error       string `json:"error,omitempty"`      // Set when the node failed to stage the volume
+}
+
+// VolumeConditionRequest reports the result of a health check of a volume
+// on a node
+type VolumeConditionRequest struct {
+	NodeID   string `json:"node_id" validate:"required"`
+	Abnormal bool   `json:"abnormal"`
+	Message  string `json:"message,omitempty"`
+}
+
+// RegisterNodeRequest is the request to register a node with its topology
+type RegisterNodeRequest struct {
+	Topology map[string]string `json:"topology"`
+}
+
+// VolumeUsageRequest reports the usage of a node-local volume as measured on
+// the node holding its data
+type VolumeUsageRequest struct {
+	NodeID string       `json:"node_id" validate:"required"`
+	Usage  *VolumeUsage `json:"usage,omitempty"` // nil when the node cannot tell
+}
+
+// RefreshVolumeRequest is the request to bring the content of a volume up
+// to date on every node where it is staged
+type RefreshVolumeRequest struct {
+	Ref string `json:"ref,omitempty"` // New ref to follow; empty keeps the current one
 }
 
 // PublishVolumeRequest is the request to publish a volume to a target path
 type PublishVolumeRequest struct {
+	VolumeID    string `json:"volume_id" validate:"required"`
+	NodeID      string `json:"node_id" validate:"required"`
+	StagingPath string `json:"staging_path"`
+	TargetPath  string `json:"target_path" validate:"required"`
+	ReadOnly    bool   `json:"read_only"`
+	Error       string `json:"error,omitempty"` // Set when the node failed to publish the volume
+}
+
+// UnstageVolumeRequest is the request to unstage a volume from a node
+type UnstageVolumeRequest struct {
+	VolumeID    string `json:"volume_id" validate:"required"`
+	NodeID      string `json:"node_id" validate:"required"`
+	StagingPath string `json:"staging_path"`
+}
+
+// UnpublishVolumeRequest is the request to unpublish a volume from a target path
+type UnpublishVolumeRequest struct {
 	VolumeID   string `json:"volume_id" validate:"required"`
 	NodeID     string `json:"node_id" validate:"required"`
-	TargetPath string `json:"target_path" validate:"required"`
-	ReadOnly   bool   `json:"read_only"`
+	TargetPath string `json:"target_path"`
 }
 
 // Backend represents a storage backend
@@ -57,10 +405,39 @@ type Backend struct {
 
 // BackendCapability describes what a backend supports
 type BackendCapability struct {
-	SupportsReadOnly  bool `json:"supports_read_only"`
-	SupportsReadWrite bool `json:"supports_read_write"`
-	SupportsSnapshot  bool `json:"supports_snapshot"`
-	SupportsClone     bool `json:"supports_clone"`
+	SupportsReadOnly  bool         `json:"supports_read_only"`
+	SupportsReadWrite bool         `json:"supports_read_write"`
+	SupportsSnapshot  bool         `json:"supports_snapshot"`
+	SupportsClone     bool         `json:"supports_clone"`
+	SupportsCapacity  bool         `json:"supports_capacity"`
+	RequiresCapacity  bool         `json:"requires_capacity"` // Volumes must be created with a capacity
+	NodeLocal         bool         `json:"node_local"`        // Volume data lives on a single node
+	AccessModes       []AccessMode `json:"access_modes"`      // Access modes volumes can be created with; the first is the default
+
+	// ManagedParameters are volume parameters the backend records itself,
+	// e.g. where a clone is copied from, so they can differ from the
+	// parameters the volume was requested with
+	ManagedParameters []string `json:"managed_parameters,omitempty"`
+}
+
+// SupportsAccessMode reports whether volumes of the backend can be created
+// with the access mode
+func (c BackendCapability) SupportsAccessMode(mode AccessMode) bool {
+	for _, m := range c.AccessModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultAccessMode returns the access mode of volumes created without one,
+// or "" if the backend lists no modes
+func (c BackendCapability) DefaultAccessMode() AccessMode {
+	if len(c.AccessModes) == 0 {
+		return ""
+	}
+	return c.AccessModes[0]
 }
 
 // ErrorResponse is the standard error response